@@ -7,18 +7,21 @@ import (
 	"context"
 	"database/sql"
 	"flag"
-	"fmt"
 	"log"
+	"net/http"
 	"os"
 	"os/signal"
 	"path/filepath"
+	"sync"
 	"syscall"
 	"time"
 
-	_ "modernc.org/sqlite"
-
+	"mnemosyne/internal/buffer"
+	"mnemosyne/internal/faultinject"
+	"mnemosyne/internal/metrics"
 	"mnemosyne/internal/monitor"
 	"mnemosyne/internal/storage"
+	"mnemosyne/internal/storage/spool"
 )
 
 const (
@@ -31,15 +34,48 @@ const (
 
 // Config holds the application configuration.
 type Config struct {
-	DBPath         string
-	RedisAddr      string // New: Redis Address
-	TickInterval   time.Duration
-	IdleThreshold  time.Duration
-	BufferCapacity int
-	FlushTimeout   time.Duration
+	Storage            string // "sqlite", "postgres", "rqlite", or "redis+sqlite"
+	DBPath             string
+	DSN                string // Postgres/rqlite connection string
+	RedisAddr          string
+	RedisUsername      string
+	RedisPassword      string
+	RedisTLS           bool
+	RedisEncoding      string // "json" (default) or "msgpack" - see storage.StreamEncoding
+	RedisSpoolDir      string // empty disables the durable overflow spool; see storage.Config.RedisSpoolDir
+	RedisSpoolMaxBytes int64
+	TickInterval       time.Duration
+	IdleThreshold      time.Duration
+	BufferCapacity     int
+	FlushTimeout       time.Duration
+	WALPath            string
+
+	// Encryption-at-rest for the sqlite tier.
+	DBKey          string
+	AllowPlaintext bool
+
+	// Maintenance subsystem (VACUUM / retention / WAL checkpointing).
+	// Only applies to the sqlite backend.
+	VacuumInterval    time.Duration
+	RetentionDays     int
+	VacuumMinFreelist int
+
+	// AdaptiveFlush enables buffer.SystemPressurePolicy so flush timing and
+	// durability relax under OS resource pressure (battery, disk queue -
+	// see buffer.AdaptivePolicy). Off by default since it trades durability
+	// for write amplification only once an operator opts in.
+	AdaptiveFlush bool
+
+	// MetricsAddr, if set, serves Prometheus /metrics on this address.
+	MetricsAddr string
 }
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "rekey" {
+		runRekey(os.Args[2:])
+		return
+	}
+
 	log.Printf("Mnemosyne Core Watcher v%s starting...", Version)
 
 	// Parse command line flags
@@ -50,24 +86,35 @@ func main() {
 		log.Fatalf("Failed to create data directory: %v", err)
 	}
 
-	// Initialize database (Legacy/Fallback)
-	db, err := initDatabase(config.DBPath)
-	if err != nil {
-		log.Fatalf("Failed to initialize database: %v", err)
-	}
-	defer db.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
 
-	// Initialize Redis (Primary for v4.0)
-	var redisClient *storage.RedisClient
-	if config.RedisAddr != "" {
-		rc, err := storage.NewRedisClient(config.RedisAddr, "", 0)
-		if err != nil {
-			log.Fatalf("Failed to connect to Redis at %s: %v", config.RedisAddr, err)
-		}
-		redisClient = rc
-		defer redisClient.Close()
-		log.Printf("Redis connected: %s", config.RedisAddr)
+	// Shared connection registry: today the Watcher is the only subsystem
+	// in this process, but a co-located Processor or HTTP stats endpoint
+	// (see storage.Manager) would otherwise race to open the same sqlite
+	// file or Redis address as their own connection.
+	connManager := storage.NewManager()
+	defer connManager.CloseAll()
+
+	backend, err := storage.Open(ctx, config.Storage, storage.Config{
+		DBPath:             config.DBPath,
+		DSN:                config.DSN,
+		RedisAddr:          config.RedisAddr,
+		RedisUsername:      config.RedisUsername,
+		RedisPassword:      config.RedisPassword,
+		RedisTLS:           config.RedisTLS,
+		RedisEncoding:      storage.StreamEncoding(config.RedisEncoding),
+		RedisSpoolDir:      config.RedisSpoolDir,
+		RedisSpoolMaxBytes: config.RedisSpoolMaxBytes,
+		DBKey:              config.DBKey,
+		AllowPlaintext:     config.AllowPlaintext,
+		Manager:            connManager,
+	})
+	if err != nil {
+		log.Fatalf("Failed to open storage backend %q: %v", config.Storage, err)
 	}
+	defer backend.Close()
+	log.Printf("Storage backend: %s", config.Storage)
 
 	// Create monitor
 	monitorConfig := monitor.Config{
@@ -75,13 +122,64 @@ func main() {
 		IdleThreshold:  config.IdleThreshold,
 		BufferCapacity: config.BufferCapacity,
 		FlushTimeout:   config.FlushTimeout,
+		WALPath:        config.WALPath,
+	}
+	if config.AdaptiveFlush {
+		monitorConfig.AdaptivePolicy = buffer.NewSystemPressurePolicy()
 	}
 
-	watcher := monitor.New(db, redisClient, monitorConfig)
+	watcher := monitor.New(backend, monitorConfig)
 
-	// Setup graceful shutdown
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
+	// Maintenance subsystem: periodic VACUUM INTO + retention pruning +
+	// (when WAL is enabled) wal_checkpoint(TRUNCATE). Only the sqlite driver
+	// exposes the *sql.DB this needs; other backends manage their own upkeep.
+	// watcher.FlushMu() lets the maintainer refuse to run while a flush
+	// transaction is open against the same database.
+	if maintainer := newMaintainerFor(backend, config, watcher.FlushMu()); maintainer != nil {
+		maintainer.Start(ctx)
+		defer maintainer.Stop()
+	}
+
+	// Wire leader-election status through for observability when the
+	// backend supports it (currently redis+sqlite; see storage.Lease).
+	if leased, ok := backend.(interface{ Lease() *storage.Lease }); ok {
+		watcher.SetLease(leased.Lease())
+	}
+
+	// Wire the overflow spool through for observability when the backend
+	// has one configured (currently redis+sqlite with --redis-spool-dir
+	// set; see storage/spool).
+	if spooled, ok := backend.(interface{ Spool() *spool.Spool }); ok {
+		if s := spooled.Spool(); s != nil {
+			watcher.SetSpool(s)
+		}
+	}
+
+	// Metrics: a Prometheus exporter wired into both the monitor (flush
+	// latency, buffer occupancy, idle ratio) and the Redis client (enqueue
+	// errors), started only when --metrics-addr is set.
+	var metricsServer *http.Server
+	if config.MetricsAddr != "" {
+		sink := metrics.NewPrometheusSink()
+		watcher.SetMetricsSink(sink)
+
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", sink.Handler())
+		mux.Handle("/faultinject", faultinject.AdminHandler()) // 404s unless built with -tags faultinject
+		metricsServer = &http.Server{Addr: config.MetricsAddr, Handler: mux}
+
+		go func() {
+			log.Printf("Metrics server listening on %s", config.MetricsAddr)
+			if err := metricsServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				log.Printf("Metrics server error: %v", err)
+			}
+		}()
+
+		// Clean stale metrics on shutdown so a restarted Watcher doesn't
+		// briefly report gauges scraped from this process's last state.
+		defer sink.Reset()
+		defer metricsServer.Shutdown(context.Background())
+	}
 
 	// Signal handling for graceful shutdown
 	stop := make(chan os.Signal, 1)
@@ -128,64 +226,111 @@ func main() {
 
 // parseFlags parses command line flags and returns configuration.
 func parseFlags() Config {
-	dbPath := flag.String("db", defaultDBPath, "Path to SQLite database file")
-	redisAddr := flag.String("redis", "", "Redis address (e.g., localhost:6379)")
+	storageName := flag.String("storage", "sqlite", "Storage backend: sqlite, postgres, rqlite, or redis+sqlite")
+	dbPath := flag.String("db", defaultDBPath, "Path to SQLite database file (sqlite/redis+sqlite backends)")
+	dsn := flag.String("dsn", "", "Connection string for the postgres/rqlite backends")
+	redisAddr := flag.String("redis", "", "Redis address for the redis+sqlite backend: bare host:port, or a redis://, redis+sentinel://, redis+cluster:// URI (see storage.ParseRedisURI)")
+	redisUsername := flag.String("redis-username", "", "Redis ACL username (redis+sqlite backend)")
+	redisPassword := flag.String("redis-password", "", "Redis password/ACL secret (redis+sqlite backend)")
+	redisTLS := flag.Bool("redis-tls", false, "Connect to Redis over TLS (redis+sqlite backend)")
+	redisEncoding := flag.String("redis-encoding", "json", "Redis stream wire format for the redis+sqlite backend: json or msgpack")
+	redisSpoolDir := flag.String("redis-spool-dir", "", "Directory for a durable overflow queue that spools events to disk when Redis is unreachable (redis+sqlite backend; empty disables it)")
+	redisSpoolMaxBytes := flag.Int64("redis-spool-max-bytes", 0, "Max on-disk size of --redis-spool-dir before the oldest un-acked entries are evicted (0 uses spool.Open's 1GB default)")
+	dbKey := flag.String("db-key", os.Getenv("MNEMOSYNE_DB_KEY"), "Encryption key for the sqlite database file (also read from MNEMOSYNE_DB_KEY); requires a SQLCipher-compatible build")
+	allowPlaintext := flag.Bool("allow-plaintext", false, "Allow starting against a database that did not accept --db-key instead of refusing to run")
 	tickInterval := flag.Duration("tick", 1000*time.Millisecond, "Tick interval (e.g., 1000ms for 1Hz)")
 	idleThreshold := flag.Duration("idle", 60*time.Second, "Idle threshold before marking as idle")
 	bufferCapacity := flag.Int("buffer", 100, "Buffer capacity before forced flush")
 	flushTimeout := flag.Duration("flush", 5*time.Minute, "Time between automatic flushes")
+	walPath := flag.String("wal-path", "", "Path to the buffer's crash-safe write-ahead log (empty disables it)")
+	vacuumInterval := flag.Duration("vacuum-interval", 0, "How often to run VACUUM INTO and swap it in (0 disables)")
+	retentionDays := flag.Int("retention-days", 0, "Prune raw_events older than this many days (0 disables)")
+	vacuumMinFreelist := flag.Int("vacuum-min-freelist", 1000, "Minimum freelist_count pages before a vacuum is worthwhile")
+	adaptiveFlush := flag.Bool("adaptive-flush", false, "Defer flushes and relax durability under OS resource pressure (battery, disk queue - see buffer.SystemPressurePolicy)")
+	metricsAddr := flag.String("metrics-addr", "", "Address to serve Prometheus /metrics on (empty disables it)")
 
 	flag.Parse()
 
 	return Config{
-		DBPath:         *dbPath,
-		RedisAddr:      *redisAddr,
-		TickInterval:   *tickInterval,
-		IdleThreshold:  *idleThreshold,
-		BufferCapacity: *bufferCapacity,
-		FlushTimeout:   *flushTimeout,
+		Storage:            *storageName,
+		DBPath:             *dbPath,
+		DSN:                *dsn,
+		RedisAddr:          *redisAddr,
+		RedisUsername:      *redisUsername,
+		RedisPassword:      *redisPassword,
+		RedisTLS:           *redisTLS,
+		RedisEncoding:      *redisEncoding,
+		RedisSpoolDir:      *redisSpoolDir,
+		RedisSpoolMaxBytes: *redisSpoolMaxBytes,
+		TickInterval:       *tickInterval,
+		IdleThreshold:      *idleThreshold,
+		BufferCapacity:     *bufferCapacity,
+		FlushTimeout:       *flushTimeout,
+		WALPath:            *walPath,
+		VacuumInterval:     *vacuumInterval,
+		RetentionDays:      *retentionDays,
+		VacuumMinFreelist:  *vacuumMinFreelist,
+		AdaptiveFlush:      *adaptiveFlush,
+		MetricsAddr:        *metricsAddr,
+		DBKey:              *dbKey,
+		AllowPlaintext:     *allowPlaintext,
 	}
 }
 
-// ensureDataDir ensures the data directory exists.
-func ensureDataDir(dbPath string) error {
-	dir := filepath.Dir(dbPath)
-	if dir == "." {
-		return nil
+// runRekey implements `mnemosyne rekey`, streaming the sqlite database at
+// --db into a freshly encrypted copy under a new key. It takes its own flag
+// set (mirroring the git-style `<binary> <subcommand> [flags]` convention)
+// since most top-level flags (tick interval, buffer size, ...) don't apply.
+func runRekey(args []string) {
+	fs := flag.NewFlagSet("rekey", flag.ExitOnError)
+	dbPath := fs.String("db", defaultDBPath, "Path to the existing SQLite database file")
+	out := fs.String("out", "", "Path to write the rekeyed database to (required)")
+	oldKey := fs.String("db-key", os.Getenv("MNEMOSYNE_DB_KEY"), "Existing encryption key for --db, if it's already encrypted (also read from MNEMOSYNE_DB_KEY)")
+	newKey := fs.String("new-db-key", os.Getenv("MNEMOSYNE_NEW_DB_KEY"), "New encryption key (also read from MNEMOSYNE_NEW_DB_KEY)")
+	allowPlaintext := fs.Bool("allow-plaintext", false, "Permit finishing even if the new key doesn't appear to take effect")
+	fs.Parse(args)
+
+	if *out == "" {
+		log.Fatal("rekey: --out is required")
 	}
 
-	return os.MkdirAll(dir, 0755)
+	srcCfg := storage.EncryptionConfig{Key: *oldKey, AllowPlaintext: *allowPlaintext}
+	dstCfg := storage.EncryptionConfig{Key: *newKey, AllowPlaintext: *allowPlaintext}
+	if err := storage.Rekey(*dbPath, *out, srcCfg, dstCfg); err != nil {
+		log.Fatalf("rekey failed: %v", err)
+	}
+
+	log.Printf("Rekeyed %s -> %s", *dbPath, *out)
 }
 
-// initDatabase initializes the SQLite database with proper configuration.
-func initDatabase(dbPath string) (*sql.DB, error) {
-	// Open database connection
-	db, err := sql.Open("sqlite", dbPath)
-	if err != nil {
-		return nil, fmt.Errorf("failed to open database: %w", err)
+// newMaintainerFor builds a storage.Maintainer if backend exposes a raw
+// *sql.DB (currently only the sqlite driver does via its DB() accessor).
+// Returns nil for backends that manage their own housekeeping. flushMu is
+// the Watcher's flush mutex (see monitor.Monitor.FlushMu) so the maintainer
+// refuses to VACUUM/checkpoint while a flush transaction is open.
+func newMaintainerFor(backend storage.Backend, config Config, flushMu *sync.Mutex) *storage.Maintainer {
+	sqliteDB, ok := backend.(interface{ DB() *sql.DB })
+	if !ok {
+		return nil
 	}
 
-	// Configure SQLite for optimal performance and SSD protection
-	pragmas := []string{
-		"PRAGMA journal_mode = DELETE", // Uses rollback journal (safer for Windows+Docker bind mounts)
-		"PRAGMA synchronous = NORMAL",  // Balance between safety and performance
-		"PRAGMA temp_store = MEMORY",   // Store temp tables in RAM
-		"PRAGMA mmap_size = 268435456", // 256MB memory-mapped I/O
-		"PRAGMA busy_timeout = 5000",   // Wait 5 seconds on lock
-		"PRAGMA foreign_keys = ON",     // Enable foreign keys
-	}
+	maintConfig := storage.DefaultMaintenanceConfig()
+	// The sqlite backend (backend_sqlite.go) always opens with journal_mode=WAL,
+	// so checkpointing is always relevant once this backend is selected.
+	maintConfig.JournalMode = "WAL"
+	maintConfig.VacuumInterval = config.VacuumInterval
+	maintConfig.RetentionDays = config.RetentionDays
+	maintConfig.VacuumMinFreelist = config.VacuumMinFreelist
 
-	for _, pragma := range pragmas {
-		if _, err := db.Exec(pragma); err != nil {
-			return nil, fmt.Errorf("failed to set pragma %q: %w", pragma, err)
-		}
-	}
+	return storage.NewMaintainer(sqliteDB, config.DBPath, maintConfig, flushMu)
+}
 
-	// Verify connection works
-	if err := db.Ping(); err != nil {
-		return nil, fmt.Errorf("failed to ping database: %w", err)
+// ensureDataDir ensures the data directory exists.
+func ensureDataDir(dbPath string) error {
+	dir := filepath.Dir(dbPath)
+	if dir == "." {
+		return nil
 	}
 
-	log.Printf("Database initialized: %s", dbPath)
-	return db, nil
+	return os.MkdirAll(dir, 0755)
 }
@@ -0,0 +1,153 @@
+package buffer
+
+import "time"
+
+// PressurePolicy controls what Add does when the buffer is already holding
+// MaxCapacity entries and the configured sink hasn't drained them yet.
+type PressurePolicy int
+
+const (
+	// Block makes Add wait (polling, releasing the lock between checks)
+	// until a flush frees up room. Safe only when something else is
+	// actively draining the buffer; otherwise Add stalls the caller.
+	Block PressurePolicy = iota
+	// DropOldest evicts entries[0] to make room for the new entry.
+	DropOldest
+	// DropNewest discards the incoming entry instead of appending it.
+	DropNewest
+)
+
+// blockPollInterval is how often Add rechecks capacity while waiting under
+// the Block policy.
+const blockPollInterval = 10 * time.Millisecond
+
+// statsWindow is how many recent flush latencies Stats() reports.
+const statsWindow = 20
+
+// ewmaAlpha weights how much a single flush moves the running latency/error
+// averages; smaller means smoother but slower to react.
+const ewmaAlpha = 0.3
+
+// growthFactor/shrinkFactor control how aggressively effective capacity and
+// flush timeout move toward MaxCapacity (on sustained pressure) or back
+// toward the configured baseline (once latency recovers).
+const (
+	growthFactor   = 1.5
+	shrinkFactor   = 0.85
+	errorThreshold = 0.2 // EWMA error rate above this counts as "spiking"
+)
+
+// Stats is a snapshot of the buffer's adaptive flush-throttling state.
+type Stats struct {
+	EffectiveCapacity     int
+	EffectiveFlushTimeout time.Duration
+	RecentFlushLatencies  []time.Duration
+	ErrorCount            int
+	DroppedCount          int
+}
+
+// Stats returns a snapshot of the buffer's current adaptive tuning and
+// recent flush health. Safe to call concurrently.
+func (b *Buffer) Stats() Stats {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	latencies := make([]time.Duration, len(b.flushLatencies))
+	copy(latencies, b.flushLatencies)
+
+	return Stats{
+		EffectiveCapacity:     b.effectiveCapacity,
+		EffectiveFlushTimeout: b.effectiveFlushTimeout,
+		RecentFlushLatencies:  latencies,
+		ErrorCount:            b.errorCount,
+		DroppedCount:          b.droppedCount,
+	}
+}
+
+// maxCapacityLocked returns the configured ceiling on held entries, with
+// MaxCapacity defaulting to the baseline Capacity when unset (i.e. adaptive
+// growth is opt-in). Caller must hold b.mu.
+func (b *Buffer) maxCapacityLocked() int {
+	if b.config.MaxCapacity > 0 {
+		return b.config.MaxCapacity
+	}
+	return b.baseCapacity
+}
+
+// recordFlushLocked folds a flush's duration and outcome into the running
+// EWMAs, trims the recent-latency window, and re-tunes effective capacity
+// and flush timeout. Caller must hold b.mu.
+func (b *Buffer) recordFlushLocked(duration time.Duration, err error) {
+	b.flushLatencies = append(b.flushLatencies, duration)
+	if len(b.flushLatencies) > statsWindow {
+		b.flushLatencies = b.flushLatencies[len(b.flushLatencies)-statsWindow:]
+	}
+
+	if b.latencyEWMA == 0 {
+		b.latencyEWMA = duration
+	} else {
+		b.latencyEWMA = time.Duration(ewmaAlpha*float64(duration) + (1-ewmaAlpha)*float64(b.latencyEWMA))
+	}
+
+	outcome := 0.0
+	if err != nil {
+		b.errorCount++
+		outcome = 1.0
+	}
+	b.errorEWMA = ewmaAlpha*outcome + (1-ewmaAlpha)*b.errorEWMA
+
+	b.retuneLocked()
+}
+
+// retuneLocked grows effectiveCapacity/effectiveFlushTimeout toward
+// MaxCapacity when latency or errors are running hot, and shrinks them back
+// toward the configured baseline once things cool off. Caller must hold
+// b.mu.
+func (b *Buffer) retuneLocked() {
+	target := b.config.TargetFlushLatency
+	if target <= 0 {
+		return // adaptive throttling disabled; stick to the configured baseline
+	}
+
+	maxCapacity := b.maxCapacityLocked()
+	underPressure := b.latencyEWMA > target || b.errorEWMA > errorThreshold
+
+	if underPressure {
+		b.effectiveCapacity = min(int(float64(b.effectiveCapacity)*growthFactor)+1, maxCapacity)
+		b.effectiveFlushTimeout = minDuration(time.Duration(float64(b.effectiveFlushTimeout)*growthFactor)+1, b.baseFlushTimeout*8)
+		return
+	}
+
+	if b.latencyEWMA < target/2 {
+		b.effectiveCapacity = max(b.baseCapacity, int(float64(b.effectiveCapacity)*shrinkFactor))
+		b.effectiveFlushTimeout = maxDuration(b.baseFlushTimeout, time.Duration(float64(b.effectiveFlushTimeout)*shrinkFactor))
+	}
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func max(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+func minDuration(a, b time.Duration) time.Duration {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func maxDuration(a, b time.Duration) time.Duration {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -3,10 +3,13 @@
 package buffer
 
 import (
+	"context"
 	"database/sql"
-	"fmt"
+	"log"
 	"sync"
 	"time"
+
+	"mnemosyne/internal/hostid"
 )
 
 // LogEntry represents a single activity log entry.
@@ -14,14 +17,43 @@ import (
 // Matches the raw_events table schema in db/schema.sql.
 type LogEntry struct {
 	SessionUUID    string  // Session UUID for grouping events
-	UnixTime       int64   // Unix timestamp in milliseconds
+	HostUUID       string  // Stable per-machine ID (see hostid.Get), stamped by Buffer.Add itself rather than the caller
+	UnixTime       int64   // Unix timestamp in milliseconds; start of the run when coalesced
 	ProcessName    string  // Process executable name (interned string for memory efficiency)
 	WindowTitle    string  // Window title text
-	WindowHandle   int64   // HWND as int64 (SQLite INTEGER)
+	WindowHandle   int64   // Portable window identifier (see sysinfo.WindowHandle), stored as SQLite INTEGER
 	InputIdleMs    int64   // Time of inactivity in milliseconds
-	InputIntensity float32 // Calculated input intensity (0.0 to 1.0)
+	InputIntensity float32 // Calculated input intensity (0.0 to 1.0); max across the run when coalesced
 	ScreenshotPath string  // Path to captured screenshot
-	ScreenshotData []byte  // In-memory screenshot data (JPEG)
+	ScreenshotData []byte  // In-memory screenshot data (JPEG); empty when vision/dedupe judged this frame a duplicate of the last stored one
+	ScreenshotHash uint64  // xxhash64 of the stored/referenced frame's encoded bytes (see vision/dedupe.HashFrame), for content-addressing in the Processor tier
+
+	// The fields below enrich the entry with the foreground process'
+	// resource usage at sample time (see sysinfo.ProcessSampler). All zero
+	// when the platform has no sampler - Windows is currently the only one
+	// that does.
+	CPUPercent    float32 // CPU% since the previous sample of this PID (0-100*NumCPU)
+	WorkingSetRSS uint64  // Working-set RSS in bytes
+	IOReadBytes   uint64  // Cumulative IO bytes read by the process
+	IOWriteBytes  uint64  // Cumulative IO bytes written by the process
+	HandleCount   uint32  // Open OS handle count
+
+	// The fields below come from the low-level keyboard/mouse hooks (see
+	// sysinfo.InputActivity), summed over the same rolling window that
+	// produced InputIntensity when a platform hook subsystem is available.
+	// All zero on a platform without one (currently only Windows has one).
+	Keystrokes    uint32 // Key-down events in the rolling window
+	MouseClicks   uint32 // Mouse button-down events in the rolling window
+	MouseTravelPx uint64 // Cumulative mouse travel distance in pixels
+
+	// The fields below are only meaningful once Coalesce has merged two or
+	// more samples of the same window into a run (see coalesce.go).
+	// SampleCount <= 1 means "not coalesced" - read UnixTime/InputIdleMs/
+	// InputIntensity as a single sample the way callers always have.
+	EndUnixTime       int64   // Unix timestamp (ms) of the run's last sample
+	SampleCount       int     // Number of samples merged into this entry
+	InputIntensitySum float32 // Sum of InputIntensity across the run
+	InputIdleMin      int64   // Minimum InputIdleMs seen across the run
 }
 
 // BufferConfig holds configuration for the buffer behavior.
@@ -29,6 +61,51 @@ type BufferConfig struct {
 	Capacity      int           // Maximum number of entries before forced flush
 	FlushTimeout  time.Duration // Time between automatic flushes
 	IdleThreshold time.Duration // Time of inactivity before marking as idle
+
+	// WALPath, if set, enables a crash-safe, segmented write-ahead log
+	// alongside the in-memory buffer (see wal.go): Add synchronously
+	// appends an LSN-stamped, CRC32C-checked record before returning, and a
+	// successful flush checkpoints it away (see Checkpoint,
+	// WithWALCheckpointLSN). Use buffer.Recover(WALPath) at startup to
+	// replay anything left behind by a crash between a tick and its next
+	// flush - callers should only replay records whose LSN is past their
+	// own last durable checkpoint (see storage.WALCheckpointStore).
+	WALPath string
+
+	// MaxCapacity bounds how far the effective capacity can grow under
+	// sustained flush pressure (see adaptive.go). Defaults to Capacity,
+	// which disables growth. Once reached, PressurePolicy decides what
+	// Add does next.
+	MaxCapacity int
+
+	// TargetFlushLatency is the flush duration Buffer tries to stay under.
+	// Zero disables adaptive throttling entirely: Capacity/FlushTimeout are
+	// then used as fixed values, matching pre-adaptive behavior.
+	TargetFlushLatency time.Duration
+
+	// PressurePolicy controls Add's behavior once MaxCapacity is hit while
+	// the sink is still slow. Defaults to Block.
+	PressurePolicy PressurePolicy
+
+	// Coalesce, if true, makes Add merge an incoming entry into the tail
+	// entry instead of appending a new one whenever they share the same
+	// (ProcessName, WindowTitle, WindowHandle) - collapsing the long runs of
+	// identical samples a once-a-second watcher produces into a single
+	// range record (see coalesce.go). Off by default.
+	Coalesce bool
+
+	// CoalesceGapMs, when Coalesce is enabled, breaks a run even for the
+	// same window once the incoming sample is more than this many
+	// milliseconds after the run's last sample. Zero means no gap limit.
+	CoalesceGapMs int64
+
+	// AdaptivePolicy, if set, lets live OS resource pressure (battery, disk
+	// queue, CPU - see sysinfo.SystemPressure) defer flushes beyond
+	// effectiveFlushTimeout and relax sink durability, on top of (not
+	// instead of) the sink-latency-driven adaptation above (see
+	// adaptive.go). Feed readings via SetPressureReading. Nil disables this
+	// entirely, matching TargetFlushLatency's opt-in convention.
+	AdaptivePolicy AdaptivePolicy
 }
 
 // DefaultConfig returns sensible defaults for the buffer.
@@ -50,16 +127,66 @@ type Buffer struct {
 	flushTimer *time.Timer
 	flushChan  chan struct{}
 	stopChan   chan struct{}
+	wal        *wal
+	hostUUID   string // resolved once in New via hostid.Get, stamped onto every entry by Add
+
+	// Adaptive flush throttling (see adaptive.go). baseCapacity/
+	// baseFlushTimeout hold the configured baseline that effectiveCapacity/
+	// effectiveFlushTimeout grow away from under pressure and shrink back
+	// toward once it subsides.
+	baseCapacity          int
+	baseFlushTimeout      time.Duration
+	effectiveCapacity     int
+	effectiveFlushTimeout time.Duration
+	flushLatencies        []time.Duration
+	latencyEWMA           time.Duration
+	errorEWMA             float64
+	errorCount            int
+	droppedCount          int
+
+	// Pressure-driven adaptive flushing (see pressure.go), set by
+	// SetPressureReading and layered on top of effectiveFlushTimeout/
+	// effectiveCapacity above rather than replacing them.
+	pressureDeferral time.Duration
+	pressureRelaxed  bool
+
+	// lastLSN is the WAL LSN assigned to the most recently Add()-ed entry
+	// (see wal.go). Since flushLocked/GetAndClear always clear the entire
+	// buffer at once, it doubles as "the highest LSN covered by the next
+	// flush" - threaded through as the checkpoint LSN once that flush is
+	// durably committed (see Checkpoint, storage.WALCheckpointStore).
+	lastLSN int64
 }
 
-// New creates a new buffer with the given configuration.
+// New creates a new buffer with the given configuration. If config.WALPath
+// is set but the WAL can't be opened, the buffer still starts - it just
+// runs without crash protection, logging why.
 func New(config BufferConfig) *Buffer {
 	b := &Buffer{
-		entries:   make([]LogEntry, 0, config.Capacity),
-		config:    config,
-		lastFlush: time.Now(),
-		flushChan: make(chan struct{}, 1),
-		stopChan:  make(chan struct{}),
+		entries:               make([]LogEntry, 0, config.Capacity),
+		config:                config,
+		lastFlush:             time.Now(),
+		flushChan:             make(chan struct{}, 1),
+		stopChan:              make(chan struct{}),
+		baseCapacity:          config.Capacity,
+		baseFlushTimeout:      config.FlushTimeout,
+		effectiveCapacity:     config.Capacity,
+		effectiveFlushTimeout: config.FlushTimeout,
+	}
+
+	if config.WALPath != "" {
+		w, err := openWAL(config.WALPath)
+		if err != nil {
+			log.Printf("buffer: WAL disabled: %v", err)
+		} else {
+			b.wal = w
+		}
+	}
+
+	if id, err := hostid.Get(); err != nil {
+		log.Printf("buffer: failed to resolve host id, entries will carry an empty HostUUID: %v", err)
+	} else {
+		b.hostUUID = id
 	}
 
 	// Start automatic flush timer
@@ -68,9 +195,12 @@ func New(config BufferConfig) *Buffer {
 	return b
 }
 
-// startFlushTimer starts the background timer for periodic flushing.
+// startFlushTimer starts the background timer for periodic flushing, using
+// the current effective flush timeout (see adaptive.go) plus any extra
+// deferral the AdaptivePolicy has asked for under system pressure (see
+// pressure.go).
 func (b *Buffer) startFlushTimer() {
-	b.flushTimer = time.AfterFunc(b.config.FlushTimeout, func() {
+	b.flushTimer = time.AfterFunc(b.effectiveFlushTimeout+b.pressureDeferral, func() {
 		select {
 		case b.flushChan <- struct{}{}:
 		default:
@@ -89,14 +219,51 @@ func (b *Buffer) resetFlushTimer() {
 
 // Add adds a new log entry to the buffer.
 // Returns true if the buffer was flushed due to capacity threshold.
+//
+// If the buffer is already holding MaxCapacity entries (see adaptive.go),
+// PressurePolicy decides what happens next: Block waits for room to free up,
+// DropOldest evicts the oldest entry to make room, and DropNewest discards
+// entry instead of appending it. Dropped entries are counted in Stats().
 func (b *Buffer) Add(entry LogEntry) (flushed bool) {
+	entry.HostUUID = b.hostUUID
+
 	b.mu.Lock()
 	defer b.mu.Unlock()
 
+	for b.config.PressurePolicy == Block && len(b.entries) >= b.maxCapacityLocked() {
+		b.mu.Unlock()
+		time.Sleep(blockPollInterval)
+		b.mu.Lock()
+	}
+
+	if len(b.entries) >= b.maxCapacityLocked() {
+		switch b.config.PressurePolicy {
+		case DropNewest:
+			b.droppedCount++
+			return false
+		case DropOldest:
+			b.entries = b.entries[1:]
+			b.droppedCount++
+		}
+	}
+
+	if b.wal != nil {
+		lsn, err := b.wal.append(entry)
+		if err != nil {
+			log.Printf("buffer: WAL append failed: %v", err)
+		} else {
+			b.lastLSN = lsn
+		}
+	}
+
+	if b.config.Coalesce && b.tryCoalesceLocked(entry) {
+		return false
+	}
+
 	b.entries = append(b.entries, entry)
 
-	// Check capacity threshold
-	if len(b.entries) >= b.config.Capacity {
+	// Check effective capacity threshold (adaptive - see adaptive.go)
+	if len(b.entries) >= b.effectiveCapacity {
 		// Signal flush (will be handled by caller)
 		return true
 	}
@@ -118,9 +285,9 @@ func (b *Buffer) Size() int {
 
 	size := 0
 	for _, entry := range b.entries {
-		size += 32 + // Fixed fields (SessionUUID string pointer + UnixTime + WindowHandle + InputIdleMs + InputIntensity)
+		size += 80 + // Fixed fields (SessionUUID string pointer + UnixTime + WindowHandle + InputIdleMs + InputIntensity + process resource usage fields + input hook counters)
 			len(entry.SessionUUID) +
-			len(entry.ProcessName) +
+			len(entry.HostUUID) +
 			len(entry.ProcessName) +
 			len(entry.WindowTitle) +
 			len(entry.ScreenshotPath) +
@@ -129,69 +296,38 @@ func (b *Buffer) Size() int {
 	return size
 }
 
-// Flush writes all buffered entries to the database in a single transaction.
-// This is the critical operation for SSD protection - batch inserts minimize I/O.
-func (b *Buffer) Flush(db *sql.DB) error {
+// Flush writes all buffered entries to sink in a single call. This is the
+// critical operation for SSD protection - batch writes minimize I/O.
+func (b *Buffer) Flush(sink Sink) error {
+	return b.FlushContext(context.Background(), sink)
+}
+
+// FlushContext is Flush with an explicit context, threaded through to
+// sink.Write so a caller can bound how long a single flush attempt (and any
+// retries the sink itself does) is allowed to take. Used by Flusher.Close
+// to honor its deadline.
+func (b *Buffer) FlushContext(ctx context.Context, sink Sink) error {
 	b.mu.Lock()
 	defer b.mu.Unlock()
 
-	if len(b.entries) == 0 {
-		return nil
-	}
-
-	// Start transaction
-	tx, err := db.Begin()
-	if err != nil {
-		return fmt.Errorf("failed to begin transaction: %w", err)
-	}
-
-	// Prepare insert statement
-	stmt, err := tx.Prepare(`
-		INSERT INTO raw_events
-		(session_uuid, timestamp_utc, unix_time, process_name, window_title, window_hwnd, input_idle_ms, input_intensity, screenshot_path)
-		VALUES (?, datetime('now'), ?, ?, ?, ?, ?, ?, ?)
-	`)
-	if err != nil {
-		tx.Rollback()
-		return fmt.Errorf("failed to prepare statement: %w", err)
-	}
-	defer stmt.Close()
-
-	// Batch insert all entries
-	for _, entry := range b.entries {
-		_, err := stmt.Exec(
-			entry.SessionUUID,
-			entry.UnixTime,
-			entry.ProcessName,
-			entry.WindowTitle,
-			entry.WindowHandle,
-			entry.InputIdleMs,
-			entry.InputIdleMs,
-			entry.InputIntensity,
-			entry.ScreenshotPath,
-		)
-		if err != nil {
-			tx.Rollback()
-			return fmt.Errorf("failed to insert entry: %w", err)
-		}
-	}
-
-	// Commit transaction
-	if err := tx.Commit(); err != nil {
-		return fmt.Errorf("failed to commit transaction: %w", err)
-	}
-
-	// Clear buffer and update last flush time
-	b.entries = b.entries[:0]
-	b.lastFlush = time.Now()
-	b.resetFlushTimer()
+	return b.flushLocked(ctx, sink)
+}
 
-	return nil
+// FlushDB is a backward-compatible shim for callers still passing a raw
+// *sql.DB instead of a Sink.
+func (b *Buffer) FlushDB(db *sql.DB) error {
+	return b.Flush(NewSQLiteSink(db))
 }
 
 // ForceFlush immediately flushes all entries regardless of thresholds.
 // Used during graceful shutdown.
-func (b *Buffer) ForceFlush(db *sql.DB) error {
+func (b *Buffer) ForceFlush(sink Sink) error {
+	return b.ForceFlushContext(context.Background(), sink)
+}
+
+// ForceFlushContext is ForceFlush with an explicit context; see
+// FlushContext.
+func (b *Buffer) ForceFlushContext(ctx context.Context, sink Sink) error {
 	b.mu.Lock()
 	defer b.mu.Unlock()
 
@@ -204,62 +340,43 @@ func (b *Buffer) ForceFlush(db *sql.DB) error {
 		b.flushTimer.Stop()
 	}
 
-	// Perform flush
-	err := b.flushUnsafe(db)
-	if err != nil {
-		return err
-	}
-
-	// Clear buffer
-	b.entries = b.entries[:0]
-	b.lastFlush = time.Now()
+	return b.flushLocked(ctx, sink)
+}
 
-	return nil
+// ForceFlushDB is a backward-compatible shim for callers still passing a raw
+// *sql.DB instead of a Sink.
+func (b *Buffer) ForceFlushDB(db *sql.DB) error {
+	return b.ForceFlush(NewSQLiteSink(db))
 }
 
-// flushUnsafe performs flush without locking (caller must hold lock).
-func (b *Buffer) flushUnsafe(db *sql.DB) error {
+// flushLocked writes the buffered entries through sink and, on success,
+// clears the buffer and rotates the WAL. Every call (success or failure) is
+// timed and fed into the adaptive latency/error tracking in adaptive.go,
+// which may grow or shrink the effective capacity and flush timeout before
+// this returns. Caller must hold b.mu.
+func (b *Buffer) flushLocked(ctx context.Context, sink Sink) error {
 	if len(b.entries) == 0 {
 		return nil
 	}
 
-	tx, err := db.Begin()
-	if err != nil {
-		return fmt.Errorf("failed to begin transaction: %w", err)
+	if b.pressureRelaxed {
+		ctx = WithRelaxedDurability(ctx)
 	}
-
-	// Prepare insert statement
-	stmt, err := tx.Prepare(`
-		INSERT INTO raw_events
-		(session_uuid, timestamp_utc, unix_time, process_name, window_title, window_hwnd, input_idle_ms, input_intensity, screenshot_path)
-		VALUES (?, datetime('now'), ?, ?, ?, ?, ?, ?, ?)
-	`)
-	if err != nil {
-		tx.Rollback()
-		return fmt.Errorf("failed to prepare statement: %w", err)
+	if b.wal != nil {
+		ctx = WithWALCheckpointLSN(ctx, b.lastLSN)
 	}
-	defer stmt.Close()
 
-	for _, entry := range b.entries {
-		_, err := stmt.Exec(
-			entry.SessionUUID,
-			entry.UnixTime,
-			entry.ProcessName,
-			entry.WindowTitle,
-			entry.WindowHandle,
-			entry.InputIdleMs,
-			entry.InputIntensity,
-			entry.ScreenshotPath,
-		)
-		if err != nil {
-			tx.Rollback()
-			return fmt.Errorf("failed to insert entry: %w", err)
-		}
+	start := time.Now()
+	err := sink.Write(ctx, b.entries)
+	b.recordFlushLocked(time.Since(start), err)
+	if err != nil {
+		return err
 	}
 
-	if err := tx.Commit(); err != nil {
-		return fmt.Errorf("failed to commit transaction: %w", err)
-	}
+	b.entries = b.entries[:0]
+	b.lastFlush = time.Now()
+	b.resetFlushTimer()
+	b.checkpointWALLocked(b.lastLSN)
 
 	return nil
 }
@@ -276,13 +393,42 @@ func (b *Buffer) LastFlush() time.Time {
 	return b.lastFlush
 }
 
-// Stop stops the background flush timer.
+// Stop stops the background flush timer and closes the WAL, if any.
 // Should be called during graceful shutdown.
 func (b *Buffer) Stop() {
 	close(b.stopChan)
 	if b.flushTimer != nil {
 		b.flushTimer.Stop()
 	}
+	if b.wal != nil {
+		if err := b.wal.close(); err != nil {
+			log.Printf("buffer: failed to close WAL: %v", err)
+		}
+	}
+}
+
+// checkpointWALLocked reclaims WAL segments fully covered by lsn, once the
+// caller has durably recorded lsn as committed elsewhere (a successful
+// sink.Write for flushLocked, or a storage.WALCheckpointStore write for
+// Checkpoint). Caller must hold b.mu.
+func (b *Buffer) checkpointWALLocked(lsn int64) {
+	if b.wal == nil {
+		return
+	}
+	if err := b.wal.checkpoint(lsn); err != nil {
+		log.Printf("buffer: WAL checkpoint failed: %v", err)
+	}
+}
+
+// Checkpoint durably commits lsn as the highest WAL record a caller has
+// flushed through some path other than Flush/ForceFlush (i.e.
+// GetAndClear - see monitor.Monitor.flush), then reclaims any WAL segment
+// fully covered by it. Flush/ForceFlush don't need this: they already
+// checkpoint as part of a successful sink.Write.
+func (b *Buffer) Checkpoint(lsn int64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.checkpointWALLocked(lsn)
 }
 
 // GetEntries returns a copy of all buffered entries.
@@ -304,14 +450,19 @@ func (b *Buffer) Clear() {
 	b.entries = b.entries[:0]
 }
 
-// GetAndClear returns all entries and clears the buffer atomically.
-// Used for Redis processing where we handle persistence externally.
-func (b *Buffer) GetAndClear() []LogEntry {
+// GetAndClear returns all entries and the WAL LSN they're covered up to
+// (see WithWALCheckpointLSN), clearing the buffer atomically. Used for
+// Redis processing and the plain storage.Backend path (see
+// monitor.Monitor.flush), where persistence happens outside a Sink and so
+// can't checkpoint the WAL itself - the caller must call Checkpoint(lsn)
+// once it has confirmed that write is durable, or a crash before then will
+// correctly replay these entries again rather than lose them.
+func (b *Buffer) GetAndClear() ([]LogEntry, int64) {
 	b.mu.Lock()
 	defer b.mu.Unlock()
 
 	if len(b.entries) == 0 {
-		return nil
+		return nil, b.lastLSN
 	}
 
 	entries := b.entries
@@ -322,5 +473,5 @@ func (b *Buffer) GetAndClear() []LogEntry {
 	b.lastFlush = time.Now()
 	b.resetFlushTimer()
 
-	return entries
+	return entries, b.lastLSN
 }
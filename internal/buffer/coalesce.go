@@ -0,0 +1,87 @@
+package buffer
+
+// tryCoalesceLocked merges entry into the current tail entry when Coalesce
+// run-length deduplication applies: same window, and (if CoalesceGapMs is
+// set) no larger a gap than allowed since the run's last sample. Returns
+// true if entry was merged (the caller must not also append it). Caller
+// must hold b.mu.
+func (b *Buffer) tryCoalesceLocked(entry LogEntry) bool {
+	if len(b.entries) == 0 {
+		return false
+	}
+
+	tail := &b.entries[len(b.entries)-1]
+	if !sameWindow(*tail, entry) {
+		return false
+	}
+
+	gap := entry.UnixTime - runEndUnix(*tail)
+	if b.config.CoalesceGapMs > 0 && gap > b.config.CoalesceGapMs {
+		return false
+	}
+
+	mergeIntoRun(tail, entry)
+	return true
+}
+
+// sameWindow reports whether a and b represent the same foreground window,
+// the criterion for coalescing one sample into another.
+func sameWindow(a, b LogEntry) bool {
+	return a.ProcessName == b.ProcessName &&
+		a.WindowTitle == b.WindowTitle &&
+		a.WindowHandle == b.WindowHandle
+}
+
+// runEndUnix returns the unix-ms timestamp of entry's most recent sample,
+// whether or not it has already been coalesced into a run.
+func runEndUnix(entry LogEntry) int64 {
+	if entry.SampleCount > 1 {
+		return entry.EndUnixTime
+	}
+	return entry.UnixTime
+}
+
+// runSampleCount returns how many original samples entry represents.
+func runSampleCount(entry LogEntry) int {
+	if entry.SampleCount > 1 {
+		return entry.SampleCount
+	}
+	return 1
+}
+
+// runIntensitySum returns the sum of InputIntensity across entry's samples.
+func runIntensitySum(entry LogEntry) float32 {
+	if entry.SampleCount > 1 {
+		return entry.InputIntensitySum
+	}
+	return entry.InputIntensity
+}
+
+// runIdleMin returns the minimum InputIdleMs across entry's samples.
+func runIdleMin(entry LogEntry) int64 {
+	if entry.SampleCount > 1 {
+		return entry.InputIdleMin
+	}
+	return entry.InputIdleMs
+}
+
+// mergeIntoRun folds next into tail, turning tail into (or extending) a
+// coalesced range: UnixTime keeps marking the run's start, EndUnixTime
+// tracks its end, InputIntensity becomes the run's max, and
+// InputIntensitySum/InputIdleMin accumulate across every merged sample.
+func mergeIntoRun(tail *LogEntry, next LogEntry) {
+	sampleCount := runSampleCount(*tail) + runSampleCount(next)
+	intensitySum := runIntensitySum(*tail) + runIntensitySum(next)
+	idleMin := runIdleMin(*tail)
+	if nextIdleMin := runIdleMin(next); nextIdleMin < idleMin {
+		idleMin = nextIdleMin
+	}
+	if next.InputIntensity > tail.InputIntensity {
+		tail.InputIntensity = next.InputIntensity
+	}
+
+	tail.EndUnixTime = next.UnixTime
+	tail.SampleCount = sampleCount
+	tail.InputIntensitySum = intensitySum
+	tail.InputIdleMin = idleMin
+}
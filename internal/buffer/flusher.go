@@ -0,0 +1,246 @@
+package buffer
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+)
+
+// flusherIdleCheckInterval is how often the worker goroutine checks whether
+// Submit has gone quiet for long enough to trigger an idle flush.
+const flusherIdleCheckInterval = 1 * time.Second
+
+// flusherBaseBackoff/flusherMaxBackoff bound the retry backoff a Flusher
+// applies to a failing batch, same shape as httpsink's retry loop.
+const (
+	flusherBaseBackoff = 200 * time.Millisecond
+	flusherMaxBackoff  = 5 * time.Second
+)
+
+// FlusherConfig controls the background worker started by NewFlusher, on
+// top of the BufferConfig governing the Buffer it owns.
+type FlusherConfig struct {
+	BufferConfig
+
+	// QueueDepth bounds how many entries Submit can have queued for the
+	// worker goroutine before PressurePolicy applies. Defaults to
+	// BufferConfig.Capacity.
+	QueueDepth int
+
+	// MaxRetryDuration bounds how long a single batch flush keeps retrying
+	// with exponential backoff before giving up and reporting it via
+	// OnError. Zero means a single attempt, no retries.
+	MaxRetryDuration time.Duration
+
+	// OnError, if set, is invoked from the worker goroutine whenever a
+	// batch flush exhausts its retries. The batch stays in the Buffer (it
+	// is never cleared on failure), so it will be retried on the next
+	// trigger and is still covered by the WAL if one is configured.
+	OnError func(batch []LogEntry, err error)
+}
+
+// Flusher owns a Buffer and a background goroutine that flushes it on
+// capacity, FlushTimeout, and IdleThreshold triggers, so callers no longer
+// have to drive Flush/ForceFlush themselves. Submit is the only thing a
+// caller needs during normal operation; Close drains what's left on
+// shutdown.
+type Flusher struct {
+	buf  *Buffer
+	sink Sink
+	cfg  FlusherConfig
+
+	submitCh chan LogEntry
+	stopCh   chan struct{}
+	doneCh   chan struct{}
+
+	mu         sync.Mutex
+	lastSubmit time.Time
+}
+
+// NewFlusher builds a Flusher over a fresh Buffer(cfg.BufferConfig) and
+// starts its worker goroutine immediately.
+func NewFlusher(cfg FlusherConfig, sink Sink) *Flusher {
+	depth := cfg.QueueDepth
+	if depth <= 0 {
+		depth = cfg.Capacity
+	}
+	if depth <= 0 {
+		depth = 100
+	}
+
+	f := &Flusher{
+		buf: New(cfg.BufferConfig),
+		sink: &retryingSink{
+			inner:            sink,
+			maxRetryDuration: cfg.MaxRetryDuration,
+			onError:          cfg.OnError,
+		},
+		cfg:        cfg,
+		submitCh:   make(chan LogEntry, depth),
+		stopCh:     make(chan struct{}),
+		doneCh:     make(chan struct{}),
+		lastSubmit: time.Now(),
+	}
+	go f.run()
+	return f
+}
+
+// Submit hands entry to the worker goroutine, non-blocking up to
+// QueueDepth. Once the queue is full, cfg.PressurePolicy decides what
+// happens next, mirroring Buffer.Add under MaxCapacity pressure: Block
+// waits for room (until Close starts), DropOldest evicts the queue's oldest
+// pending entry to make room, and DropNewest discards entry.
+func (f *Flusher) Submit(entry LogEntry) {
+	f.mu.Lock()
+	f.lastSubmit = time.Now()
+	f.mu.Unlock()
+
+	switch f.cfg.PressurePolicy {
+	case DropNewest:
+		select {
+		case f.submitCh <- entry:
+		default:
+		}
+
+	case DropOldest:
+		select {
+		case f.submitCh <- entry:
+		default:
+			select {
+			case <-f.submitCh:
+			default:
+			}
+			select {
+			case f.submitCh <- entry:
+			default:
+			}
+		}
+
+	default: // Block
+		select {
+		case f.submitCh <- entry:
+		case <-f.stopCh:
+		}
+	}
+}
+
+// run is the worker goroutine: it owns the Buffer exclusively and reacts to
+// Submit, the Buffer's own FlushTimeout timer, and idle-based flushing.
+func (f *Flusher) run() {
+	defer close(f.doneCh)
+
+	idleTicker := time.NewTicker(flusherIdleCheckInterval)
+	defer idleTicker.Stop()
+
+	for {
+		select {
+		case entry := <-f.submitCh:
+			if f.buf.Add(entry) {
+				f.flush()
+			}
+
+		case <-f.buf.FlushChannel():
+			f.flush()
+
+		case <-idleTicker.C:
+			f.checkIdle()
+
+		case <-f.stopCh:
+			return
+		}
+	}
+}
+
+// checkIdle flushes whatever is buffered once Submit has gone quiet for at
+// least IdleThreshold, instead of waiting out the rest of FlushTimeout.
+func (f *Flusher) checkIdle() {
+	f.mu.Lock()
+	idle := time.Since(f.lastSubmit)
+	f.mu.Unlock()
+
+	if f.cfg.IdleThreshold > 0 && idle >= f.cfg.IdleThreshold && f.buf.Len() > 0 {
+		f.flush()
+	}
+}
+
+// flush runs one flush attempt (with whatever retrying the sink wraps it
+// in). Failures are already reported through cfg.OnError by retryingSink;
+// this only falls back to logging when the caller didn't set one.
+func (f *Flusher) flush() {
+	if err := f.buf.Flush(f.sink); err != nil && f.cfg.OnError == nil {
+		log.Printf("buffer: flusher: batch flush failed: %v", err)
+	}
+}
+
+// Close stops the worker and makes one final attempt to flush everything
+// left buffered (including anything still sitting in the submit queue),
+// bounded by ctx. It returns how many entries remain unflushed - either
+// because ctx expired mid-retry or the sink kept failing - so the caller
+// can fall back to WAL-based recovery for them instead of losing them.
+func (f *Flusher) Close(ctx context.Context) (unflushed int, err error) {
+	close(f.stopCh)
+	<-f.doneCh
+
+drain:
+	for {
+		select {
+		case entry := <-f.submitCh:
+			f.buf.Add(entry)
+		default:
+			break drain
+		}
+	}
+
+	if ferr := f.buf.ForceFlushContext(ctx, f.sink); ferr != nil {
+		return f.buf.Len(), ferr
+	}
+	return 0, nil
+}
+
+// retryingSink wraps another Sink, retrying a failing Write with
+// exponential backoff until it succeeds or maxRetryDuration elapses, then
+// reporting the final error through onError if set.
+type retryingSink struct {
+	inner            Sink
+	maxRetryDuration time.Duration
+	onError          func(batch []LogEntry, err error)
+}
+
+func (r *retryingSink) Name() string { return r.inner.Name() }
+
+func (r *retryingSink) Write(ctx context.Context, entries []LogEntry) error {
+	backoff := flusherBaseBackoff
+	deadline := time.Now().Add(r.maxRetryDuration)
+
+	var lastErr error
+	for {
+		lastErr = r.inner.Write(ctx, entries)
+		if lastErr == nil {
+			return nil
+		}
+		if r.maxRetryDuration <= 0 || !time.Now().Add(backoff).Before(deadline) {
+			break
+		}
+
+		select {
+		case <-ctx.Done():
+			lastErr = ctx.Err()
+			if r.onError != nil {
+				r.onError(entries, lastErr)
+			}
+			return lastErr
+		case <-time.After(backoff):
+		}
+
+		backoff *= 2
+		if backoff > flusherMaxBackoff {
+			backoff = flusherMaxBackoff
+		}
+	}
+
+	if r.onError != nil {
+		r.onError(entries, lastErr)
+	}
+	return lastErr
+}
@@ -0,0 +1,133 @@
+// Package httpsink implements a buffer.Sink that POSTs gzip-compressed
+// newline-delimited JSON batches to a central collector, with retry/backoff.
+package httpsink
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"mnemosyne/internal/buffer"
+)
+
+// Config controls the target endpoint and retry behavior.
+type Config struct {
+	URL     string
+	Headers map[string]string
+	Client  *http.Client // optional; defaults to a 10s-timeout client
+
+	MaxRetries  int           // additional attempts after the first (default 3; negative disables retries)
+	BaseBackoff time.Duration // delay before the first retry (default 200ms, doubles each attempt)
+	MaxBackoff  time.Duration // backoff ceiling (default 5s)
+}
+
+// Sink POSTs each batch as gzip-compressed NDJSON.
+type Sink struct {
+	cfg    Config
+	client *http.Client
+}
+
+// New builds a Sink posting to cfg.URL.
+func New(cfg Config) *Sink {
+	client := cfg.Client
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	return &Sink{cfg: cfg, client: client}
+}
+
+func (s *Sink) Name() string {
+	return "http:" + s.cfg.URL
+}
+
+func (s *Sink) Write(ctx context.Context, entries []buffer.LogEntry) error {
+	payload, err := encodeBatch(entries)
+	if err != nil {
+		return fmt.Errorf("httpsink: failed to encode batch: %w", err)
+	}
+
+	backoff := s.cfg.BaseBackoff
+	if backoff <= 0 {
+		backoff = 200 * time.Millisecond
+	}
+	maxBackoff := s.cfg.MaxBackoff
+	if maxBackoff <= 0 {
+		maxBackoff = 5 * time.Second
+	}
+	maxRetries := s.cfg.MaxRetries
+	if maxRetries == 0 {
+		maxRetries = 3
+	}
+	attempts := maxRetries + 1
+	if attempts < 1 {
+		attempts = 1
+	}
+
+	var lastErr error
+	for attempt := 0; attempt < attempts; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(backoff):
+			}
+			backoff *= 2
+			if backoff > maxBackoff {
+				backoff = maxBackoff
+			}
+		}
+
+		if err := s.post(ctx, payload); err != nil {
+			lastErr = err
+			continue
+		}
+		return nil
+	}
+
+	return fmt.Errorf("httpsink: giving up after %d attempt(s): %w", attempts, lastErr)
+}
+
+func (s *Sink) post(ctx context.Context, payload []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.cfg.URL, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-ndjson")
+	req.Header.Set("Content-Encoding", "gzip")
+	for k, v := range s.cfg.Headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+func encodeBatch(entries []buffer.LogEntry) ([]byte, error) {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+
+	enc := json.NewEncoder(gz)
+	for _, entry := range entries {
+		if err := enc.Encode(entry); err != nil {
+			gz.Close()
+			return nil, err
+		}
+	}
+
+	if err := gz.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
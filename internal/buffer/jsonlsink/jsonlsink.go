@@ -0,0 +1,122 @@
+// Package jsonlsink implements a buffer.Sink that appends newline-delimited
+// JSON to a local file, rotating it by size and/or age.
+package jsonlsink
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"mnemosyne/internal/buffer"
+)
+
+// Config controls where the sink writes and when it rotates.
+type Config struct {
+	Path string // destination file path, e.g. /var/log/mnemosyne/events.jsonl
+
+	MaxSizeBytes int64         // rotate before a write once the file has reached this size; one batch may run past it (0 disables size-based rotation)
+	MaxAge       time.Duration // rotate once the current file is older than this (0 disables time-based rotation)
+}
+
+// Sink appends one JSON object per LogEntry per line.
+type Sink struct {
+	cfg Config
+
+	mu       sync.Mutex
+	file     *os.File
+	size     int64
+	openedAt time.Time
+}
+
+// New opens (or creates) the file at cfg.Path for appending.
+func New(cfg Config) (*Sink, error) {
+	s := &Sink{cfg: cfg}
+	if err := s.openCurrent(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *Sink) Name() string {
+	return "jsonl:" + s.cfg.Path
+}
+
+func (s *Sink) Write(ctx context.Context, entries []buffer.LogEntry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.shouldRotate() {
+		if err := s.rotate(); err != nil {
+			return err
+		}
+	}
+
+	for _, entry := range entries {
+		line, err := json.Marshal(entry)
+		if err != nil {
+			return fmt.Errorf("jsonlsink: failed to marshal entry: %w", err)
+		}
+		line = append(line, '\n')
+
+		n, err := s.file.Write(line)
+		if err != nil {
+			return fmt.Errorf("jsonlsink: failed to write entry: %w", err)
+		}
+		s.size += int64(n)
+	}
+
+	return s.file.Sync()
+}
+
+// Close closes the underlying file handle.
+func (s *Sink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.file.Close()
+}
+
+func (s *Sink) shouldRotate() bool {
+	if s.cfg.MaxSizeBytes > 0 && s.size >= s.cfg.MaxSizeBytes {
+		return true
+	}
+	if s.cfg.MaxAge > 0 && time.Since(s.openedAt) >= s.cfg.MaxAge {
+		return true
+	}
+	return false
+}
+
+func (s *Sink) openCurrent() error {
+	f, err := os.OpenFile(s.cfg.Path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("jsonlsink: failed to open %q: %w", s.cfg.Path, err)
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return fmt.Errorf("jsonlsink: failed to stat %q: %w", s.cfg.Path, err)
+	}
+
+	s.file = f
+	s.size = info.Size()
+	s.openedAt = time.Now()
+	return nil
+}
+
+// rotate closes the current file, moves it aside with a timestamp suffix,
+// and opens a fresh file at the configured path.
+func (s *Sink) rotate() error {
+	if err := s.file.Close(); err != nil {
+		return fmt.Errorf("jsonlsink: failed to close before rotation: %w", err)
+	}
+
+	rotated := fmt.Sprintf("%s.%d", s.cfg.Path, time.Now().UnixNano())
+	if err := os.Rename(s.cfg.Path, rotated); err != nil {
+		return fmt.Errorf("jsonlsink: failed to rotate %q: %w", s.cfg.Path, err)
+	}
+
+	return s.openCurrent()
+}
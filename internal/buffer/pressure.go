@@ -0,0 +1,152 @@
+package buffer
+
+import (
+	"sync"
+	"time"
+)
+
+// PressureReading is a point-in-time snapshot of OS resource pressure (see
+// sysinfo.SystemPressure), fed into Buffer by whoever owns the
+// sysinfo.Provider - mirroring how monitor.Monitor enriches LogEntry from
+// sysinfo rather than Buffer depending on sysinfo directly.
+type PressureReading struct {
+	OnBattery       bool
+	DiskQueueLength float64
+	CPUPercent      float64
+	Supported       bool // false when the platform has no pressure probe
+}
+
+// AdaptivePolicy decides how long Buffer should defer its next flush beyond
+// the effective flush timeout (see adaptive.go), and whether that flush
+// should relax durability guarantees, given the latest PressureReading.
+// Buffer.SetPressureReading feeds readings to whatever policy
+// BufferConfig.AdaptivePolicy names; tests can inject a fake to drive
+// deterministic pressure without real battery/disk/CPU state.
+type AdaptivePolicy interface {
+	// Decide returns the extra time to wait beyond the baseline flush
+	// timeout (zero means "flush on schedule") and whether the upcoming
+	// flush should use relaxed durability (e.g. SQLite's
+	// PRAGMA synchronous=NORMAL instead of FULL).
+	Decide(reading PressureReading) (deferral time.Duration, relaxedDurability bool)
+}
+
+// PressureEvent records one change in AdaptivePolicy's decision, for a
+// caller (see monitor.Monitor) to persist via a storage.PressureEventRecorder
+// for later analysis.
+type PressureEvent struct {
+	UnixTime          int64
+	OnBattery         bool
+	DiskQueueLength   float64
+	CPUPercent        float64
+	DeferralMs        int64
+	RelaxedDurability bool
+}
+
+// SystemPressurePolicy is the default AdaptivePolicy: on AC power with an
+// idle disk it defers flushes only as scheduled; on battery, or once the
+// disk queue crosses DiskQueueHighWatermark, it coalesces aggressively up to
+// MaxDeferral and asks for relaxed durability. The gap between
+// DiskQueueHighWatermark and DiskQueueLowWatermark is a hysteresis band so a
+// queue length oscillating around one threshold doesn't flip the decision
+// every reading.
+type SystemPressurePolicy struct {
+	// MaxDeferral bounds how long a flush can be pushed out under sustained
+	// pressure.
+	MaxDeferral time.Duration
+
+	// DiskQueueHighWatermark is the Avg. Disk Queue Length at or above which
+	// the disk is considered under pressure.
+	DiskQueueHighWatermark float64
+
+	// DiskQueueLowWatermark is the Avg. Disk Queue Length the disk has to
+	// drop back to before pressure is considered to have subsided.
+	DiskQueueLowWatermark float64
+
+	mu            sync.Mutex
+	diskUnderLoad bool
+}
+
+// Default watermarks/ceiling for NewSystemPressurePolicy.
+const (
+	defaultDiskQueueHighWatermark = 2.0
+	defaultDiskQueueLowWatermark  = 0.5
+	defaultMaxDeferral            = 2 * time.Minute
+)
+
+// NewSystemPressurePolicy returns a SystemPressurePolicy with sane defaults.
+func NewSystemPressurePolicy() *SystemPressurePolicy {
+	return &SystemPressurePolicy{
+		MaxDeferral:            defaultMaxDeferral,
+		DiskQueueHighWatermark: defaultDiskQueueHighWatermark,
+		DiskQueueLowWatermark:  defaultDiskQueueLowWatermark,
+	}
+}
+
+// Decide implements AdaptivePolicy. An unsupported reading (no pressure
+// probe on this platform) always reports "no pressure" rather than
+// defaulting to aggressive coalescing from a guess.
+func (p *SystemPressurePolicy) Decide(reading PressureReading) (time.Duration, bool) {
+	if !reading.Supported {
+		return 0, false
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	switch {
+	case p.diskUnderLoad && reading.DiskQueueLength <= p.DiskQueueLowWatermark:
+		p.diskUnderLoad = false
+	case !p.diskUnderLoad && reading.DiskQueueLength >= p.DiskQueueHighWatermark:
+		p.diskUnderLoad = true
+	}
+
+	if !reading.OnBattery && !p.diskUnderLoad {
+		return 0, false
+	}
+	return p.MaxDeferral, true
+}
+
+// SetPressureReading feeds the latest system-pressure snapshot to
+// config.AdaptivePolicy and re-times the flush timer if its decision
+// changed. Returns the resulting event and true if the policy's decision
+// (deferral/relaxedDurability) differs from the previous reading - the
+// caller is expected to persist that via a storage.PressureEventRecorder. A
+// no-op returning (PressureEvent{}, false) if AdaptivePolicy is unset.
+func (b *Buffer) SetPressureReading(reading PressureReading) (PressureEvent, bool) {
+	if b.config.AdaptivePolicy == nil {
+		return PressureEvent{}, false
+	}
+
+	deferral, relaxed := b.config.AdaptivePolicy.Decide(reading)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	changed := deferral != b.pressureDeferral || relaxed != b.pressureRelaxed
+	b.pressureDeferral = deferral
+	b.pressureRelaxed = relaxed
+	b.resetFlushTimer()
+
+	if !changed {
+		return PressureEvent{}, false
+	}
+
+	return PressureEvent{
+		UnixTime:          time.Now().UnixMilli(),
+		OnBattery:         reading.OnBattery,
+		DiskQueueLength:   reading.DiskQueueLength,
+		CPUPercent:        reading.CPUPercent,
+		DeferralMs:        deferral.Milliseconds(),
+		RelaxedDurability: relaxed,
+	}, true
+}
+
+// PressureState returns the deferral/relaxed-durability decision currently
+// in effect, as last set by SetPressureReading. Used by a caller (see
+// monitor.Monitor.flush) that flushes straight through a storage.Backend
+// instead of a Sink, and so needs to mark relaxed durability on ctx itself.
+func (b *Buffer) PressureState() (deferral time.Duration, relaxedDurability bool) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.pressureDeferral, b.pressureRelaxed
+}
@@ -0,0 +1,228 @@
+package buffer
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"strings"
+)
+
+// relaxedDurabilityKey is the context key WithRelaxedDurability/
+// RelaxedDurability use to mark a flush as allowed to trade durability for
+// fewer fsyncs, set by Buffer.flushLocked under system pressure (see
+// pressure.go).
+type relaxedDurabilityKey struct{}
+
+// WithRelaxedDurability marks ctx so a Sink that supports it (SQLiteSink)
+// can relax its durability guarantees for this one flush.
+func WithRelaxedDurability(ctx context.Context) context.Context {
+	return context.WithValue(ctx, relaxedDurabilityKey{}, true)
+}
+
+// RelaxedDurability reports whether ctx was marked via
+// WithRelaxedDurability.
+func RelaxedDurability(ctx context.Context) bool {
+	relaxed, _ := ctx.Value(relaxedDurabilityKey{}).(bool)
+	return relaxed
+}
+
+// walCheckpointLSNKey is the context key WithWALCheckpointLSN/
+// WALCheckpointLSN use to tell a Sink that supports it (SQLiteSink) the WAL
+// LSN this flush covers, so it can record it in the same transaction as the
+// entries it inserts (see wal.go, Buffer.flushLocked).
+type walCheckpointLSNKey struct{}
+
+// WithWALCheckpointLSN marks ctx with the highest WAL LSN (see wal.go) this
+// flush covers.
+func WithWALCheckpointLSN(ctx context.Context, lsn int64) context.Context {
+	return context.WithValue(ctx, walCheckpointLSNKey{}, lsn)
+}
+
+// WALCheckpointLSN returns the LSN set via WithWALCheckpointLSN, if any.
+func WALCheckpointLSN(ctx context.Context) (int64, bool) {
+	lsn, ok := ctx.Value(walCheckpointLSNKey{}).(int64)
+	return lsn, ok
+}
+
+// Sink is a flush destination for a batch of buffered LogEntry values.
+// Buffer.Flush/ForceFlush write through whatever Sink they're given, so the
+// buffer itself doesn't need to know about SQLite, HTTP collectors, or any
+// other destination (see the jsonlsink/httpsink subpackages, and MultiSink
+// below for fanning out to several at once).
+type Sink interface {
+	Write(ctx context.Context, entries []LogEntry) error
+	Name() string
+}
+
+// SQLiteSink is the original raw_events insert behavior, now behind the
+// Sink interface instead of hard-coded into Buffer.Flush.
+type SQLiteSink struct {
+	db *sql.DB
+}
+
+// NewSQLiteSink wraps an existing *sql.DB as a Sink.
+func NewSQLiteSink(db *sql.DB) *SQLiteSink {
+	return &SQLiteSink{db: db}
+}
+
+func (s *SQLiteSink) Name() string {
+	return "sqlite"
+}
+
+func (s *SQLiteSink) Write(ctx context.Context, entries []LogEntry) error {
+	if RelaxedDurability(ctx) {
+		// Under system pressure (see buffer.AdaptivePolicy), trade durability
+		// for fewer fsyncs: NORMAL only flushes the WAL at checkpoints
+		// instead of every commit. Restored after so a quiet period between
+		// pressure windows goes back to full durability.
+		if _, err := s.db.ExecContext(ctx, "PRAGMA synchronous=NORMAL"); err != nil {
+			log.Printf("buffer: failed to relax synchronous pragma: %v", err)
+		} else {
+			defer func() {
+				if _, err := s.db.ExecContext(ctx, "PRAGMA synchronous=FULL"); err != nil {
+					log.Printf("buffer: failed to restore synchronous pragma: %v", err)
+				}
+			}()
+		}
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	// Record the WAL checkpoint in the same transaction as the inserts
+	// below, so a crash can't commit one without the other: on restart,
+	// replay compares the WAL's LSNs against this row (see
+	// storage.WALCheckpointStore) and only redoes what didn't make it in.
+	if lsn, ok := WALCheckpointLSN(ctx); ok {
+		if _, err := tx.ExecContext(ctx, `
+			INSERT INTO wal_checkpoint (id, lsn) VALUES (1, ?)
+			ON CONFLICT(id) DO UPDATE SET lsn = excluded.lsn
+		`, lsn); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("failed to record WAL checkpoint: %w", err)
+		}
+	}
+
+	stmt, err := tx.PrepareContext(ctx, `
+		INSERT INTO raw_events
+		(session_uuid, timestamp_utc, unix_time, process_name, window_title, window_hwnd, input_idle_ms, input_intensity, screenshot_path,
+		 end_unix_time, sample_count, input_intensity_sum, input_idle_min, screenshot_hash, host_uuid,
+		 keystrokes, mouse_clicks, mouse_travel_px)
+		VALUES (?, datetime('now'), ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`)
+	if err != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to prepare statement: %w", err)
+	}
+	defer stmt.Close()
+
+	for _, entry := range entries {
+		if _, err := stmt.ExecContext(ctx,
+			entry.SessionUUID,
+			entry.UnixTime,
+			entry.ProcessName,
+			entry.WindowTitle,
+			entry.WindowHandle,
+			entry.InputIdleMs,
+			entry.InputIntensity,
+			entry.ScreenshotPath,
+			runEndUnix(entry),
+			runSampleCount(entry),
+			runIntensitySum(entry),
+			runIdleMin(entry),
+			entry.ScreenshotHash,
+			entry.HostUUID,
+			entry.Keystrokes,
+			entry.MouseClicks,
+			entry.MouseTravelPx,
+		); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("failed to insert entry: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// FailurePolicy controls how MultiSink reacts when one of its sinks errors.
+type FailurePolicy int
+
+const (
+	// FailAll reports the batch as unflushed if any sink fails.
+	FailAll FailurePolicy = iota
+	// BestEffort reports success as long as at least one sink succeeds,
+	// logging the rest.
+	BestEffort
+	// PrimaryOnly only requires the first sink to succeed; failures in the
+	// others are logged but never fail the batch.
+	PrimaryOnly
+)
+
+// MultiSink fans a batch out to several sinks, treating the batch as
+// flushed according to policy.
+type MultiSink struct {
+	sinks  []Sink
+	policy FailurePolicy
+}
+
+// NewMultiSink builds a MultiSink over sinks (first one is "primary" under
+// PrimaryOnly).
+func NewMultiSink(policy FailurePolicy, sinks ...Sink) *MultiSink {
+	return &MultiSink{sinks: sinks, policy: policy}
+}
+
+func (m *MultiSink) Name() string {
+	names := make([]string, len(m.sinks))
+	for i, s := range m.sinks {
+		names[i] = s.Name()
+	}
+	return "multi(" + strings.Join(names, ",") + ")"
+}
+
+func (m *MultiSink) Write(ctx context.Context, entries []LogEntry) error {
+	if len(m.sinks) == 0 {
+		return nil
+	}
+
+	switch m.policy {
+	case PrimaryOnly:
+		if err := m.sinks[0].Write(ctx, entries); err != nil {
+			return fmt.Errorf("primary sink %q failed: %w", m.sinks[0].Name(), err)
+		}
+		for _, s := range m.sinks[1:] {
+			if err := s.Write(ctx, entries); err != nil {
+				log.Printf("buffer: best-effort sink %q failed: %v", s.Name(), err)
+			}
+		}
+		return nil
+
+	case BestEffort:
+		var failures []string
+		for _, s := range m.sinks {
+			if err := s.Write(ctx, entries); err != nil {
+				failures = append(failures, fmt.Sprintf("%s: %v", s.Name(), err))
+			}
+		}
+		if len(failures) == len(m.sinks) {
+			return fmt.Errorf("all sinks failed: %s", strings.Join(failures, "; "))
+		}
+		if len(failures) > 0 {
+			log.Printf("buffer: %d/%d sinks failed: %s", len(failures), len(m.sinks), strings.Join(failures, "; "))
+		}
+		return nil
+
+	default: // FailAll
+		for _, s := range m.sinks {
+			if err := s.Write(ctx, entries); err != nil {
+				return fmt.Errorf("sink %q failed: %w", s.Name(), err)
+			}
+		}
+		return nil
+	}
+}
@@ -0,0 +1,453 @@
+package buffer
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// walMagic marks the start of a framed record, so a reader can tell a
+// corrupted length prefix from a legitimately truncated tail.
+const walMagic uint32 = 0x4D4E454D // "MNEM"
+
+// walHeaderLen is magic(4) + lsn(8) + length(4) + crc32c(4).
+const walHeaderLen = 20
+
+// crc32cTable is the Castagnoli polynomial - the same one SQLite's own WAL
+// uses, and the one most CPUs have a hardware instruction for, unlike the
+// IEEE polynomial crc32.ChecksumIEEE defaults to.
+var crc32cTable = crc32.MakeTable(crc32.Castagnoli)
+
+// maxSegmentBytes bounds how large a single WAL segment grows before
+// append starts a new one, so Checkpoint has something smaller than "the
+// whole WAL" to reclaim once old segments fall behind the checkpoint LSN.
+const maxSegmentBytes = 8 << 20 // 8 MiB
+
+// wal is a segmented, LSN-ordered write-ahead log of LogEntry values.
+// Buffer.Add appends a record here synchronously before returning, so a
+// crash between ticks never loses anything that made it into the buffer -
+// only entries never Add()-ed in the first place, which is unavoidable by
+// definition. Segments let Checkpoint reclaim disk space incrementally
+// (delete whole files once their LSNs are durably committed elsewhere - see
+// storage.WALCheckpointStore) instead of rewriting or deleting the entire
+// log on every flush.
+type wal struct {
+	mu       sync.Mutex
+	dir      string
+	prefix   string // filepath.Base(path); segments are named "<prefix>.<startLSN>"
+	file     *os.File
+	segStart int64 // LSN of the first record in the currently-open segment
+	segBytes int64 // bytes written to the currently-open segment so far
+	nextLSN  int64
+}
+
+// segmentPath returns the on-disk path for the segment starting at
+// startLSN. LSNs are zero-padded so a lexical directory listing already
+// sorts oldest-first, matching listSegments' explicit numeric sort.
+func segmentPath(dir, prefix string, startLSN int64) string {
+	return filepath.Join(dir, fmt.Sprintf("%s.%020d", prefix, startLSN))
+}
+
+// listSegments returns the starting LSN of every existing segment for
+// prefix in dir, ascending. A missing dir is not an error - there's simply
+// nothing to list yet.
+func listSegments(dir, prefix string) ([]int64, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	want := prefix + "."
+	var lsns []int64
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasPrefix(e.Name(), want) {
+			continue
+		}
+		lsn, err := strconv.ParseInt(strings.TrimPrefix(e.Name(), want), 10, 64)
+		if err != nil {
+			continue
+		}
+		lsns = append(lsns, lsn)
+	}
+	sort.Slice(lsns, func(i, j int) bool { return lsns[i] < lsns[j] })
+	return lsns, nil
+}
+
+// openWAL opens (or starts) the segmented WAL rooted at path, resuming LSN
+// numbering after whatever the newest surviving segment last recorded. Any
+// trailing bytes past the last valid record in that segment - the
+// signature of a crash mid-append - are truncated away first, so a fresh
+// append can't strand a valid record behind unreachable garbage.
+func openWAL(path string) (*wal, error) {
+	dir := filepath.Dir(path)
+	prefix := filepath.Base(path)
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create WAL directory %q: %w", dir, err)
+	}
+
+	segments, err := listSegments(dir, prefix)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list WAL segments: %w", err)
+	}
+
+	w := &wal{dir: dir, prefix: prefix}
+	if len(segments) > 0 {
+		w.segStart = segments[len(segments)-1]
+	}
+
+	segPath := segmentPath(dir, prefix, w.segStart)
+	records, validBytes, err := readSegment(segPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read WAL segment %q: %w", segPath, err)
+	}
+	if len(records) > 0 {
+		w.nextLSN = records[len(records)-1].LSN + 1
+	} else {
+		w.nextLSN = w.segStart
+	}
+	if err := os.Truncate(segPath, validBytes); err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to truncate trailing garbage from WAL segment %q: %w", segPath, err)
+	}
+
+	f, err := os.OpenFile(segPath, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open WAL segment %q: %w", segPath, err)
+	}
+	w.file = f
+	w.segBytes = validBytes
+	return w, nil
+}
+
+// append writes one framed record (magic, LSN, length, CRC32C, payload),
+// fsyncs it, and returns the LSN it was assigned. Once the active segment
+// crosses maxSegmentBytes, it rotates to a fresh one so Checkpoint later has
+// whole files it can reclaim.
+func (w *wal) append(entry LogEntry) (int64, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	payload := encodeLogEntry(entry)
+	lsn := w.nextLSN
+
+	header := make([]byte, walHeaderLen)
+	binary.BigEndian.PutUint32(header[0:4], walMagic)
+	binary.BigEndian.PutUint64(header[4:12], uint64(lsn))
+	binary.BigEndian.PutUint32(header[12:16], uint32(len(payload)))
+	binary.BigEndian.PutUint32(header[16:20], crc32.Checksum(payload, crc32cTable))
+
+	if _, err := w.file.Write(header); err != nil {
+		return 0, fmt.Errorf("failed to write WAL record header: %w", err)
+	}
+	if _, err := w.file.Write(payload); err != nil {
+		return 0, fmt.Errorf("failed to write WAL record payload: %w", err)
+	}
+	if err := w.file.Sync(); err != nil {
+		return 0, fmt.Errorf("failed to sync WAL: %w", err)
+	}
+
+	w.nextLSN++
+	w.segBytes += int64(len(header) + len(payload))
+	if w.segBytes >= maxSegmentBytes {
+		if err := w.rotateLocked(); err != nil {
+			return 0, err
+		}
+	}
+	return lsn, nil
+}
+
+// rotateLocked closes the active segment and starts a fresh, empty one at
+// the current LSN. Unlike checkpoint, this never deletes anything - it just
+// bounds how large one segment can grow. Caller must hold w.mu.
+func (w *wal) rotateLocked() error {
+	if err := w.file.Close(); err != nil {
+		return fmt.Errorf("failed to close WAL segment before rotation: %w", err)
+	}
+
+	w.segStart = w.nextLSN
+	f, err := os.OpenFile(segmentPath(w.dir, w.prefix, w.segStart), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open new WAL segment: %w", err)
+	}
+	w.file = f
+	w.segBytes = 0
+	return nil
+}
+
+// checkpoint deletes every WAL segment whose records are all <= lsn - i.e.
+// fully covered by a checkpoint the caller has already durably recorded
+// elsewhere (see Buffer.Checkpoint / storage.WALCheckpointStore). The
+// currently-open segment is never deleted, even if everything written to it
+// so far is <= lsn, since it's still being appended to.
+func (w *wal) checkpoint(lsn int64) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	segments, err := listSegments(w.dir, w.prefix)
+	if err != nil {
+		return fmt.Errorf("failed to list WAL segments: %w", err)
+	}
+
+	for i, start := range segments {
+		if start == w.segStart {
+			break // never delete the active segment
+		}
+		upperBound := segments[i+1] - 1 // next segment's first LSN is exclusive
+		if upperBound > lsn {
+			break // this segment (and every later one) still has unchecked data
+		}
+		path := segmentPath(w.dir, w.prefix, start)
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove checkpointed WAL segment %q: %w", path, err)
+		}
+	}
+	return nil
+}
+
+func (w *wal) close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.file.Close()
+}
+
+// encodeLogEntry serializes a LogEntry into a compact binary payload: fixed-
+// width numeric fields followed by length-prefixed variable fields. Every
+// persisted field is covered except ScreenshotData, which is intentionally
+// omitted - screenshots are large and already ephemeral by design (see
+// monitor's "RAM" placeholder path), and not worth the WAL's per-entry fsync
+// cost. Everything else (HostUUID, ScreenshotHash, the process-stats and
+// input-hook enrichment, and the coalesce run-length fields) has to survive
+// a crash/replay round-trip or recovery silently discards it - a coalesced
+// run's SampleCount/InputIntensitySum/InputIdleMin/EndUnixTime in particular.
+func encodeLogEntry(entry LogEntry) []byte {
+	buf := make([]byte, 0, encodedFixedLen+len(entry.SessionUUID)+len(entry.HostUUID)+len(entry.ProcessName)+len(entry.WindowTitle)+len(entry.ScreenshotPath))
+
+	var num [8]byte
+	putUint64 := func(v uint64) {
+		binary.BigEndian.PutUint64(num[:], v)
+		buf = append(buf, num[:8]...)
+	}
+	putUint32 := func(v uint32) {
+		binary.BigEndian.PutUint32(num[:4], v)
+		buf = append(buf, num[:4]...)
+	}
+	putFloat32 := func(v float32) {
+		putUint32(math.Float32bits(v))
+	}
+	putString := func(s string) {
+		binary.BigEndian.PutUint16(num[:2], uint16(len(s)))
+		buf = append(buf, num[:2]...)
+		buf = append(buf, s...)
+	}
+
+	putUint64(uint64(entry.UnixTime))
+	putUint64(uint64(entry.WindowHandle))
+	putUint64(uint64(entry.InputIdleMs))
+	putFloat32(entry.InputIntensity)
+	putUint64(entry.ScreenshotHash)
+
+	putFloat32(entry.CPUPercent)
+	putUint64(entry.WorkingSetRSS)
+	putUint64(entry.IOReadBytes)
+	putUint64(entry.IOWriteBytes)
+	putUint32(entry.HandleCount)
+
+	putUint32(entry.Keystrokes)
+	putUint32(entry.MouseClicks)
+	putUint64(entry.MouseTravelPx)
+
+	putUint64(uint64(entry.EndUnixTime))
+	putUint64(uint64(entry.SampleCount))
+	putFloat32(entry.InputIntensitySum)
+	putUint64(uint64(entry.InputIdleMin))
+
+	putString(entry.SessionUUID)
+	putString(entry.HostUUID)
+	putString(entry.ProcessName)
+	putString(entry.WindowTitle)
+	putString(entry.ScreenshotPath)
+
+	return buf
+}
+
+const encodedFixedLen = 8 + 8 + 8 + 4 + 8 + 4 + 8 + 8 + 8 + 4 + 4 + 4 + 8 + 8 + 8 + 4 + 8
+
+func decodeLogEntry(payload []byte) (LogEntry, error) {
+	var entry LogEntry
+	if len(payload) < encodedFixedLen {
+		return entry, fmt.Errorf("payload too short: %d bytes", len(payload))
+	}
+
+	pos := 0
+	getUint64 := func() uint64 {
+		v := binary.BigEndian.Uint64(payload[pos : pos+8])
+		pos += 8
+		return v
+	}
+	getUint32 := func() uint32 {
+		v := binary.BigEndian.Uint32(payload[pos : pos+4])
+		pos += 4
+		return v
+	}
+	getFloat32 := func() float32 {
+		return math.Float32frombits(getUint32())
+	}
+
+	entry.UnixTime = int64(getUint64())
+	entry.WindowHandle = int64(getUint64())
+	entry.InputIdleMs = int64(getUint64())
+	entry.InputIntensity = getFloat32()
+	entry.ScreenshotHash = getUint64()
+
+	entry.CPUPercent = getFloat32()
+	entry.WorkingSetRSS = getUint64()
+	entry.IOReadBytes = getUint64()
+	entry.IOWriteBytes = getUint64()
+	entry.HandleCount = getUint32()
+
+	entry.Keystrokes = getUint32()
+	entry.MouseClicks = getUint32()
+	entry.MouseTravelPx = getUint64()
+
+	entry.EndUnixTime = int64(getUint64())
+	entry.SampleCount = int(getUint64())
+	entry.InputIntensitySum = getFloat32()
+	entry.InputIdleMin = int64(getUint64())
+
+	readString := func() (string, error) {
+		if pos+2 > len(payload) {
+			return "", fmt.Errorf("truncated string length prefix")
+		}
+		n := int(binary.BigEndian.Uint16(payload[pos : pos+2]))
+		pos += 2
+		if pos+n > len(payload) {
+			return "", fmt.Errorf("truncated string payload")
+		}
+		s := string(payload[pos : pos+n])
+		pos += n
+		return s, nil
+	}
+
+	var err error
+	if entry.SessionUUID, err = readString(); err != nil {
+		return entry, err
+	}
+	if entry.HostUUID, err = readString(); err != nil {
+		return entry, err
+	}
+	if entry.ProcessName, err = readString(); err != nil {
+		return entry, err
+	}
+	if entry.WindowTitle, err = readString(); err != nil {
+		return entry, err
+	}
+	if entry.ScreenshotPath, err = readString(); err != nil {
+		return entry, err
+	}
+
+	return entry, nil
+}
+
+// WALRecord pairs a recovered LogEntry with the LSN it was written at, so a
+// caller can compare against a durable checkpoint (see
+// storage.WALCheckpointStore) before deciding what actually still needs
+// replaying.
+type WALRecord struct {
+	LSN   int64
+	Entry LogEntry
+}
+
+// readSegment replays one segment file in order, stopping (without
+// returning an error) at the first truncated or corrupt record - that's
+// exactly what a crash mid-write looks like: everything before it is still
+// valid and worth replaying, and nothing after it can be trusted anyway. It
+// also returns the byte offset immediately past the last valid record, so
+// openWAL can truncate away any trailing garbage before resuming appends. A
+// missing file is not an error; it just means there's nothing there.
+func readSegment(path string) ([]WALRecord, int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, 0, nil
+		}
+		return nil, 0, fmt.Errorf("failed to open WAL segment %q: %w", path, err)
+	}
+	defer f.Close()
+
+	r := bufio.NewReader(f)
+	var records []WALRecord
+	var offset int64
+
+	for {
+		header := make([]byte, walHeaderLen)
+		if _, err := io.ReadFull(r, header); err != nil {
+			break
+		}
+
+		magic := binary.BigEndian.Uint32(header[0:4])
+		lsn := int64(binary.BigEndian.Uint64(header[4:12]))
+		length := binary.BigEndian.Uint32(header[12:16])
+		wantCRC := binary.BigEndian.Uint32(header[16:20])
+		if magic != walMagic {
+			break
+		}
+
+		payload := make([]byte, length)
+		if _, err := io.ReadFull(r, payload); err != nil {
+			break
+		}
+
+		if crc32.Checksum(payload, crc32cTable) != wantCRC {
+			break
+		}
+
+		entry, err := decodeLogEntry(payload)
+		if err != nil {
+			break
+		}
+
+		records = append(records, WALRecord{LSN: lsn, Entry: entry})
+		offset += int64(len(header) + len(payload))
+	}
+
+	return records, offset, nil
+}
+
+// Recover replays every surviving segment of the WAL rooted at path, in LSN
+// order. A missing or empty WAL - nothing ever written, or everything
+// already checkpointed away - is not an error; it just means there's
+// nothing to recover. Recover has no way to know which records a caller
+// already durably committed (that checkpoint lives in the storage backend,
+// not the WAL - see storage.WALCheckpointStore): callers should replay only
+// the records whose LSN is past their own last checkpoint.
+func Recover(path string) ([]WALRecord, error) {
+	dir := filepath.Dir(path)
+	prefix := filepath.Base(path)
+
+	segments, err := listSegments(dir, prefix)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list WAL segments: %w", err)
+	}
+
+	var all []WALRecord
+	for _, start := range segments {
+		records, _, err := readSegment(segmentPath(dir, prefix, start))
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, records...)
+	}
+	return all, nil
+}
@@ -0,0 +1,17 @@
+package buffer
+
+// WindowSnapshot is one visible, z-ordered top-level window captured as
+// part of a full-desktop snapshot (see sysinfo.DesktopSnapshot), persisted
+// via a storage.WindowSnapshotRecorder alongside - but independently of -
+// the single foreground-window LogEntry each tick already produces, so
+// later "what was I looking at" queries can reconstruct the full
+// multi-monitor desktop rather than just the focused window.
+type WindowSnapshot struct {
+	UnixTime                 int64
+	HostUUID                 string
+	WindowHandle             int64
+	ProcessName              string
+	WindowTitle              string
+	Left, Top, Right, Bottom int32
+	ZOrder                   int // 0-based, front-to-back
+}
@@ -0,0 +1,128 @@
+//go:build faultinject
+
+// Package faultinject lets tests (and operators, via an HTTP admin endpoint)
+// trigger delays, panics and errors at named injection points inside the
+// Watcher pipeline, so scenarios like "flush times out mid-transaction" or
+// "Redis disconnects under load" can be reproduced deterministically instead
+// of relying on real timing races. It only compiles with -tags faultinject;
+// Trigger is a no-op stub everywhere else (see faultinject_stub.go).
+package faultinject
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Action describes what Trigger should do when a named injection point fires.
+type Action struct {
+	Delay    time.Duration `json:"delay"`
+	Panic    bool          `json:"panic"`
+	ErrorMsg string        `json:"error"` // non-empty activates an error return
+}
+
+var (
+	mu         sync.RWMutex
+	activation = map[string]Action{}
+)
+
+// Trigger checks whether name has an active Action and applies it: sleeping
+// for Delay, panicking if Panic is set, or returning an error built from
+// ErrorMsg. Call sites treat a non-nil error exactly like a real failure.
+func Trigger(name string) error {
+	mu.RLock()
+	action, ok := activation[name]
+	mu.RUnlock()
+	if !ok {
+		return nil
+	}
+
+	if action.Delay > 0 {
+		time.Sleep(action.Delay)
+	}
+	if action.Panic {
+		panic(fmt.Sprintf("faultinject: triggered panic at %q", name))
+	}
+	if action.ErrorMsg != "" {
+		return fmt.Errorf("faultinject: %s: %s", name, action.ErrorMsg)
+	}
+	return nil
+}
+
+// Enable activates an Action for name, replacing any existing activation.
+func Enable(name string, action Action) {
+	mu.Lock()
+	defer mu.Unlock()
+	activation[name] = action
+}
+
+// Disable removes the activation for name, if any.
+func Disable(name string) {
+	mu.Lock()
+	defer mu.Unlock()
+	delete(activation, name)
+}
+
+// LoadConfig replaces all activations with those decoded from a JSON object
+// of {"injection-point-name": {"delay": <nanoseconds>, "panic": bool, "error": "..."}}.
+func LoadConfig(data []byte) error {
+	var cfg map[string]Action
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return fmt.Errorf("faultinject: failed to parse config: %w", err)
+	}
+
+	mu.Lock()
+	activation = cfg
+	mu.Unlock()
+	return nil
+}
+
+// AdminHandler serves the runtime toggle API. Only reachable when this
+// package is compiled in (build tag faultinject):
+//
+//	GET    /faultinject            -> current activations (JSON)
+//	POST   /faultinject?name=X     -> body is an Action, enables it for X
+//	DELETE /faultinject?name=X     -> disables the injection at X
+func AdminHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/faultinject", func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			mu.RLock()
+			defer mu.RUnlock()
+			if err := json.NewEncoder(w).Encode(activation); err != nil {
+				log.Printf("faultinject: failed to encode activations: %v", err)
+			}
+
+		case http.MethodPost:
+			name := r.URL.Query().Get("name")
+			if name == "" {
+				http.Error(w, "missing name query parameter", http.StatusBadRequest)
+				return
+			}
+			var action Action
+			if err := json.NewDecoder(r.Body).Decode(&action); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			Enable(name, action)
+			w.WriteHeader(http.StatusNoContent)
+
+		case http.MethodDelete:
+			name := r.URL.Query().Get("name")
+			if name == "" {
+				http.Error(w, "missing name query parameter", http.StatusBadRequest)
+				return
+			}
+			Disable(name)
+			w.WriteHeader(http.StatusNoContent)
+
+		default:
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		}
+	})
+	return mux
+}
@@ -0,0 +1,18 @@
+//go:build !faultinject
+
+package faultinject
+
+import "net/http"
+
+// Trigger is a no-op when the faultinject build tag is not set, so call
+// sites can invoke it unconditionally without paying for it in production
+// builds.
+func Trigger(name string) error {
+	return nil
+}
+
+// AdminHandler returns a handler that 404s everywhere the faultinject build
+// tag isn't set, so main.go can mount it unconditionally.
+func AdminHandler() http.Handler {
+	return http.NotFoundHandler()
+}
@@ -0,0 +1,93 @@
+// Package hostid resolves a stable, machine-scoped identifier for this
+// install, so raw_events.host_uuid lets a future sync/consolidation layer
+// tell which device produced which rows without overloading session_uuid
+// (see buffer.LogEntry.HostUUID). The lookup is a fallback chain, mirroring
+// the makeNodeID pattern in HashiCorp's Consul and gopsutil's host-ID code:
+// a platform-native machine ID first (on Windows, the registry MachineGuid,
+// then the SMBIOS UUID - see hostid_windows.go), falling back on any
+// platform to a self-generated v4 UUID persisted to disk, so at least this
+// machine's ID is stable across restarts even when no OS-native source is
+// available.
+package hostid
+
+import (
+	"crypto/rand"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+var (
+	once      sync.Once
+	cached    string
+	cachedErr error
+)
+
+// Get returns this machine's stable identifier. The first call resolves it
+// through platformMachineID (platform-specific, see hostid_windows.go/
+// hostid_other.go) and, failing that, a persisted fallback UUID; later
+// calls return the cached result without touching disk again.
+func Get() (string, error) {
+	once.Do(func() {
+		cached, cachedErr = resolve()
+	})
+	return cached, cachedErr
+}
+
+func resolve() (string, error) {
+	if id, err := platformMachineID(); err == nil && id != "" {
+		return id, nil
+	}
+	return persistedFallback()
+}
+
+// fallbackDir is where persistedFallback reads/writes the self-generated
+// UUID when no platform-native machine ID is available. Prefers
+// os.UserConfigDir (e.g. %AppData% or ~/.config) and falls back to the
+// system temp dir if that's unavailable (e.g. a minimal/sandboxed
+// environment without a resolvable home directory).
+func fallbackDir() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		dir = os.TempDir()
+	}
+	return filepath.Join(dir, "mnemosyne")
+}
+
+// persistedFallback reads the UUID left behind by a previous call on this
+// machine, or generates and persists a fresh one on first use.
+func persistedFallback() (string, error) {
+	dir := fallbackDir()
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", fmt.Errorf("hostid: create %q: %w", dir, err)
+	}
+	path := filepath.Join(dir, "host_id")
+
+	if data, err := os.ReadFile(path); err == nil {
+		if id := strings.TrimSpace(string(data)); id != "" {
+			return id, nil
+		}
+	}
+
+	id, err := newV4UUID()
+	if err != nil {
+		return "", fmt.Errorf("hostid: generate fallback UUID: %w", err)
+	}
+	if err := os.WriteFile(path, []byte(id), 0600); err != nil {
+		return "", fmt.Errorf("hostid: persist fallback UUID to %q: %w", path, err)
+	}
+	return id, nil
+}
+
+// newV4UUID generates a random RFC 4122 version-4 UUID.
+func newV4UUID() (string, error) {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", err
+	}
+	b[6] = (b[6] & 0x0f) | 0x40 // version 4
+	b[8] = (b[8] & 0x3f) | 0x80 // RFC 4122 variant
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16]), nil
+}
@@ -0,0 +1,12 @@
+//go:build !windows
+
+package hostid
+
+import "fmt"
+
+// platformMachineID has no non-Windows implementation yet (no registry or
+// SMBIOS equivalent wired in), so Get always falls through to the
+// persisted fallback UUID on Linux/macOS.
+func platformMachineID() (string, error) {
+	return "", fmt.Errorf("hostid: no platform machine ID source on this OS")
+}
@@ -0,0 +1,109 @@
+//go:build windows
+
+package hostid
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"syscall"
+	"unsafe"
+)
+
+var (
+	advapi32 = syscall.NewLazyDLL("advapi32.dll")
+	kernel32 = syscall.NewLazyDLL("kernel32.dll")
+
+	procRegOpenKeyExW          = advapi32.NewProc("RegOpenKeyExW")
+	procRegQueryValueExW       = advapi32.NewProc("RegQueryValueExW")
+	procRegCloseKey            = advapi32.NewProc("RegCloseKey")
+	procGetSystemFirmwareTable = kernel32.NewProc("GetSystemFirmwareTable")
+)
+
+const (
+	hkeyLocalMachine = 0x80000002
+	keyRead          = 0x20019
+)
+
+// platformMachineID implements the Windows tiers of hostid's fallback
+// chain: the registry MachineGuid first (cheap, no admin rights needed),
+// then a hash of the raw SMBIOS table when that's unreadable.
+func platformMachineID() (string, error) {
+	if id, err := machineGUIDFromRegistry(); err == nil && id != "" {
+		return id, nil
+	}
+	return smbiosUUID()
+}
+
+// machineGUIDFromRegistry reads HKLM\SOFTWARE\Microsoft\Cryptography\
+// MachineGuid, a per-install value Windows sets at image time and never
+// changes afterward - the same source .NET's ManagementClass and several
+// licensing SDKs use as a machine fingerprint.
+func machineGUIDFromRegistry() (string, error) {
+	subkey, err := syscall.UTF16PtrFromString(`SOFTWARE\Microsoft\Cryptography`)
+	if err != nil {
+		return "", err
+	}
+
+	var hkey syscall.Handle
+	if ret, _, _ := procRegOpenKeyExW.Call(
+		uintptr(hkeyLocalMachine),
+		uintptr(unsafe.Pointer(subkey)),
+		0,
+		uintptr(keyRead),
+		uintptr(unsafe.Pointer(&hkey)),
+	); ret != 0 {
+		return "", fmt.Errorf("hostid: RegOpenKeyExW: status 0x%x", ret)
+	}
+	defer procRegCloseKey.Call(uintptr(hkey))
+
+	valueName, err := syscall.UTF16PtrFromString("MachineGuid")
+	if err != nil {
+		return "", err
+	}
+
+	var bufLen uint32
+	if ret, _, _ := procRegQueryValueExW.Call(
+		uintptr(hkey), uintptr(unsafe.Pointer(valueName)), 0, 0, 0, uintptr(unsafe.Pointer(&bufLen)),
+	); ret != 0 || bufLen == 0 {
+		return "", fmt.Errorf("hostid: RegQueryValueExW (size probe): status 0x%x", ret)
+	}
+
+	buf := make([]uint16, bufLen/2)
+	if ret, _, _ := procRegQueryValueExW.Call(
+		uintptr(hkey), uintptr(unsafe.Pointer(valueName)), 0, 0,
+		uintptr(unsafe.Pointer(&buf[0])), uintptr(unsafe.Pointer(&bufLen)),
+	); ret != 0 {
+		return "", fmt.Errorf("hostid: RegQueryValueExW: status 0x%x", ret)
+	}
+
+	return syscall.UTF16ToString(buf), nil
+}
+
+// rsmbSignature is the 'RSMB' provider signature GetSystemFirmwareTable
+// expects in order to return the raw SMBIOS firmware table.
+const rsmbSignature = 0x52534D42 // "RSMB"
+
+// smbiosUUID hashes the raw SMBIOS table into a stable ID when the registry
+// MachineGuid isn't readable. It doesn't bother parsing out the System
+// Information structure's UUID field specifically (type 1, offset 8, per
+// the SMBIOS spec) - hashing the whole table is simpler to get right and
+// just as stable across reboots, and the goal here is a consistent
+// per-machine fingerprint, not the literal BIOS UUID string.
+func smbiosUUID() (string, error) {
+	ret, _, _ := procGetSystemFirmwareTable.Call(uintptr(rsmbSignature), 0, 0, 0)
+	length := uint32(ret)
+	if length == 0 {
+		return "", fmt.Errorf("hostid: GetSystemFirmwareTable(RSMB) size probe failed")
+	}
+
+	buf := make([]byte, length)
+	ret, _, _ = procGetSystemFirmwareTable.Call(
+		uintptr(rsmbSignature), 0, uintptr(unsafe.Pointer(&buf[0])), uintptr(length),
+	)
+	if uint32(ret) == 0 {
+		return "", fmt.Errorf("hostid: GetSystemFirmwareTable(RSMB) read failed")
+	}
+
+	sum := sha256.Sum256(buf)
+	return fmt.Sprintf("%x", sum[:16]), nil
+}
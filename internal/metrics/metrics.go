@@ -0,0 +1,54 @@
+// Package metrics defines a small, dependency-free instrumentation surface
+// so packages like monitor and storage can report operational stats without
+// importing a specific metrics backend (e.g. Prometheus) directly.
+package metrics
+
+import "time"
+
+// Sink receives operational events from the Watcher pipeline. Implementations
+// decide how (or whether) to expose them; see PrometheusSink for the HTTP
+// /metrics exporter and NoopSink for when metrics are disabled.
+type Sink interface {
+	// IncEventsCaptured records that one activity sample was captured.
+	IncEventsCaptured()
+
+	// SetBufferOccupancy reports the current number of entries sitting in
+	// the in-memory buffer awaiting flush.
+	SetBufferOccupancy(n int)
+
+	// ObserveFlushLatency records how long a single flush to the storage
+	// backend took.
+	ObserveFlushLatency(d time.Duration)
+
+	// IncError records a failure in the named component (e.g. "redis", "sqlite").
+	IncError(component string)
+
+	// SetIdleTicksRatio reports the fraction of ticks (0.0-1.0) observed as idle
+	// over the current reporting window.
+	SetIdleTicksRatio(ratio float64)
+
+	// SetDBSizeBytes reports the on-disk size of the database file.
+	SetDBSizeBytes(bytes int64)
+
+	// SetSpoolDepth reports how many events are sitting un-acked in the
+	// durable spool (see storage/spool), awaiting backend confirmation.
+	SetSpoolDepth(n int)
+
+	// Reset clears all gauges back to zero. Called on graceful shutdown so a
+	// subsequently restarted Watcher doesn't briefly report stale values
+	// scraped from the previous process's last-known state.
+	Reset()
+}
+
+// NoopSink discards every metric. It is the default when --metrics-addr is
+// not set, so instrumented code never has to nil-check the sink.
+type NoopSink struct{}
+
+func (NoopSink) IncEventsCaptured()            {}
+func (NoopSink) SetBufferOccupancy(int)        {}
+func (NoopSink) ObserveFlushLatency(time.Duration) {}
+func (NoopSink) IncError(string)               {}
+func (NoopSink) SetIdleTicksRatio(float64)     {}
+func (NoopSink) SetDBSizeBytes(int64)          {}
+func (NoopSink) SetSpoolDepth(int)             {}
+func (NoopSink) Reset()                        {}
@@ -3,24 +3,20 @@
 package monitor
 
 import (
-	"bytes"
 	"context"
-	"database/sql"
-	"encoding/base64"
-	"fmt"
-	"image"
-	"image/jpeg"
 	"log"
 	"runtime"
 	"sync"
-	"syscall"
 	"time"
 
-	"github.com/kbinani/screenshot"
-
 	"mnemosyne/internal/buffer"
+	"mnemosyne/internal/faultinject"
+	"mnemosyne/internal/hostid"
+	"mnemosyne/internal/metrics"
 	"mnemosyne/internal/storage"
-	"mnemosyne/internal/win32"
+	"mnemosyne/internal/storage/spool"
+	"mnemosyne/internal/sysinfo"
+	"mnemosyne/internal/vision/dedupe"
 )
 
 // Config holds configuration for the monitor.
@@ -30,37 +26,78 @@ type Config struct {
 	BufferCapacity     int           // Buffer capacity before forced flush
 	FlushTimeout       time.Duration // Time between automatic flushes
 	ScreenshotInterval time.Duration // Time between screenshots (e.g. 2s)
+	WALPath            string        // Crash-safe buffer WAL path (empty disables it)
+
+	// DedupeMinMatchingTiles is the tile-match threshold (out of
+	// dedupe.FingerprintLen) above which a new screenshot is judged a
+	// duplicate of the last one stored. Zero uses dedupe.DefaultMinMatchingTiles.
+	DedupeMinMatchingTiles int
+
+	// AdaptivePolicy, if set, lets live OS resource pressure (battery, disk
+	// queue, CPU - see sysinfo.SystemPressure) defer buffer flushes and
+	// relax sink durability under pressure (see buffer.AdaptivePolicy). Nil
+	// disables this entirely; tick() still samples SystemPressure either
+	// way, but buffer.Buffer.SetPressureReading no-ops without a policy.
+	AdaptivePolicy buffer.AdaptivePolicy
+
+	// DesktopSnapshotDeadline bounds how long one tick's full-desktop
+	// window enumeration (see sysinfo.DesktopSnapshot) is allowed to run
+	// before tick moves on - a hung window can't stall a tick indefinitely.
+	// Zero uses win32's own per-window timeout with no overall bound.
+	DesktopSnapshotDeadline time.Duration
 }
 
 // DefaultConfig returns sensible defaults for the monitor.
 func DefaultConfig() Config {
 	return Config{
-		TickInterval:       1000 * time.Millisecond, // 1Hz (1 tick per second)
-		IdleThreshold:      60 * time.Second,
-		BufferCapacity:     100,
-		FlushTimeout:       5 * time.Minute,
-		ScreenshotInterval: 1 * time.Second, // Rate limit: 1 screenshot per second
+		TickInterval:            1000 * time.Millisecond, // 1Hz (1 tick per second)
+		IdleThreshold:           60 * time.Second,
+		BufferCapacity:          100,
+		FlushTimeout:            5 * time.Minute,
+		ScreenshotInterval:      1 * time.Second, // Rate limit: 1 screenshot per second
+		DesktopSnapshotDeadline: 200 * time.Millisecond,
 	}
 }
 
 // State holds the current state of the monitor.
 type State struct {
-	LastWindowHandle   uintptr
+	LastWindowHandle   sysinfo.WindowHandle
 	LastWindowTitle    string
 	LastProcessName    string
-	LastInputTick      uint32
 	LastTickTime       time.Time
 	LastScreenshotTime time.Time // Track last screenshot time
+
+	// HaveFingerprint/LastFingerprint/LastScreenshotHash track the last
+	// screenshot actually stored, so tick() can skip re-storing the bytes
+	// of a visually-identical frame (see vision/dedupe) and instead point
+	// the new entry at the previous frame's hash.
+	HaveFingerprint    bool
+	LastFingerprint    dedupe.Fingerprint
+	LastScreenshotHash uint64
 }
 
 // Monitor implements the main observation loop with Smart Full Stop.
 type Monitor struct {
-	config Config
-	db     *sql.DB
-	redis  *storage.RedisClient // Optional Redis client
-	buf    *buffer.Buffer
-	state  State
-	mu     sync.RWMutex
+	config  Config
+	backend storage.Backend
+	buf     *buffer.Buffer
+	state   State
+	mu      sync.RWMutex
+	metrics metrics.Sink
+	lease   *storage.Lease
+	spool   *spool.Spool
+	sys     sysinfo.Provider
+
+	// flushMu is held for the duration of flush()'s FlushBatch call, so
+	// storage.Maintainer (see FlushMu) can refuse to VACUUM/checkpoint while
+	// a flush transaction is open against the same database.
+	flushMu sync.Mutex
+
+	// hostUUID is resolved once in New via hostid.Get - the same stable
+	// per-machine ID buffer.Buffer stamps onto every LogEntry.
+	// recordWindowSnapshots stamps it onto buffer.WindowSnapshot too, since
+	// those bypass Buffer entirely.
+	hostUUID string
 
 	// Statistics
 	tickCount    uint64
@@ -71,22 +108,121 @@ type Monitor struct {
 	startTime    time.Time
 }
 
-// New creates a new monitor instance.
-func New(db *sql.DB, redis *storage.RedisClient, config Config) *Monitor {
-	return &Monitor{
-		config: config,
-		db:     db,
-		redis:  redis,
-		buf: buffer.New(buffer.BufferConfig{
-			Capacity:      config.BufferCapacity,
-			FlushTimeout:  config.FlushTimeout,
-			IdleThreshold: config.IdleThreshold,
-		}),
+// New creates a new monitor instance that writes through the given storage
+// backend (sqlite, postgres, rqlite or redis+sqlite - see storage.Open).
+// Metrics are discarded by default; use SetMetricsSink to attach a reporter.
+func New(backend storage.Backend, config Config) *Monitor {
+	// Replay anything a previous run's WAL captured but never got durably
+	// flushed (e.g. the process was killed between a tick and its next
+	// flush) so it isn't silently lost. backend's own WAL checkpoint (see
+	// storage.WALCheckpointStore) - not just "the file existed" - decides
+	// what still needs replaying: anything at or below it already made it
+	// into backend in a prior run's flush, so redoing it here would
+	// duplicate rows instead of recovering lost ones.
+	var toReplay []buffer.LogEntry
+	if config.WALPath != "" {
+		records, err := buffer.Recover(config.WALPath)
+		if err != nil {
+			log.Printf("Failed to recover buffer WAL: %v", err)
+		}
+
+		var checkpoint int64
+		if store, ok := backend.(storage.WALCheckpointStore); ok {
+			checkpoint, err = store.LastWALCheckpoint(context.Background())
+			if err != nil {
+				log.Printf("Failed to read last WAL checkpoint, replaying everything recovered: %v", err)
+				checkpoint = 0
+			}
+		}
+
+		for _, record := range records {
+			if record.LSN > checkpoint {
+				toReplay = append(toReplay, record.Entry)
+			}
+		}
+		if len(toReplay) > 0 {
+			log.Printf("Recovered %d buffered entries from WAL past checkpoint %d", len(toReplay), checkpoint)
+		}
+	}
+
+	buf := buffer.New(buffer.BufferConfig{
+		Capacity:       config.BufferCapacity,
+		FlushTimeout:   config.FlushTimeout,
+		IdleThreshold:  config.IdleThreshold,
+		WALPath:        config.WALPath,
+		AdaptivePolicy: config.AdaptivePolicy,
+	})
+
+	for _, entry := range toReplay {
+		buf.Add(entry)
+	}
+
+	sys, err := sysinfo.New()
+	if err != nil {
+		// Degrade rather than fail construction: tick() treats a nil sys as
+		// "nothing to observe yet" and no-ops, the same as a locked
+		// workstation. SetSysInfo can still inject a fake afterwards (e.g.
+		// in tests or headless builds).
+		log.Printf("Failed to initialize sysinfo provider: %v", err)
+	}
+
+	var hostUUID string
+	if id, err := hostid.Get(); err != nil {
+		log.Printf("monitor: failed to resolve host id, window snapshots will carry an empty HostUUID: %v", err)
+	} else {
+		hostUUID = id
+	}
+
+	mon := &Monitor{
+		config:   config,
+		backend:  backend,
+		metrics:  metrics.NoopSink{},
+		buf:      buf,
+		sys:      sys,
+		hostUUID: hostUUID,
 		state: State{
 			LastTickTime: time.Now(),
 		},
 		startTime: time.Now(),
 	}
+
+	// Get anything recovered above durably out of the way before the first
+	// tick, rather than let it sit in memory (and the new WAL) until the
+	// regular flush timer or capacity threshold happens to trigger.
+	if len(toReplay) > 0 {
+		mon.flush()
+	}
+
+	return mon
+}
+
+// SetMetricsSink attaches a metrics.Sink to receive operational counters and
+// gauges as the monitor runs. Call before Start; safe to leave unset, in
+// which case metrics are silently discarded.
+func (m *Monitor) SetMetricsSink(sink metrics.Sink) {
+	m.metrics = sink
+}
+
+// SetLease attaches a storage.Lease so the monitor can report leadership
+// changes (see backend_redis.go's redisSQLiteBackend). Call before Start;
+// safe to leave unset for backends that don't use leader election.
+func (m *Monitor) SetLease(lease *storage.Lease) {
+	m.lease = lease
+}
+
+// SetSpool attaches the backend's overflow spool (see backend_redis.go's
+// redisSQLiteBackend.Spool) so the monitor can report its depth. Call
+// before Start; safe to leave unset for backends without a spool.
+func (m *Monitor) SetSpool(s *spool.Spool) {
+	m.spool = s
+}
+
+// SetSysInfo overrides the sysinfo.Provider New() constructed by default,
+// so tests and headless builds can inject a fake foreground
+// window/idle/presence source instead of depending on a real OS probe.
+// Call before Start.
+func (m *Monitor) SetSysInfo(sys sysinfo.Provider) {
+	m.sys = sys
 }
 
 // Start begins the main observation loop.
@@ -94,6 +230,10 @@ func New(db *sql.DB, redis *storage.RedisClient, config Config) *Monitor {
 func (m *Monitor) Start(ctx context.Context) error {
 	log.Printf("Starting monitor with tick interval: %v", m.config.TickInterval)
 
+	if err := faultinject.Trigger("monitor.Watcher.Start"); err != nil {
+		return err
+	}
+
 	ticker := time.NewTicker(m.config.TickInterval)
 	defer ticker.Stop()
 
@@ -125,70 +265,130 @@ func (m *Monitor) tick() {
 	m.tickCount++
 	now := time.Now()
 
+	if m.sys == nil {
+		// No sysinfo.Provider available (construction failed and nothing
+		// was injected via SetSysInfo) - nothing to observe this tick.
+		return
+	}
+
 	// Step 1: Gaming Guard - Smart Full Stop
 	// If a full-screen game is running, skip this tick entirely
-	if isGame, err := win32.IsGameRunning(); err == nil && isGame {
+	if fullScreen, err := m.sys.FullScreenExclusive(); err == nil && fullScreen {
 		m.skippedTicks++
 		return
 	}
 
 	// Step 2: Idle Check
-	idleTime, err := win32.GetIdleTime()
+	idleTime, err := m.sys.IdleDuration()
 	if err != nil {
 		// Log error but continue
 		log.Printf("Error getting idle time: %v", err)
 		idleTime = 0
 	}
 
-	isIdle := idleTime >= uint32(m.config.IdleThreshold.Milliseconds())
+	isIdle := idleTime >= m.config.IdleThreshold
 	if isIdle {
 		m.idleTicks++
 	}
+	if m.tickCount > 0 {
+		m.metrics.SetIdleTicksRatio(float64(m.idleTicks) / float64(m.tickCount))
+	}
 
 	// Step 3: Get current window info
-	hwnd, err := win32.GetForegroundWindow()
-	if err != nil {
-		// No foreground window (e.g., workstation locked)
-		// Skip this tick
+	win, err := m.sys.Foreground()
+	if err != nil || win.Handle == 0 {
+		// No foreground window (e.g., workstation locked), or the probe
+		// failed. Skip this tick.
 		return
 	}
-
-	// Get window title
-	windowTitle, err := win32.GetWindowText(hwnd)
+	hwnd := win.Handle
+	windowTitle := win.Title
+	processName := win.ProcessName
+
+	// Step 3.5: Per-process resource usage (CPU/RSS/IO/handles), enriching
+	// the entry alongside the window info above. Best-effort: a sampler-less
+	// platform (anything but Windows today) or a failed sample just leaves
+	// these at zero rather than skipping the tick.
+	procStats, err := m.sys.SampleProcess(win.PID)
 	if err != nil {
-		log.Printf("Error getting window text: %v", err)
-		windowTitle = "Unknown"
+		procStats = sysinfo.ProcessStats{}
 	}
 
-	// Get process ID
-	_, pid, err := win32.GetWindowThreadProcessId(hwnd)
+	// Step 4: Calculate input intensity score. Prefer the real
+	// keystroke/mouse counts from m.sys.InputActivity (only Windows has a
+	// hook subsystem today, see sysinfo.InputActivity) over the
+	// idle-duration-only heuristic, which stays as the fallback everywhere
+	// else.
+	inputStats, err := m.sys.InputActivity()
 	if err != nil {
-		log.Printf("Error getting process ID: %v", err)
-		pid = 0
+		inputStats = sysinfo.InputStats{}
+	}
+	inputScore := m.calculateInputScore(isIdle)
+	if inputStats.Supported {
+		inputScore = inputStats.Intensity
 	}
 
-	// Get process name from PID (simplified - in production would use proper lookup)
-	processName := fmt.Sprintf("PID_%d", pid)
+	// Step 3.6: Feed live system pressure (battery/disk queue/CPU) to the
+	// buffer's AdaptivePolicy, if one is configured (see
+	// buffer.AdaptivePolicy). A best-effort sample failure just reports
+	// "unsupported", which SetPressureReading/SystemPressurePolicy already
+	// treat as "no pressure" rather than guessing.
+	pressure, err := m.sys.SystemPressure()
+	if err != nil {
+		pressure = sysinfo.SystemPressure{}
+	}
+	if event, changed := m.buf.SetPressureReading(buffer.PressureReading{
+		OnBattery:       pressure.OnBattery,
+		DiskQueueLength: pressure.DiskQueueLength,
+		CPUPercent:      pressure.CPUPercent,
+		Supported:       pressure.Supported,
+	}); changed {
+		m.recordPressureEvent(event)
+	}
 
-	// Step 4: Calculate input intensity score
-	inputScore := m.calculateInputScore(isIdle)
+	// Step 3.7: Full desktop window snapshot (see sysinfo.DesktopSnapshot) -
+	// not just the single foreground window above, but every visible
+	// top-level window, for later "what was I looking at" queries. Recorded
+	// straight through m.backend rather than buffered through m.buf, since
+	// it describes the whole desktop rather than one LogEntry; skipped
+	// entirely when either side doesn't support it (only Windows enumerates
+	// today, only sqlite/postgres persist it).
+	if recorder, ok := m.backend.(storage.WindowSnapshotRecorder); ok {
+		windows, err := m.sys.EnumWindows(m.config.DesktopSnapshotDeadline)
+		if err != nil {
+			log.Printf("Error enumerating desktop windows: %v", err)
+		} else if len(windows) > 0 {
+			m.recordWindowSnapshots(recorder, now, windows)
+		}
+	}
 
 	// Step 4.5: Screenshot Capture (Active Vision)
 	var screenshotData []byte
+	var screenshotHash uint64
+	var gotScreenshot bool
 
 	// Only capture if:
 	// 1. Not idle (don't screenshot empty screens or screensavers)
 	// 2. Interval passed (2s default)
 	// 3. Not game mode (already checked above)
 	if !isIdle && now.Sub(m.state.LastScreenshotTime) >= m.config.ScreenshotInterval {
-		data, err := m.captureScreenshot(hwnd)
+		data, hash, isDup, err := m.captureAndDedupe(hwnd)
 		if err != nil {
 			// Log error periodically, don't spam
 			if m.tickCount%50 == 0 {
 				log.Printf("Screenshot failed: %v", err)
 			}
 		} else {
-			screenshotData = data
+			// Duplicate frames still refresh LastScreenshotTime (we did
+			// capture) and count as "got a screenshot" for shouldLog below,
+			// but leave screenshotData empty so the buffer/sink don't store
+			// the bytes again - ScreenshotHash is enough to point at the
+			// frame already on disk.
+			if !isDup {
+				screenshotData = data
+			}
+			screenshotHash = hash
+			gotScreenshot = true
 			m.state.LastScreenshotTime = now
 		}
 	}
@@ -209,12 +409,12 @@ func (m *Monitor) tick() {
 	// 5. Not idle and input activity detected
 	// 6. Screenshot captured (visual change)
 
-	windowChanged := m.state.LastWindowHandle != uintptr(hwnd)
+	windowChanged := m.state.LastWindowHandle != hwnd
 	titleChanged := m.state.LastWindowTitle != windowTitle
 	processChanged := m.state.LastProcessName != processName
 	timePassed := now.Sub(m.state.LastTickTime) > 5*time.Second
 
-	if windowChanged || titleChanged || processChanged || timePassed || (!isIdle && inputScore > 0.1) || len(screenshotData) > 0 {
+	if windowChanged || titleChanged || processChanged || timePassed || (!isIdle && inputScore > 0.1) || gotScreenshot {
 		shouldLog = true
 	}
 
@@ -225,17 +425,28 @@ func (m *Monitor) tick() {
 			ProcessName:    processName,
 			WindowTitle:    windowTitle,
 			WindowHandle:   int64(hwnd),
-			InputIdleMs:    int64(idleTime),
+			InputIdleMs:    idleTime.Milliseconds(),
 			InputIntensity: inputScore,
 			ScreenshotPath: "RAM", // Placeholder for legacy DB compatibility
 			ScreenshotData: screenshotData,
+			ScreenshotHash: screenshotHash,
+			CPUPercent:     float32(procStats.CPUPercent),
+			WorkingSetRSS:  procStats.WorkingSetRSS,
+			IOReadBytes:    procStats.IOReadBytes,
+			IOWriteBytes:   procStats.IOWriteBytes,
+			HandleCount:    procStats.HandleCount,
+			Keystrokes:     inputStats.Keystrokes,
+			MouseClicks:    inputStats.MouseClicks,
+			MouseTravelPx:  inputStats.MouseTravelPx,
 		}
 
 		// Add to buffer
 		flushNeeded := m.buf.Add(entry)
+		m.metrics.IncEventsCaptured()
+		m.metrics.SetBufferOccupancy(m.buf.Len())
 
 		// Update state
-		m.state.LastWindowHandle = uintptr(hwnd)
+		m.state.LastWindowHandle = hwnd
 		m.state.LastWindowTitle = windowTitle
 		m.state.LastProcessName = processName
 		m.state.LastTickTime = now
@@ -247,76 +458,28 @@ func (m *Monitor) tick() {
 	}
 }
 
-// calculateInputScore calculates a normalized input intensity score (0.0 to 1.0).
-// This is a heuristic based on idle time and input tick changes.
+// calculateInputScoreWindow is the normalization range calculateInputScore
+// maps IdleDuration into: 0 idle = 1.0, >= this = 0.0.
+const calculateInputScoreWindow = 5 * time.Second
+
+// calculateInputScore calculates a normalized input intensity score (0.0 to
+// 1.0), a heuristic based purely on how recently the user has provided
+// input.
 func (m *Monitor) calculateInputScore(isIdle bool) float32 {
 	if isIdle {
 		return 0.0
 	}
 
-	// Get current input tick
-	inputTick, err := win32.GetLastInputInfo()
+	idleTime, err := m.sys.IdleDuration()
 	if err != nil {
 		return 0.0
 	}
 
-	// Check if input tick changed since last tick
-	m.mu.RLock()
-	lastTick := m.state.LastInputTick
-	m.mu.RUnlock()
-
-	if inputTick == lastTick {
-		// No new input
-		return 0.0
-	}
-
-	// Update last input tick
-	m.mu.Lock()
-	m.state.LastInputTick = inputTick
-	m.mu.Unlock()
-
-	// Calculate score based on time since last input
-	// Recent input = higher score
-	idleTime, _ := win32.GetIdleTime()
-
-	// Normalize: 0ms idle = 1.0, 5000ms idle = 0.0
-	if idleTime >= 5000 {
+	if idleTime >= calculateInputScoreWindow {
 		return 0.0
 	}
 
-	return 1.0 - float32(idleTime)/5000.0
-}
-
-// captureScreenshot captures the window content and returns JPEG bytes.
-// Uses in-memory processing to avoid SSD writes (Ephemeral Vision).
-func (m *Monitor) captureScreenshot(hwnd syscall.Handle) ([]byte, error) {
-	// 1. Get Window Rect
-	rect, err := win32.GetWindowRect(hwnd)
-	if err != nil {
-		return nil, err
-	}
-
-	// 2. Normalize coordinates
-	width := int(rect.Right - rect.Left)
-	height := int(rect.Bottom - rect.Top)
-
-	if width <= 0 || height <= 0 {
-		return nil, fmt.Errorf("invalid dimensions: %dx%d", width, height)
-	}
-
-	// 3. Capture
-	img, err := screenshot.CaptureRect(image.Rect(int(rect.Left), int(rect.Top), int(rect.Right), int(rect.Bottom)))
-	if err != nil {
-		return nil, err
-	}
-
-	// 4. Encode to JPEG in memory
-	var buf bytes.Buffer
-	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: 75}); err != nil {
-		return nil, err
-	}
-
-	return buf.Bytes(), nil
+	return 1.0 - float32(idleTime)/float32(calculateInputScoreWindow)
 }
 
 // flushHandler handles periodic flushes from the buffer's flush channel.
@@ -339,58 +502,138 @@ func (m *Monitor) flushHandlerStop() {
 	// The flush handler will stop when context is cancelled
 }
 
-// flush performs a buffer flush to the database or Redis.
-func (m *Monitor) flush() {
-	// 1. Redis Mode (v4.0 Fast Path)
-	if m.redis != nil {
-		entries := m.buf.GetAndClear()
-		if len(entries) == 0 {
-			return
+// recordWindowSnapshots persists one tick's full desktop snapshot via
+// recorder, stamping each window with m.hostUUID and now the same way
+// buffer.Buffer stamps LogEntry (see hostid.Get).
+func (m *Monitor) recordWindowSnapshots(recorder storage.WindowSnapshotRecorder, now time.Time, windows []sysinfo.WindowSnapshotInfo) {
+	snapshots := make([]buffer.WindowSnapshot, len(windows))
+	for i, w := range windows {
+		snapshots[i] = buffer.WindowSnapshot{
+			UnixTime:     now.UnixMilli(),
+			HostUUID:     m.hostUUID,
+			WindowHandle: int64(w.Handle),
+			ProcessName:  w.ProcessName,
+			WindowTitle:  w.Title,
+			Left:         w.Left,
+			Top:          w.Top,
+			Right:        w.Right,
+			Bottom:       w.Bottom,
+			ZOrder:       w.ZOrder,
 		}
+	}
 
-		ctx := context.Background()
-		pushed := 0
-
-		for _, entry := range entries {
-			// Convert to efficient map for JSON/MsgPack (or plain map for XADD)
-			data := map[string]interface{}{
-				"session_uuid":    entry.SessionUUID,
-				"unix_time":       entry.UnixTime,
-				"process_name":    entry.ProcessName,
-				"window_title":    entry.WindowTitle,
-				"window_hwnd":     entry.WindowHandle,
-				"input_idle":      entry.InputIdleMs,
-				"intensity":       entry.InputIntensity,
-				"screenshot_path": entry.ScreenshotPath, // "RAM"
-			}
+	if err := recorder.RecordWindowSnapshots(context.Background(), toBackendWindowSnapshots(snapshots)); err != nil {
+		log.Printf("Failed to record desktop window snapshot: %v", err)
+	}
+}
 
-			// Attach image data if present
-			if len(entry.ScreenshotData) > 0 {
-				data["image_data"] = base64.StdEncoding.EncodeToString(entry.ScreenshotData)
-			}
+// recordPressureEvent persists event via m.backend's
+// storage.PressureEventRecorder, if it implements one (sqlite and postgres
+// today - see backend_sqlite.go/backend_postgres.go). A no-op otherwise.
+func (m *Monitor) recordPressureEvent(event buffer.PressureEvent) {
+	recorder, ok := m.backend.(storage.PressureEventRecorder)
+	if !ok {
+		return
+	}
 
-			if err := m.redis.PublishEvent(ctx, "mnemosyne:events", data); err != nil {
-				log.Printf("Error publishing to Redis: %v", err)
-			} else {
-				pushed++
-			}
-		}
+	if err := recorder.RecordPressureEvent(context.Background(), storage.PressureEvent{
+		UnixTime:          event.UnixTime,
+		OnBattery:         event.OnBattery,
+		DiskQueueLength:   event.DiskQueueLength,
+		CPUPercent:        event.CPUPercent,
+		DeferralMs:        event.DeferralMs,
+		RelaxedDurability: event.RelaxedDurability,
+	}); err != nil {
+		log.Printf("Failed to record buffer pressure event: %v", err)
+	}
+}
 
-		if pushed > 0 {
-			m.flushCount++
-			m.eventsPushed += uint64(pushed)
-		}
+// flush performs a buffer flush through the configured storage backend.
+func (m *Monitor) flush() {
+	entries, lsn := m.buf.GetAndClear()
+	if len(entries) == 0 {
 		return
 	}
 
-	// 2. SQLite Mode (Legacy)
-	err := m.buf.Flush(m.db)
+	ctx := context.Background()
+	if _, relaxed := m.buf.PressureState(); relaxed {
+		ctx = storage.WithRelaxedDurability(ctx)
+	}
+	ctx = storage.WithWALCheckpoint(ctx, lsn)
+
+	m.flushMu.Lock()
+	defer m.flushMu.Unlock()
+
+	start := time.Now()
+	err := m.backend.FlushBatch(ctx, toBackendEvents(entries))
+	m.metrics.ObserveFlushLatency(time.Since(start))
 	if err != nil {
-		log.Printf("Error flushing buffer: %v", err)
+		log.Printf("Error flushing buffer to backend: %v", err)
+		m.metrics.IncError("storage")
 		return
 	}
 
+	// FlushBatch committed the checkpoint in the same transaction as the
+	// events above (see backend_sqlite.go/backend_postgres.go), so it's
+	// durable now: safe to reclaim any WAL segment it covers (see
+	// buffer.Buffer.Checkpoint).
+	m.buf.Checkpoint(lsn)
+
 	m.flushCount++
+	m.eventsPushed += uint64(len(entries))
+	m.metrics.SetBufferOccupancy(m.buf.Len())
+}
+
+// toBackendWindowSnapshots converts a batch of buffer.WindowSnapshot (the
+// domain type recordWindowSnapshots builds) into storage.WindowSnapshot (the
+// storage-agnostic wire type), mirroring toBackendEvents below.
+func toBackendWindowSnapshots(snapshots []buffer.WindowSnapshot) []storage.WindowSnapshot {
+	out := make([]storage.WindowSnapshot, len(snapshots))
+	for i, s := range snapshots {
+		out[i] = storage.WindowSnapshot{
+			UnixTime:     s.UnixTime,
+			HostUUID:     s.HostUUID,
+			WindowHandle: s.WindowHandle,
+			ProcessName:  s.ProcessName,
+			WindowTitle:  s.WindowTitle,
+			Left:         s.Left,
+			Top:          s.Top,
+			Right:        s.Right,
+			Bottom:       s.Bottom,
+			ZOrder:       s.ZOrder,
+		}
+	}
+	return out
+}
+
+// toBackendEvents converts buffered LogEntry values into storage.Event,
+// the backend-agnostic wire shape every driver writes.
+func toBackendEvents(entries []buffer.LogEntry) []storage.Event {
+	events := make([]storage.Event, len(entries))
+	for i, entry := range entries {
+		events[i] = storage.Event{
+			SessionUUID:    entry.SessionUUID,
+			HostUUID:       entry.HostUUID,
+			UnixTime:       entry.UnixTime,
+			ProcessName:    entry.ProcessName,
+			WindowTitle:    entry.WindowTitle,
+			WindowHandle:   entry.WindowHandle,
+			InputIdleMs:    entry.InputIdleMs,
+			InputIntensity: entry.InputIntensity,
+			ScreenshotPath: entry.ScreenshotPath,
+			ScreenshotData: entry.ScreenshotData,
+			ScreenshotHash: entry.ScreenshotHash,
+			CPUPercent:     entry.CPUPercent,
+			WorkingSetRSS:  entry.WorkingSetRSS,
+			IOReadBytes:    entry.IOReadBytes,
+			IOWriteBytes:   entry.IOWriteBytes,
+			HandleCount:    entry.HandleCount,
+			Keystrokes:     entry.Keystrokes,
+			MouseClicks:    entry.MouseClicks,
+			MouseTravelPx:  entry.MouseTravelPx,
+		}
+	}
+	return events
 }
 
 // shutdown performs graceful shutdown by flushing remaining data.
@@ -398,11 +641,7 @@ func (m *Monitor) shutdown() error {
 	log.Println("Performing graceful shutdown...")
 
 	// Force flush any remaining data
-	err := m.buf.ForceFlush(m.db)
-	if err != nil {
-		log.Printf("Error during final flush: %v", err)
-		return err
-	}
+	m.flush()
 
 	m.buf.Stop()
 
@@ -439,6 +678,13 @@ func (m *Monitor) GetBuffer() *buffer.Buffer {
 	return m.buf
 }
 
+// FlushMu returns the mutex flush() holds for the duration of its
+// FlushBatch call, so storage.NewMaintainer can be handed real coordination
+// instead of nil (see flushMu).
+func (m *Monitor) FlushMu() *sync.Mutex {
+	return &m.flushMu
+}
+
 // statsLogger periodically logs monitoring statistics.
 func (m *Monitor) statsLogger(ctx context.Context) {
 	ticker := time.NewTicker(30 * time.Second)
@@ -484,22 +730,15 @@ func (m *Monitor) logStats() {
 	log.Printf("ðŸ”„ Ticks: %d total | %d idle | %d skipped (games)", tickCount, idleTicks, skippedTicks)
 	log.Printf("ðŸ’¾ Buffer: %d entries | %d bytes", bufferLen, bufferSize)
 
-	if m.redis != nil {
-		log.Printf("ðŸš€ Redis: %d events pushed | %d flushes", eventsPushed, flushCount)
-	} else {
-		// Legacy DB counts
-		var totalEvents, pendingEvents int64
-		row := m.db.QueryRow("SELECT COUNT(*) FROM raw_events")
-		if err := row.Scan(&totalEvents); err != nil {
-			totalEvents = -1
-		}
-		row = m.db.QueryRow("SELECT COUNT(*) FROM raw_events WHERE is_processed = 0")
-		if err := row.Scan(&pendingEvents); err != nil {
-			pendingEvents = -1
-		}
-		log.Printf("ðŸ“ Database: %d events | %d pending | %d flushes", totalEvents, pendingEvents, flushCount)
+	log.Printf("🚀 Backend: %d events pushed | %d flushes", eventsPushed, flushCount)
+	if m.lease != nil {
+		log.Printf("Lease: holding=%v", m.lease.Held())
+	}
+	if m.spool != nil {
+		depth := m.spool.Depth()
+		m.metrics.SetSpoolDepth(depth)
+		log.Printf("Spool: %d un-acked | %d dropped", depth, m.spool.Dropped())
 	}
-
 	log.Printf("ðŸ§  RAM: %.1f MB used | %.1f MB sys", allocMB, sysMB)
 	log.Printf("â”â”â”â”â”â”â”â”â”â”â”â”â”â”â”â”â”â”â”â”â”â”â”â”â”â”â”â”â”â”â”â”â”â”â”â”â”â”â”â”â”â”â”â”â”â”â”â”â”â”")
 }
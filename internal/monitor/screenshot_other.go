@@ -0,0 +1,21 @@
+//go:build !windows
+
+package monitor
+
+import (
+	"errors"
+
+	"mnemosyne/internal/sysinfo"
+)
+
+// errScreenshotUnsupported is returned on platforms without a capture
+// backend yet (see captureAndDedupe). Linux/macOS capture is tracked
+// separately from the sysinfo abstraction this file depends on.
+var errScreenshotUnsupported = errors.New("monitor: screenshot capture not yet implemented on this platform")
+
+// captureAndDedupe mirrors screenshot_windows.go's signature so tick() can
+// stay platform-agnostic; non-Windows builds simply report every tick as
+// screenshot-less until a capture backend lands here.
+func (m *Monitor) captureAndDedupe(hwnd sysinfo.WindowHandle) (data []byte, hash uint64, isDup bool, err error) {
+	return nil, 0, false, errScreenshotUnsupported
+}
@@ -0,0 +1,102 @@
+//go:build windows
+
+package monitor
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"syscall"
+
+	"github.com/kbinani/screenshot"
+
+	"mnemosyne/internal/sysinfo"
+	"mnemosyne/internal/vision/dedupe"
+	"mnemosyne/internal/win32"
+)
+
+// captureScreenshotImage captures the window content and returns the raw
+// image, so callers can fingerprint it (see vision/dedupe) before deciding
+// whether it's worth JPEG-encoding at all.
+func (m *Monitor) captureScreenshotImage(hwnd sysinfo.WindowHandle) (image.Image, error) {
+	// 1. Get Window Rect
+	rect, err := win32.GetWindowRect(syscall.Handle(hwnd))
+	if err != nil {
+		return nil, err
+	}
+
+	// 2. Normalize coordinates
+	width := int(rect.Right - rect.Left)
+	height := int(rect.Bottom - rect.Top)
+
+	if width <= 0 || height <= 0 {
+		return nil, fmt.Errorf("invalid dimensions: %dx%d", width, height)
+	}
+
+	// 3. Capture
+	return screenshot.CaptureRect(image.Rect(int(rect.Left), int(rect.Top), int(rect.Right), int(rect.Bottom)))
+}
+
+// captureScreenshot captures the window content and returns JPEG bytes.
+// Uses in-memory processing to avoid SSD writes (Ephemeral Vision).
+func (m *Monitor) captureScreenshot(hwnd sysinfo.WindowHandle) ([]byte, error) {
+	img, err := m.captureScreenshotImage(hwnd)
+	if err != nil {
+		return nil, err
+	}
+	return encodeJPEG(img)
+}
+
+// encodeJPEG encodes img to JPEG in memory at the quality level the rest of
+// the pipeline (storage, sinks) expects.
+func encodeJPEG(img image.Image) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: 75}); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// captureAndDedupe captures the window content and, via vision/dedupe,
+// checks it against the last frame actually stored. It returns the JPEG
+// bytes (empty when isDup is true - the caller already has those bytes
+// under the previous hash) along with the content hash to stamp the entry
+// with, either way.
+func (m *Monitor) captureAndDedupe(hwnd sysinfo.WindowHandle) (data []byte, hash uint64, isDup bool, err error) {
+	img, err := m.captureScreenshotImage(hwnd)
+	if err != nil {
+		return nil, 0, false, err
+	}
+
+	fp := dedupe.Compute(img)
+
+	threshold := m.config.DedupeMinMatchingTiles
+	if threshold == 0 {
+		threshold = dedupe.DefaultMinMatchingTiles
+	}
+
+	m.mu.RLock()
+	haveFingerprint := m.state.HaveFingerprint
+	lastFingerprint := m.state.LastFingerprint
+	lastHash := m.state.LastScreenshotHash
+	m.mu.RUnlock()
+
+	if haveFingerprint && dedupe.SameFrame(lastFingerprint, fp, threshold) {
+		return nil, lastHash, true, nil
+	}
+
+	encoded, err := encodeJPEG(img)
+	if err != nil {
+		return nil, 0, false, err
+	}
+	newHash := dedupe.HashFrame(encoded)
+
+	m.mu.Lock()
+	m.state.HaveFingerprint = true
+	m.state.LastFingerprint = fp
+	m.state.LastScreenshotHash = newHash
+	m.mu.Unlock()
+
+	return encoded, newHash, false, nil
+}
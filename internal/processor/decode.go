@@ -0,0 +1,32 @@
+package processor
+
+import (
+	"mnemosyne/internal/buffer"
+	"mnemosyne/internal/storage"
+)
+
+// logEntryFromStreamFields decodes a Redis Streams message's field map -
+// written by storage.EncodeStreamEvent (see eventToStreamFields in
+// storage/backend_redis.go, or the monitor's own Redis flush path) - back
+// into a buffer.LogEntry. storage.DecodeStreamEvent auto-detects whether
+// the message is the original per-field map or a single MessagePack blob
+// (storage.EncodingMsgPack), so the Processor doesn't care which
+// Config.RedisEncoding produced it.
+func logEntryFromStreamFields(values map[string]interface{}) buffer.LogEntry {
+	event := storage.DecodeStreamEvent(values)
+	return buffer.LogEntry{
+		SessionUUID:       event.SessionUUID,
+		UnixTime:          event.UnixTime,
+		ProcessName:       event.ProcessName,
+		WindowTitle:       event.WindowTitle,
+		WindowHandle:      event.WindowHandle,
+		InputIdleMs:       event.InputIdleMs,
+		InputIntensity:    event.InputIntensity,
+		ScreenshotData:    event.ScreenshotData,
+		ScreenshotHash:    event.ScreenshotHash,
+		EndUnixTime:       event.EndUnixTime,
+		SampleCount:       event.SampleCount,
+		InputIntensitySum: event.InputIntensitySum,
+		InputIdleMin:      event.InputIdleMin,
+	}
+}
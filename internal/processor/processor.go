@@ -0,0 +1,259 @@
+// Package processor implements the Processor tier: a worker pool that
+// reliably drains a Redis Stream (e.g. "{mnemosyne}:events", see
+// storage.RedisClient.PublishEvent) via a consumer group, decoding each
+// entry back into a buffer.LogEntry and handing batches to a pluggable
+// buffer.Sink. This is what closes the loop between the 1Hz Watcher and
+// downstream processing (SQLite writer, OCR pipeline, ...) without losing
+// events when a Processor crashes mid-batch: unacked entries stay in the
+// group's pending-entries list and are either redelivered on restart or
+// reclaimed by the claim sweeper, and entries that fail past MaxDeliveries
+// are moved to a dead-letter stream instead of being retried forever.
+package processor
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"mnemosyne/internal/buffer"
+	"mnemosyne/internal/storage"
+)
+
+// Config controls a Processor's consumer group, batching, and claim/dead-
+// letter behavior.
+type Config struct {
+	Stream string // source stream, e.g. "{mnemosyne}:events"
+	Group  string // consumer group name, e.g. "processor"
+
+	// Consumer is this process's base consumer identity; each worker
+	// goroutine suffixes it with its own index. Defaults to
+	// "<hostname>-<pid>".
+	Consumer string
+
+	WorkerCount int           // ReadGroup worker goroutines (default 4)
+	BatchCount  int64         // entries requested per XREADGROUP/XAUTOCLAIM call (default 50)
+	BlockTime   time.Duration // XREADGROUP block duration (default 5s)
+
+	ClaimInterval time.Duration // how often to sweep for abandoned pending entries (default 30s)
+	ClaimMinIdle  time.Duration // idle time before a pending entry is claimable (default 1m)
+	MaxDeliveries int64         // delivery attempts allowed before dead-lettering (default 5)
+
+	// DeadLetterStream receives entries that exceed MaxDeliveries, each
+	// carrying its original fields plus original_id/original_stream.
+	// Defaults to Stream+":dead".
+	DeadLetterStream string
+}
+
+func (c Config) withDefaults() Config {
+	if c.WorkerCount <= 0 {
+		c.WorkerCount = 4
+	}
+	if c.BatchCount <= 0 {
+		c.BatchCount = 50
+	}
+	if c.BlockTime <= 0 {
+		c.BlockTime = 5 * time.Second
+	}
+	if c.ClaimInterval <= 0 {
+		c.ClaimInterval = 30 * time.Second
+	}
+	if c.ClaimMinIdle <= 0 {
+		c.ClaimMinIdle = time.Minute
+	}
+	if c.MaxDeliveries <= 0 {
+		c.MaxDeliveries = 5
+	}
+	if c.DeadLetterStream == "" {
+		c.DeadLetterStream = c.Stream + ":dead"
+	}
+	if c.Consumer == "" {
+		host, err := os.Hostname()
+		if err != nil {
+			host = "processor"
+		}
+		c.Consumer = fmt.Sprintf("%s-%d", host, os.Getpid())
+	}
+	return c
+}
+
+// Processor drains Config.Stream into Sink via a Redis Streams consumer
+// group. Construct with New, then Start; Stop waits for every worker and
+// the claim sweeper to exit.
+type Processor struct {
+	redis *storage.RedisClient
+	sink  buffer.Sink
+	cfg   Config
+
+	stopCh chan struct{}
+	wg     sync.WaitGroup
+}
+
+// New builds a Processor draining cfg.Stream into sink. Call Start to begin.
+func New(redisClient *storage.RedisClient, sink buffer.Sink, cfg Config) *Processor {
+	return &Processor{
+		redis:  redisClient,
+		sink:   sink,
+		cfg:    cfg.withDefaults(),
+		stopCh: make(chan struct{}),
+	}
+}
+
+// Start ensures the consumer group exists, then launches the worker pool
+// and claim sweeper in the background. Returns once the group is ready.
+func (p *Processor) Start(ctx context.Context) error {
+	if err := p.redis.EnsureGroup(ctx, p.cfg.Stream, p.cfg.Group); err != nil {
+		return fmt.Errorf("processor: %w", err)
+	}
+
+	for i := 0; i < p.cfg.WorkerCount; i++ {
+		consumer := fmt.Sprintf("%s-%d", p.cfg.Consumer, i)
+		p.wg.Add(1)
+		go p.runWorker(consumer)
+	}
+
+	p.wg.Add(1)
+	go p.runClaimSweeper()
+
+	return nil
+}
+
+// Stop signals every worker and the claim sweeper to exit and waits for
+// them to finish their current batch.
+func (p *Processor) Stop() {
+	close(p.stopCh)
+	p.wg.Wait()
+}
+
+// runWorker is one ReadGroup consumer: block for new entries (">"), write
+// the batch through Sink, and Ack only on success. On failure the batch
+// stays in the pending-entries list for the claim sweeper (or this same
+// worker's next redelivery) to retry.
+func (p *Processor) runWorker(consumer string) {
+	defer p.wg.Done()
+
+	for {
+		select {
+		case <-p.stopCh:
+			return
+		default:
+		}
+
+		msgs, err := p.redis.ReadGroup(context.Background(), p.cfg.Stream, p.cfg.Group, consumer, ">", p.cfg.BatchCount, p.cfg.BlockTime)
+		if err != nil {
+			log.Printf("processor: %s: ReadGroup failed: %v", consumer, err)
+			time.Sleep(time.Second)
+			continue
+		}
+		if len(msgs) == 0 {
+			continue
+		}
+
+		p.process(context.Background(), consumer, msgs)
+	}
+}
+
+// process decodes msgs into LogEntry values, writes them through Sink as a
+// single batch, and Acks them only once the sink confirms the write.
+func (p *Processor) process(ctx context.Context, consumer string, msgs []redis.XMessage) {
+	entries := make([]buffer.LogEntry, len(msgs))
+	ids := make([]string, len(msgs))
+	for i, msg := range msgs {
+		entries[i] = logEntryFromStreamFields(msg.Values)
+		ids[i] = msg.ID
+	}
+
+	if err := p.sink.Write(ctx, entries); err != nil {
+		log.Printf("processor: %s: sink %q failed on %d entries, leaving them pending: %v", consumer, p.sink.Name(), len(entries), err)
+		return
+	}
+
+	if err := p.redis.Ack(ctx, p.cfg.Stream, p.cfg.Group, ids...); err != nil {
+		log.Printf("processor: %s: ack failed: %v", consumer, err)
+	}
+}
+
+// runClaimSweeper periodically reclaims pending entries abandoned by a
+// crashed consumer, so they don't sit unflushed forever just because the
+// worker that originally read them never Acked.
+func (p *Processor) runClaimSweeper() {
+	defer p.wg.Done()
+
+	ticker := time.NewTicker(p.cfg.ClaimInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-p.stopCh:
+			return
+		case <-ticker.C:
+			p.sweep()
+		}
+	}
+}
+
+// sweep pages through the consumer group's pending-entries list via
+// XAUTOCLAIM, claiming anything idle beyond ClaimMinIdle under this
+// Processor's own sweep identity and handing it to handleClaimed.
+func (p *Processor) sweep() {
+	ctx := context.Background()
+	consumer := p.cfg.Consumer + "-sweep"
+	start := "0-0"
+
+	for {
+		msgs, cursor, err := p.redis.Claim(ctx, p.cfg.Stream, p.cfg.Group, consumer, p.cfg.ClaimMinIdle, start, p.cfg.BatchCount)
+		if err != nil {
+			log.Printf("processor: claim sweep failed: %v", err)
+			return
+		}
+		if len(msgs) > 0 {
+			p.handleClaimed(ctx, consumer, msgs)
+		}
+		if cursor == "0-0" || len(msgs) == 0 {
+			return
+		}
+		start = cursor
+	}
+}
+
+// handleClaimed splits newly-claimed entries into ones still worth a normal
+// retry and ones that have already been delivered MaxDeliveries times,
+// dead-lettering the latter instead of claiming them forever.
+func (p *Processor) handleClaimed(ctx context.Context, consumer string, msgs []redis.XMessage) {
+	var retry []redis.XMessage
+	for _, msg := range msgs {
+		detail, err := p.redis.PendingDetail(ctx, p.cfg.Stream, p.cfg.Group, msg.ID, msg.ID, 1)
+		if err == nil && len(detail) > 0 && detail[0].DeliveryCount > p.cfg.MaxDeliveries {
+			p.deadLetter(ctx, msg)
+			continue
+		}
+		retry = append(retry, msg)
+	}
+	if len(retry) > 0 {
+		p.process(ctx, consumer, retry)
+	}
+}
+
+// deadLetter republishes msg to DeadLetterStream (tagged with its original
+// ID/stream for debugging) and Acks it out of the source group's pending
+// list so it stops being reclaimed.
+func (p *Processor) deadLetter(ctx context.Context, msg redis.XMessage) {
+	fields := make(map[string]interface{}, len(msg.Values)+2)
+	for k, v := range msg.Values {
+		fields[k] = v
+	}
+	fields["original_id"] = msg.ID
+	fields["original_stream"] = p.cfg.Stream
+
+	if err := p.redis.PublishEvent(ctx, p.cfg.DeadLetterStream, fields); err != nil {
+		log.Printf("processor: failed to dead-letter %s: %v", msg.ID, err)
+		return
+	}
+	if err := p.redis.Ack(ctx, p.cfg.Stream, p.cfg.Group, msg.ID); err != nil {
+		log.Printf("processor: failed to ack dead-lettered entry %s: %v", msg.ID, err)
+	}
+}
@@ -0,0 +1,257 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+)
+
+// Event is the backend-agnostic representation of a single captured
+// activity sample. It mirrors buffer.LogEntry but lives in storage so
+// drivers don't need to import the buffer package.
+type Event struct {
+	SessionUUID    string
+	HostUUID       string // stable per-machine ID (see hostid.Get), enabling multi-device consolidation without overloading SessionUUID
+	UnixTime       int64
+	ProcessName    string
+	WindowTitle    string
+	WindowHandle   int64
+	InputIdleMs    int64
+	InputIntensity float32
+	ScreenshotPath string
+	ScreenshotData []byte
+	ScreenshotHash uint64 // xxhash64 of the referenced frame's encoded bytes (see vision/dedupe.HashFrame), for content-addressing
+
+	// The fields below mirror buffer.LogEntry's process resource usage
+	// enrichment (see sysinfo.ProcessSampler). All zero on platforms
+	// without a sampler.
+	CPUPercent    float32
+	WorkingSetRSS uint64
+	IOReadBytes   uint64
+	IOWriteBytes  uint64
+	HandleCount   uint32
+
+	// The fields below mirror buffer.LogEntry's low-level input hook
+	// counters (see sysinfo.InputActivity). All zero on platforms without a
+	// hook subsystem.
+	Keystrokes    uint32
+	MouseClicks   uint32
+	MouseTravelPx uint64
+
+	// The fields below carry buffer.LogEntry's run-length coalescing data
+	// (see buffer.Coalesce). SampleCount <= 1 means "not coalesced" -
+	// drivers should then treat this as a single sample the way they always
+	// have.
+	EndUnixTime       int64
+	SampleCount       int
+	InputIntensitySum float32
+	InputIdleMin      int64
+}
+
+// PressureEvent records one change in buffer.AdaptivePolicy's decision
+// (battery/disk/CPU pressure causing Buffer to defer flushes and/or relax
+// durability), persisted via PressureEventRecorder for later analysis.
+type PressureEvent struct {
+	UnixTime          int64
+	OnBattery         bool
+	DiskQueueLength   float64
+	CPUPercent        float64
+	DeferralMs        int64
+	RelaxedDurability bool
+}
+
+// PressureEventRecorder is implemented by storage backends that can persist
+// PressureEvent rows (sqlite and postgres today). rqlite picks up the
+// buffer_pressure_events table via its shared DDL with sqlite but, like its
+// existing coalesce/dedupe/processStats/hostID column policy, doesn't get
+// write wiring; callers should type-assert before calling.
+type PressureEventRecorder interface {
+	RecordPressureEvent(ctx context.Context, event PressureEvent) error
+}
+
+// relaxedDurabilityKey is the context key WithRelaxedDurability/
+// RelaxedDurability use to mark a FlushBatch call as allowed to trade
+// durability for fewer fsyncs under system pressure (see
+// buffer.AdaptivePolicy).
+type relaxedDurabilityKey struct{}
+
+// WithRelaxedDurability marks ctx so a backend that supports it (sqlite)
+// can relax its durability guarantees for this one FlushBatch.
+func WithRelaxedDurability(ctx context.Context) context.Context {
+	return context.WithValue(ctx, relaxedDurabilityKey{}, true)
+}
+
+// RelaxedDurability reports whether ctx was marked via
+// WithRelaxedDurability.
+func RelaxedDurability(ctx context.Context) bool {
+	relaxed, _ := ctx.Value(relaxedDurabilityKey{}).(bool)
+	return relaxed
+}
+
+// WindowSnapshot mirrors buffer.WindowSnapshot but lives in storage so
+// drivers don't need to import the buffer package (see Event/PressureEvent
+// above for the same split). One tick's full desktop snapshot (see
+// sysinfo.DesktopSnapshot) is a batch of these, one per visible top-level
+// window, persisted via WindowSnapshotRecorder independently of the single
+// foreground-window Event that tick also produces.
+type WindowSnapshot struct {
+	UnixTime                 int64
+	HostUUID                 string
+	WindowHandle             int64
+	ProcessName              string
+	WindowTitle              string
+	Left, Top, Right, Bottom int32
+	ZOrder                   int
+}
+
+// WindowSnapshotRecorder is implemented by storage backends that can
+// persist a WindowSnapshot batch (sqlite and postgres today). rqlite picks
+// up the window_snapshots table via its shared DDL with sqlite but, like
+// its existing coalesce/dedupe/processStats/hostID/buffer_pressure_events/
+// wal_checkpoint column policy, doesn't get write wiring; callers should
+// type-assert before calling.
+type WindowSnapshotRecorder interface {
+	// RecordWindowSnapshots persists one tick's full desktop snapshot as a
+	// batch in a single transaction.
+	RecordWindowSnapshots(ctx context.Context, windows []WindowSnapshot) error
+}
+
+// WALCheckpointStore is implemented by storage backends that can durably
+// record the highest buffer.WALRecord LSN committed by a FlushBatch call
+// (sqlite and postgres today), so buffer.Buffer's crash recovery (see
+// wal.go) knows which WAL records it already wrote and which still need
+// replaying. rqlite picks up the wal_checkpoint table via its shared DDL
+// with sqlite but, like its existing coalesce/dedupe/processStats/hostID/
+// buffer_pressure_events column policy, doesn't get write wiring; callers
+// should type-assert before calling.
+type WALCheckpointStore interface {
+	// LastWALCheckpoint returns the highest LSN durably committed so far,
+	// or 0 if FlushBatch has never recorded one.
+	LastWALCheckpoint(ctx context.Context) (int64, error)
+}
+
+// walCheckpointLSNKey is the context key WithWALCheckpoint/
+// WALCheckpointFromContext use to tell a backend's FlushBatch the WAL LSN
+// (see buffer.wal) this batch covers, so it can record it in the same
+// transaction as the events it inserts.
+type walCheckpointLSNKey struct{}
+
+// WithWALCheckpoint marks ctx with the highest WAL LSN this FlushBatch call
+// covers.
+func WithWALCheckpoint(ctx context.Context, lsn int64) context.Context {
+	return context.WithValue(ctx, walCheckpointLSNKey{}, lsn)
+}
+
+// WALCheckpointFromContext returns the LSN set via WithWALCheckpoint, if
+// any.
+func WALCheckpointFromContext(ctx context.Context) (int64, bool) {
+	lsn, ok := ctx.Value(walCheckpointLSNKey{}).(int64)
+	return lsn, ok
+}
+
+// QueryFilter narrows a Query call. Zero values mean "no filter".
+type QueryFilter struct {
+	SessionUUID string
+	Since       int64 // unix_time, inclusive
+	Until       int64 // unix_time, exclusive (0 means unbounded)
+	Limit       int
+}
+
+// Backend is the storage-agnostic interface the Watcher writes through.
+// Each driver owns its own schema/DDL behind Migrate, so callers never issue
+// driver-specific SQL directly.
+type Backend interface {
+	// Append persists a single event. Drivers that buffer internally may
+	// delay the write; FlushBatch forces everything out.
+	Append(ctx context.Context, event Event) error
+
+	// FlushBatch persists a batch of events in one round-trip/transaction.
+	FlushBatch(ctx context.Context, events []Event) error
+
+	// Query returns events matching filter, most recent first.
+	Query(ctx context.Context, filter QueryFilter) ([]Event, error)
+
+	// Migrate applies the driver's schema (tables/indexes/FTS equivalents).
+	// Safe to call on an already-migrated store.
+	Migrate(ctx context.Context) error
+
+	// Close releases underlying connections/handles.
+	Close() error
+}
+
+// Config carries the subset of cmd/watcher flags needed to construct any
+// driver. Drivers ignore fields that don't apply to them.
+type Config struct {
+	// SQLite / rqlite
+	DBPath string
+
+	// Postgres / rqlite
+	DSN string
+
+	// Redis-backed drivers
+	RedisAddr     string
+	RedisUsername string
+	RedisPassword string
+	RedisDB       int
+	RedisStream   string
+	RedisTLS      bool
+	RedisEncoding StreamEncoding // wire format for stream fields; "" behaves like EncodingJSON
+
+	// RedisSpoolDir, if set, durably spools every event to disk (see
+	// storage/spool) before it is published, so a Redis outage doesn't drop
+	// them. Each Append and a background loop drain the spool into Redis in
+	// order, acking each event once it lands. Empty disables spooling: a
+	// publish failure is returned to the caller as before.
+	RedisSpoolDir      string
+	RedisSpoolMaxBytes int64
+
+	// Encryption-at-rest (sqlite / redis+sqlite). See EncryptionConfig.
+	DBKey          string
+	AllowPlaintext bool
+
+	// Manager, if set, routes this backend's underlying *sql.DB/*RedisClient
+	// through a shared, refcounted registry (see Manager) instead of opening
+	// a dedicated connection - so other in-process subsystems constructed
+	// against the same Manager and the same DBPath/RedisAddr reuse it. Nil
+	// preserves the original behaviour: every Open gets its own connection.
+	Manager *Manager
+}
+
+// Driver constructs a Backend from a Config. Registered via RegisterDriver.
+type Driver func(cfg Config) (Backend, error)
+
+var drivers = map[string]Driver{}
+
+// RegisterDriver makes a storage driver available under name for --storage.
+// Called from each driver's init() (backend_sqlite.go, backend_postgres.go, ...).
+func RegisterDriver(name string, d Driver) {
+	drivers[name] = d
+}
+
+// Open constructs the Backend registered under name (e.g. "sqlite",
+// "postgres", "rqlite", "redis+sqlite") and migrates it.
+func Open(ctx context.Context, name string, cfg Config) (Backend, error) {
+	d, ok := drivers[name]
+	if !ok {
+		return nil, fmt.Errorf("storage: unknown backend %q (known: %s)", name, knownDriverNames())
+	}
+
+	backend, err := d(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("storage: failed to construct backend %q: %w", name, err)
+	}
+
+	if err := backend.Migrate(ctx); err != nil {
+		backend.Close()
+		return nil, fmt.Errorf("storage: failed to migrate backend %q: %w", name, err)
+	}
+
+	return backend, nil
+}
+
+func knownDriverNames() string {
+	names := make([]string, 0, len(drivers))
+	for name := range drivers {
+		names = append(names, name)
+	}
+	return fmt.Sprint(names)
+}
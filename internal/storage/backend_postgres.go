@@ -0,0 +1,324 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	_ "github.com/lib/pq"
+)
+
+func init() {
+	RegisterDriver("postgres", newPostgresBackend)
+}
+
+// postgresSchema mirrors the SQLite schema but replaces FTS5 with a
+// tsvector column plus a GIN index, and raw_events.id with a SERIAL.
+const postgresSchema = `
+CREATE TABLE IF NOT EXISTS raw_events (
+	id BIGSERIAL PRIMARY KEY,
+	session_uuid TEXT NOT NULL,
+	timestamp_utc TIMESTAMPTZ NOT NULL DEFAULT now(),
+	unix_time BIGINT NOT NULL,
+	process_name TEXT NOT NULL,
+	window_title TEXT,
+	window_hwnd BIGINT NOT NULL,
+	input_idle_ms BIGINT DEFAULT 0,
+	input_intensity REAL DEFAULT 0.0,
+	screenshot_path TEXT,
+	end_unix_time BIGINT DEFAULT 0,
+	sample_count INTEGER DEFAULT 1,
+	input_intensity_sum REAL DEFAULT 0.0,
+	input_idle_min BIGINT DEFAULT 0,
+	screenshot_hash BIGINT DEFAULT 0,
+	cpu_percent REAL DEFAULT 0.0,
+	working_set_rss BIGINT DEFAULT 0,
+	io_read_bytes BIGINT DEFAULT 0,
+	io_write_bytes BIGINT DEFAULT 0,
+	handle_count INTEGER DEFAULT 0,
+	host_uuid TEXT DEFAULT '',
+	keystrokes INTEGER DEFAULT 0,
+	mouse_clicks INTEGER DEFAULT 0,
+	mouse_travel_px BIGINT DEFAULT 0
+);
+
+ALTER TABLE raw_events ADD COLUMN IF NOT EXISTS end_unix_time BIGINT DEFAULT 0;
+ALTER TABLE raw_events ADD COLUMN IF NOT EXISTS sample_count INTEGER DEFAULT 1;
+ALTER TABLE raw_events ADD COLUMN IF NOT EXISTS input_intensity_sum REAL DEFAULT 0.0;
+ALTER TABLE raw_events ADD COLUMN IF NOT EXISTS input_idle_min BIGINT DEFAULT 0;
+ALTER TABLE raw_events ADD COLUMN IF NOT EXISTS screenshot_hash BIGINT DEFAULT 0;
+ALTER TABLE raw_events ADD COLUMN IF NOT EXISTS cpu_percent REAL DEFAULT 0.0;
+ALTER TABLE raw_events ADD COLUMN IF NOT EXISTS working_set_rss BIGINT DEFAULT 0;
+ALTER TABLE raw_events ADD COLUMN IF NOT EXISTS io_read_bytes BIGINT DEFAULT 0;
+ALTER TABLE raw_events ADD COLUMN IF NOT EXISTS io_write_bytes BIGINT DEFAULT 0;
+ALTER TABLE raw_events ADD COLUMN IF NOT EXISTS handle_count INTEGER DEFAULT 0;
+ALTER TABLE raw_events ADD COLUMN IF NOT EXISTS host_uuid TEXT DEFAULT '';
+ALTER TABLE raw_events ADD COLUMN IF NOT EXISTS keystrokes INTEGER DEFAULT 0;
+ALTER TABLE raw_events ADD COLUMN IF NOT EXISTS mouse_clicks INTEGER DEFAULT 0;
+ALTER TABLE raw_events ADD COLUMN IF NOT EXISTS mouse_travel_px BIGINT DEFAULT 0;
+
+CREATE INDEX IF NOT EXISTS idx_raw_time ON raw_events(unix_time);
+CREATE INDEX IF NOT EXISTS idx_raw_session ON raw_events(session_uuid);
+CREATE INDEX IF NOT EXISTS idx_raw_process ON raw_events(process_name);
+
+CREATE TABLE IF NOT EXISTS context_enrichment (
+	event_id BIGINT PRIMARY KEY REFERENCES raw_events(id) ON DELETE CASCADE,
+	ocr_content TEXT,
+	vlm_description TEXT,
+	user_intent TEXT,
+	search_vector TSVECTOR
+);
+
+CREATE INDEX IF NOT EXISTS idx_context_fts ON context_enrichment USING GIN(search_vector);
+
+CREATE OR REPLACE FUNCTION trg_context_enrichment_vector() RETURNS trigger AS $$
+BEGIN
+	NEW.search_vector := to_tsvector('english',
+		coalesce(NEW.ocr_content, '') || ' ' ||
+		coalesce(NEW.vlm_description, '') || ' ' ||
+		coalesce(NEW.user_intent, ''));
+	RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS trg_context_enrichment_vector ON context_enrichment;
+CREATE TRIGGER trg_context_enrichment_vector
+	BEFORE INSERT OR UPDATE ON context_enrichment
+	FOR EACH ROW EXECUTE FUNCTION trg_context_enrichment_vector();
+
+CREATE TABLE IF NOT EXISTS buffer_pressure_events (
+	id BIGSERIAL PRIMARY KEY,
+	unix_time BIGINT NOT NULL,
+	on_battery BOOLEAN NOT NULL DEFAULT false,
+	disk_queue_length REAL NOT NULL DEFAULT 0.0,
+	cpu_percent REAL NOT NULL DEFAULT 0.0,
+	deferral_ms BIGINT NOT NULL DEFAULT 0,
+	relaxed_durability BOOLEAN NOT NULL DEFAULT false
+);
+
+CREATE INDEX IF NOT EXISTS idx_pressure_time ON buffer_pressure_events(unix_time);
+
+CREATE TABLE IF NOT EXISTS wal_checkpoint (
+	id SMALLINT PRIMARY KEY CHECK (id = 1),
+	lsn BIGINT NOT NULL DEFAULT 0
+);
+
+CREATE TABLE IF NOT EXISTS window_snapshots (
+	id BIGSERIAL PRIMARY KEY,
+	unix_time BIGINT NOT NULL,
+	host_uuid TEXT DEFAULT '',
+	window_hwnd BIGINT NOT NULL,
+	process_name TEXT NOT NULL,
+	window_title TEXT,
+	rect_left INTEGER DEFAULT 0,
+	rect_top INTEGER DEFAULT 0,
+	rect_right INTEGER DEFAULT 0,
+	rect_bottom INTEGER DEFAULT 0,
+	z_order INTEGER NOT NULL DEFAULT 0
+);
+
+CREATE INDEX IF NOT EXISTS idx_window_snapshots_time ON window_snapshots(unix_time);
+`
+
+// postgresBackend is the Backend driver for a shared/clustered deployment
+// backed by Postgres, used in multi-machine setups where several Watchers
+// need a common store.
+type postgresBackend struct {
+	db *sql.DB
+}
+
+func newPostgresBackend(cfg Config) (Backend, error) {
+	if cfg.DSN == "" {
+		return nil, fmt.Errorf("postgres backend requires DSN")
+	}
+
+	db, err := sql.Open("postgres", cfg.DSN)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open postgres connection: %w", err)
+	}
+
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to ping postgres: %w", err)
+	}
+
+	return &postgresBackend{db: db}, nil
+}
+
+func (b *postgresBackend) Migrate(ctx context.Context) error {
+	_, err := b.db.ExecContext(ctx, postgresSchema)
+	return err
+}
+
+func (b *postgresBackend) Append(ctx context.Context, event Event) error {
+	return b.FlushBatch(ctx, []Event{event})
+}
+
+func (b *postgresBackend) FlushBatch(ctx context.Context, events []Event) error {
+	if len(events) == 0 {
+		return nil
+	}
+
+	tx, err := b.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	// Record the WAL checkpoint in the same transaction as the inserts
+	// below, so a crash can't commit one without the other - see the
+	// sqlite backend's FlushBatch for the matching comment.
+	if lsn, ok := WALCheckpointFromContext(ctx); ok {
+		if _, err := tx.ExecContext(ctx, `
+			INSERT INTO wal_checkpoint (id, lsn) VALUES (1, $1)
+			ON CONFLICT (id) DO UPDATE SET lsn = excluded.lsn
+		`, lsn); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("failed to record WAL checkpoint: %w", err)
+		}
+	}
+
+	stmt, err := tx.PrepareContext(ctx, `
+		INSERT INTO raw_events
+		(session_uuid, unix_time, process_name, window_title, window_hwnd, input_idle_ms, input_intensity, screenshot_path,
+		 end_unix_time, sample_count, input_intensity_sum, input_idle_min, screenshot_hash,
+		 cpu_percent, working_set_rss, io_read_bytes, io_write_bytes, handle_count, host_uuid,
+		 keystrokes, mouse_clicks, mouse_travel_px)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21, $22)
+	`)
+	if err != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to prepare statement: %w", err)
+	}
+	defer stmt.Close()
+
+	for _, e := range events {
+		if _, err := stmt.ExecContext(ctx, e.SessionUUID, e.UnixTime, e.ProcessName, e.WindowTitle,
+			e.WindowHandle, e.InputIdleMs, e.InputIntensity, e.ScreenshotPath,
+			e.EndUnixTime, e.SampleCount, e.InputIntensitySum, e.InputIdleMin, e.ScreenshotHash,
+			e.CPUPercent, e.WorkingSetRSS, e.IOReadBytes, e.IOWriteBytes, e.HandleCount, e.HostUUID,
+			e.Keystrokes, e.MouseClicks, e.MouseTravelPx); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("failed to insert event: %w", err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (b *postgresBackend) Query(ctx context.Context, filter QueryFilter) ([]Event, error) {
+	query := "SELECT session_uuid, unix_time, process_name, window_title, window_hwnd, input_idle_ms, input_intensity, screenshot_path, end_unix_time, sample_count, input_intensity_sum, input_idle_min, screenshot_hash, cpu_percent, working_set_rss, io_read_bytes, io_write_bytes, handle_count, host_uuid, keystrokes, mouse_clicks, mouse_travel_px FROM raw_events WHERE 1=1"
+	var args []interface{}
+	n := 1
+
+	if filter.SessionUUID != "" {
+		query += fmt.Sprintf(" AND session_uuid = $%d", n)
+		args = append(args, filter.SessionUUID)
+		n++
+	}
+	if filter.Since > 0 {
+		query += fmt.Sprintf(" AND unix_time >= $%d", n)
+		args = append(args, filter.Since)
+		n++
+	}
+	if filter.Until > 0 {
+		query += fmt.Sprintf(" AND unix_time < $%d", n)
+		args = append(args, filter.Until)
+		n++
+	}
+	query += " ORDER BY unix_time DESC"
+	if filter.Limit > 0 {
+		query += fmt.Sprintf(" LIMIT $%d", n)
+		args = append(args, filter.Limit)
+	}
+
+	rows, err := b.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query events: %w", err)
+	}
+	defer rows.Close()
+
+	var events []Event
+	for rows.Next() {
+		var e Event
+		if err := rows.Scan(&e.SessionUUID, &e.UnixTime, &e.ProcessName, &e.WindowTitle,
+			&e.WindowHandle, &e.InputIdleMs, &e.InputIntensity, &e.ScreenshotPath,
+			&e.EndUnixTime, &e.SampleCount, &e.InputIntensitySum, &e.InputIdleMin, &e.ScreenshotHash,
+			&e.CPUPercent, &e.WorkingSetRSS, &e.IOReadBytes, &e.IOWriteBytes, &e.HandleCount, &e.HostUUID,
+			&e.Keystrokes, &e.MouseClicks, &e.MouseTravelPx); err != nil {
+			return nil, fmt.Errorf("failed to scan event: %w", err)
+		}
+		events = append(events, e)
+	}
+	return events, rows.Err()
+}
+
+// RecordPressureEvent persists event to buffer_pressure_events, implementing
+// storage.PressureEventRecorder.
+func (b *postgresBackend) RecordPressureEvent(ctx context.Context, event PressureEvent) error {
+	_, err := b.db.ExecContext(ctx, `
+		INSERT INTO buffer_pressure_events
+		(unix_time, on_battery, disk_queue_length, cpu_percent, deferral_ms, relaxed_durability)
+		VALUES ($1, $2, $3, $4, $5, $6)
+	`, event.UnixTime, event.OnBattery, event.DiskQueueLength, event.CPUPercent, event.DeferralMs, event.RelaxedDurability)
+	if err != nil {
+		return fmt.Errorf("failed to insert pressure event: %w", err)
+	}
+	return nil
+}
+
+// RecordWindowSnapshots persists windows to window_snapshots in a single
+// transaction, implementing WindowSnapshotRecorder.
+func (b *postgresBackend) RecordWindowSnapshots(ctx context.Context, windows []WindowSnapshot) error {
+	if len(windows) == 0 {
+		return nil
+	}
+
+	tx, err := b.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	stmt, err := tx.PrepareContext(ctx, `
+		INSERT INTO window_snapshots
+		(unix_time, host_uuid, window_hwnd, process_name, window_title, rect_left, rect_top, rect_right, rect_bottom, z_order)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+	`)
+	if err != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to prepare statement: %w", err)
+	}
+	defer stmt.Close()
+
+	for _, w := range windows {
+		if _, err := stmt.ExecContext(ctx,
+			w.UnixTime, w.HostUUID, w.WindowHandle, w.ProcessName, w.WindowTitle,
+			w.Left, w.Top, w.Right, w.Bottom, w.ZOrder,
+		); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("failed to insert window snapshot: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// LastWALCheckpoint implements storage.WALCheckpointStore, reading the LSN
+// last recorded by FlushBatch.
+func (b *postgresBackend) LastWALCheckpoint(ctx context.Context) (int64, error) {
+	var lsn int64
+	err := b.db.QueryRowContext(ctx, "SELECT lsn FROM wal_checkpoint WHERE id = 1").Scan(&lsn)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to read WAL checkpoint: %w", err)
+	}
+	return lsn, nil
+}
+
+func (b *postgresBackend) Close() error {
+	return b.db.Close()
+}
@@ -0,0 +1,395 @@
+package storage
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"mnemosyne/internal/storage/spool"
+)
+
+// spoolDrainInterval is how often the drain loop retries publishing
+// whatever the spool is still holding once a publish has failed.
+const spoolDrainInterval = 5 * time.Second
+
+func init() {
+	RegisterDriver("redis+sqlite", newRedisSQLiteBackend)
+}
+
+// leaseTTL is how long a Watcher's leadership survives without a heartbeat.
+// Heartbeats fire at ttl/3, so a crashed leader is replaced within ~2*ttl.
+const leaseTTL = 15 * time.Second
+
+// redisSQLiteBackend publishes events to a Redis Stream for the fast path
+// (matching the existing monitor.flush Redis behaviour) while a local
+// sqliteBackend remains available for Query, so readers don't need a
+// separate Processor tier running to inspect recent activity.
+//
+// Multiple Watchers may share one stream (e.g. several capture machines
+// feeding one central Redis), but only the lease holder mirrors entries
+// into its own local SQLite file - followers keep capturing into the
+// stream without interruption, they just don't drain it locally. When a
+// follower is promoted it replays everything published since its own
+// lastDrainedID before resuming normal mirroring, so its Query results
+// don't have a gap from the time it wasn't leader.
+type redisSQLiteBackend struct {
+	redis    *RedisClient
+	sqlite   *sqliteBackend
+	stream   string
+	lease    *Lease
+	encoding StreamEncoding
+
+	// spool durably holds events a failed publish couldn't hand to Redis
+	// (see storage/spool). Nil when RedisSpoolDir is unset, in which case
+	// Append returns publish errors to the caller exactly as before.
+	spool     *spool.Spool
+	spoolStop chan struct{}
+	spoolDone chan struct{}
+
+	// mgr/mgrKey are set when Config.Manager routed the Redis connection
+	// through a shared registry - see (*Manager).Close.
+	mgr    *Manager
+	mgrKey string
+
+	mu            sync.Mutex
+	lastDrainedID string
+}
+
+func newRedisSQLiteBackend(cfg Config) (Backend, error) {
+	if cfg.RedisAddr == "" {
+		return nil, fmt.Errorf("redis+sqlite backend requires RedisAddr")
+	}
+
+	openRedis := func() (*RedisClient, error) {
+		return NewRedisClientWithOptions(cfg.RedisAddr, RedisClientOptions{
+			Username: cfg.RedisUsername,
+			Password: cfg.RedisPassword,
+			DB:       cfg.RedisDB,
+			UseTLS:   cfg.RedisTLS,
+		})
+	}
+
+	var redisClient *RedisClient
+	var err error
+	if cfg.Manager != nil {
+		redisClient, err = cfg.Manager.GetRedis(cfg.RedisAddr, openRedis)
+	} else {
+		redisClient, err = openRedis()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	sqliteAny, err := newSQLiteBackend(cfg)
+	if err != nil {
+		closeRedisConn(cfg, redisClient)
+		return nil, err
+	}
+
+	stream := cfg.RedisStream
+	if stream == "" {
+		// Hash-tagged so Cluster mode (see ParseRedisURI) routes every XADD
+		// for this stream to a single slot instead of erroring out on a
+		// cross-slot command.
+		stream = "{mnemosyne}:events"
+	}
+
+	encoding := cfg.RedisEncoding
+	if encoding == "" {
+		encoding = EncodingJSON
+	}
+
+	b := &redisSQLiteBackend{
+		redis:    redisClient,
+		sqlite:   sqliteAny.(*sqliteBackend),
+		stream:   stream,
+		lease:    NewLease(redisClient.client, stream+":leader", leaseOwnerID(), leaseTTL),
+		encoding: encoding,
+		mgr:      cfg.Manager,
+		mgrKey:   cfg.RedisAddr,
+	}
+	b.lease.OnAcquire(b.catchUp)
+
+	if cfg.RedisSpoolDir != "" {
+		sp, err := spool.Open(cfg.RedisSpoolDir, cfg.RedisSpoolMaxBytes)
+		if err != nil {
+			closeRedisConn(cfg, redisClient)
+			return nil, fmt.Errorf("failed to open redis spool %q: %w", cfg.RedisSpoolDir, err)
+		}
+		b.spool = sp
+	}
+
+	return b, nil
+}
+
+// closeRedisConn releases client the same way newRedisSQLiteBackend
+// acquired it: through cfg.Manager if one was given (a refcounted release,
+// not necessarily closing the underlying connection), or directly otherwise.
+func closeRedisConn(cfg Config, client *RedisClient) {
+	if cfg.Manager != nil {
+		cfg.Manager.Close(cfg.RedisAddr)
+		return
+	}
+	client.Close()
+}
+
+func leaseOwnerID() string {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "unknown"
+	}
+	return fmt.Sprintf("%s-%d", host, os.Getpid())
+}
+
+// Lease exposes the backend's leader-election lease so callers (e.g.
+// monitor.Monitor, via a type assertion analogous to DB() for the sqlite
+// backend) can observe/report leadership changes.
+func (b *redisSQLiteBackend) Lease() *Lease {
+	return b.lease
+}
+
+// Spool exposes the backend's overflow spool (nil if RedisSpoolDir was
+// unset) so callers - e.g. monitor.Monitor, via the same type-assertion
+// pattern as Lease - can report its depth as a metric/log line.
+func (b *redisSQLiteBackend) Spool() *spool.Spool {
+	return b.spool
+}
+
+func (b *redisSQLiteBackend) Migrate(ctx context.Context) error {
+	if err := b.sqlite.Migrate(ctx); err != nil {
+		return err
+	}
+	b.lease.Start(ctx)
+
+	if b.spool != nil {
+		b.spoolStop = make(chan struct{})
+		b.spoolDone = make(chan struct{})
+		go b.drainSpool(ctx)
+	}
+	return nil
+}
+
+func eventToStreamFields(event Event) map[string]interface{} {
+	data := map[string]interface{}{
+		"session_uuid": event.SessionUUID,
+		"unix_time":    event.UnixTime,
+		"process_name": event.ProcessName,
+		"window_title": event.WindowTitle,
+		"window_hwnd":  event.WindowHandle,
+		"input_idle":   event.InputIdleMs,
+		"intensity":    event.InputIntensity,
+	}
+	if len(event.ScreenshotData) > 0 {
+		data["image_data"] = base64.StdEncoding.EncodeToString(event.ScreenshotData)
+	}
+	if event.ScreenshotHash != 0 {
+		data["screenshot_hash"] = event.ScreenshotHash
+	}
+	return data
+}
+
+func eventFromStreamFields(values map[string]interface{}) Event {
+	str := func(key string) string {
+		v, _ := values[key].(string)
+		return v
+	}
+	num := func(key string) int64 {
+		n, _ := strconv.ParseInt(str(key), 10, 64)
+		return n
+	}
+
+	event := Event{
+		SessionUUID:  str("session_uuid"),
+		UnixTime:     num("unix_time"),
+		ProcessName:  str("process_name"),
+		WindowTitle:  str("window_title"),
+		WindowHandle: num("window_hwnd"),
+		InputIdleMs:  num("input_idle"),
+	}
+	if f, err := strconv.ParseFloat(str("intensity"), 32); err == nil {
+		event.InputIntensity = float32(f)
+	}
+	if data := str("image_data"); data != "" {
+		if decoded, err := base64.StdEncoding.DecodeString(data); err == nil {
+			event.ScreenshotData = decoded
+		}
+	}
+	if h, err := strconv.ParseUint(str("screenshot_hash"), 10, 64); err == nil {
+		event.ScreenshotHash = h
+	}
+	return event
+}
+
+// Append durably spools event, then attempts to publish it - and everything
+// still ahead of it - to Redis in spool order, only acking each one once its
+// XADD has actually landed. Routing every event through the spool first,
+// rather than publishing live and falling back to the spool only on
+// failure, is what keeps "never replayed out of order" true across the
+// live/spool boundary and not just within a single drain: a live Append can
+// never publish ahead of an older event the drain hasn't reached yet,
+// because it's the same code path draining both.
+func (b *redisSQLiteBackend) Append(ctx context.Context, event Event) error {
+	if b.spool != nil {
+		if _, err := b.spool.Append(event); err != nil {
+			return fmt.Errorf("spool append failed: %w", err)
+		}
+		b.tryDrainSpool(ctx)
+		return nil
+	}
+
+	fields, err := EncodeStreamEvent(b.encoding, event)
+	if err != nil {
+		return err
+	}
+	return b.publishAndMirror(ctx, event, fields)
+}
+
+// publishAndMirror XADDs fields (the already-encoded form of event) to the
+// stream and, only if this process currently holds the lease, mirrors event
+// into the local SQLite file - the one place both the no-spool fast path in
+// Append and tryDrainSpool agree on what "publish" means.
+func (b *redisSQLiteBackend) publishAndMirror(ctx context.Context, event Event, fields map[string]interface{}) error {
+	id, err := b.redis.PublishEventWithID(ctx, b.stream, fields)
+	if err != nil {
+		return err
+	}
+
+	// Only the lease holder mirrors into the local SQLite file; followers
+	// have still durably captured the event into the shared stream.
+	if !b.lease.Held() {
+		return nil
+	}
+	if err := b.sqlite.Append(ctx, event); err != nil {
+		return err
+	}
+
+	b.mu.Lock()
+	b.lastDrainedID = id
+	b.mu.Unlock()
+	return nil
+}
+
+func (b *redisSQLiteBackend) FlushBatch(ctx context.Context, events []Event) error {
+	for _, e := range events {
+		if err := b.Append(ctx, e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// catchUp runs whenever this process is (re)promoted to lease holder. It
+// replays every stream entry published since the last one we personally
+// drained, so a Watcher that just regained leadership doesn't leave a gap
+// in its local mirror for the period another Watcher was leading.
+func (b *redisSQLiteBackend) catchUp() {
+	b.mu.Lock()
+	after := b.lastDrainedID
+	b.mu.Unlock()
+
+	start := "-"
+	if after != "" {
+		start = "(" + after
+	}
+
+	ctx := context.Background()
+	msgs, err := b.redis.client.XRange(ctx, b.stream, start, "+").Result()
+	if err != nil {
+		log.Printf("lease catch-up: failed to read stream %q from %q: %v", b.stream, start, err)
+		return
+	}
+
+	for _, msg := range msgs {
+		if err := b.sqlite.Append(ctx, DecodeStreamEvent(msg.Values)); err != nil {
+			log.Printf("lease catch-up: failed to mirror entry %s: %v", msg.ID, err)
+			return
+		}
+		b.mu.Lock()
+		b.lastDrainedID = msg.ID
+		b.mu.Unlock()
+	}
+}
+
+// Query reads from the local SQLite mirror; events published while another
+// Watcher held the lease and not yet caught up via catchUp are not visible
+// here.
+func (b *redisSQLiteBackend) Query(ctx context.Context, filter QueryFilter) ([]Event, error) {
+	return b.sqlite.Query(ctx, filter)
+}
+
+// drainSpool retries publishing whatever the spool is holding every
+// spoolDrainInterval until ctx is cancelled or Close is called, whichever
+// comes first.
+func (b *redisSQLiteBackend) drainSpool(ctx context.Context) {
+	defer close(b.spoolDone)
+
+	ticker := time.NewTicker(spoolDrainInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-b.spoolStop:
+			return
+		case <-ticker.C:
+			b.tryDrainSpool(ctx)
+		}
+	}
+}
+
+// tryDrainSpool replays every un-acked spool record in order, publishing
+// each to Redis and mirroring it exactly as Append would (via
+// publishAndMirror), acking only once the publish succeeds. It stops at the
+// first publish failure - Redis is presumably still down - and leaves the
+// remainder for the next caller, whether that's the drainSpool ticker or the
+// next live Append, so entries are never replayed out of order.
+func (b *redisSQLiteBackend) tryDrainSpool(ctx context.Context) {
+	records, err := b.spool.Iterate(b.spool.Cursor())
+	if err != nil {
+		log.Printf("redis+sqlite: spool drain: failed to read un-acked entries: %v", err)
+		return
+	}
+
+	for _, rec := range records {
+		fields, err := EncodeStreamEvent(b.encoding, rec.Event)
+		if err != nil {
+			// Not retryable - the same event will fail to encode forever -
+			// so drop it rather than spin on it and block everything behind.
+			log.Printf("redis+sqlite: spool drain: dropping unencodable seq %d: %v", rec.Seq, err)
+			if err := b.spool.Ack(rec.Seq); err != nil {
+				log.Printf("redis+sqlite: spool drain: failed to ack seq %d: %v", rec.Seq, err)
+			}
+			continue
+		}
+
+		if err := b.publishAndMirror(ctx, rec.Event, fields); err != nil {
+			return
+		}
+
+		if err := b.spool.Ack(rec.Seq); err != nil {
+			log.Printf("redis+sqlite: spool drain: failed to ack seq %d: %v", rec.Seq, err)
+			return
+		}
+	}
+}
+
+func (b *redisSQLiteBackend) Close() error {
+	if b.spool != nil {
+		close(b.spoolStop)
+		<-b.spoolDone
+		b.spool.Close()
+	}
+	b.lease.Stop()
+	if b.mgr != nil {
+		b.mgr.Close(b.mgrKey)
+	} else {
+		b.redis.Close()
+	}
+	return b.sqlite.Close()
+}
@@ -0,0 +1,255 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+func init() {
+	RegisterDriver("rqlite", newRqliteBackend)
+}
+
+// rqliteBackend drives an rqlite cluster over its HTTP API, reusing the
+// SQLite DDL (rqlite is a Raft-replicated SQLite) so the schema stays a
+// single source of truth between the "sqlite" and "rqlite" drivers.
+type rqliteBackend struct {
+	baseURL string
+	http    *http.Client
+}
+
+func newRqliteBackend(cfg Config) (Backend, error) {
+	if cfg.DSN == "" {
+		return nil, fmt.Errorf("rqlite backend requires DSN (e.g. http://localhost:4001)")
+	}
+
+	return &rqliteBackend{
+		baseURL: cfg.DSN,
+		http:    &http.Client{Timeout: 10 * time.Second},
+	}, nil
+}
+
+type rqliteExecuteRequest [][]interface{}
+
+type rqliteResponse struct {
+	Results []struct {
+		Error string `json:"error"`
+	} `json:"results"`
+}
+
+type rqliteQueryResponse struct {
+	Results []struct {
+		Columns []string        `json:"columns"`
+		Values  [][]interface{} `json:"values"`
+		Error   string          `json:"error"`
+	} `json:"results"`
+}
+
+// execute posts one or more statements (each [sql, args...]) to the rqlite
+// /db/execute endpoint inside a single implicit transaction.
+func (b *rqliteBackend) execute(ctx context.Context, statements rqliteExecuteRequest) error {
+	body, err := json.Marshal(statements)
+	if err != nil {
+		return fmt.Errorf("failed to marshal statements: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost,
+		b.baseURL+"/db/execute?transaction", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := b.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("rqlite execute request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var parsed rqliteResponse
+	if err := json.NewDecoder(resp.Body).Decode(&parsed); err != nil {
+		return fmt.Errorf("failed to decode rqlite response: %w", err)
+	}
+	for _, r := range parsed.Results {
+		if r.Error != "" {
+			return fmt.Errorf("rqlite statement error: %s", r.Error)
+		}
+	}
+	return nil
+}
+
+func (b *rqliteBackend) Migrate(ctx context.Context) error {
+	if err := b.execute(ctx, rqliteExecuteRequest{{sqliteSchema}}); err != nil {
+		return err
+	}
+
+	// Run each ALTER separately (not batched with the schema above): SQLite
+	// has no ADD COLUMN IF NOT EXISTS, so re-running this against an
+	// already-migrated database always errors "duplicate column name" -
+	// batching it with the CREATE TABLE statements under rqlite's
+	// single-transaction /db/execute would roll those back too.
+	for _, stmt := range coalesceColumns {
+		if err := b.execute(ctx, rqliteExecuteRequest{{stmt}}); err != nil {
+			if strings.Contains(err.Error(), "duplicate column name") {
+				continue
+			}
+			return err
+		}
+	}
+	for _, stmt := range dedupeColumns {
+		if err := b.execute(ctx, rqliteExecuteRequest{{stmt}}); err != nil {
+			if strings.Contains(err.Error(), "duplicate column name") {
+				continue
+			}
+			return err
+		}
+	}
+	for _, stmt := range processStatsColumns {
+		if err := b.execute(ctx, rqliteExecuteRequest{{stmt}}); err != nil {
+			if strings.Contains(err.Error(), "duplicate column name") {
+				continue
+			}
+			return err
+		}
+	}
+	for _, stmt := range hostIDColumns {
+		if err := b.execute(ctx, rqliteExecuteRequest{{stmt}}); err != nil {
+			if strings.Contains(err.Error(), "duplicate column name") {
+				continue
+			}
+			return err
+		}
+	}
+	for _, stmt := range inputHookColumns {
+		if err := b.execute(ctx, rqliteExecuteRequest{{stmt}}); err != nil {
+			if strings.Contains(err.Error(), "duplicate column name") {
+				continue
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+func (b *rqliteBackend) Append(ctx context.Context, event Event) error {
+	return b.FlushBatch(ctx, []Event{event})
+}
+
+func (b *rqliteBackend) FlushBatch(ctx context.Context, events []Event) error {
+	if len(events) == 0 {
+		return nil
+	}
+
+	const insertSQL = `INSERT INTO raw_events
+		(session_uuid, unix_time, process_name, window_title, window_hwnd, input_idle_ms, input_intensity, screenshot_path)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`
+
+	statements := make(rqliteExecuteRequest, 0, len(events))
+	for _, e := range events {
+		statements = append(statements, []interface{}{
+			insertSQL, e.SessionUUID, e.UnixTime, e.ProcessName, e.WindowTitle,
+			e.WindowHandle, e.InputIdleMs, e.InputIntensity, e.ScreenshotPath,
+		})
+	}
+
+	return b.execute(ctx, statements)
+}
+
+func (b *rqliteBackend) Query(ctx context.Context, filter QueryFilter) ([]Event, error) {
+	query := "SELECT session_uuid, unix_time, process_name, window_title, window_hwnd, input_idle_ms, input_intensity, screenshot_path FROM raw_events WHERE 1=1"
+	var args []interface{}
+
+	if filter.SessionUUID != "" {
+		query += " AND session_uuid = ?"
+		args = append(args, filter.SessionUUID)
+	}
+	if filter.Since > 0 {
+		query += " AND unix_time >= ?"
+		args = append(args, filter.Since)
+	}
+	if filter.Until > 0 {
+		query += " AND unix_time < ?"
+		args = append(args, filter.Until)
+	}
+	query += " ORDER BY unix_time DESC"
+	if filter.Limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, filter.Limit)
+	}
+
+	statement := append([]interface{}{query}, args...)
+	body, err := json.Marshal(rqliteExecuteRequest{statement})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal query: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, b.baseURL+"/db/query", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := b.http.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("rqlite query request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var parsed rqliteQueryResponse
+	if err := json.NewDecoder(resp.Body).Decode(&parsed); err != nil {
+		return nil, fmt.Errorf("failed to decode rqlite response: %w", err)
+	}
+	if len(parsed.Results) == 0 {
+		return nil, nil
+	}
+	result := parsed.Results[0]
+	if result.Error != "" {
+		return nil, fmt.Errorf("rqlite query error: %s", result.Error)
+	}
+
+	events := make([]Event, 0, len(result.Values))
+	for _, row := range result.Values {
+		e, err := scanRqliteRow(row)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, e)
+	}
+	return events, nil
+}
+
+// scanRqliteRow converts a positional JSON row (as returned by /db/query)
+// into an Event, matching the column order of the SELECT above.
+func scanRqliteRow(row []interface{}) (Event, error) {
+	if len(row) != 8 {
+		return Event{}, fmt.Errorf("unexpected rqlite row width: %d", len(row))
+	}
+
+	toInt64 := func(v interface{}) int64 {
+		f, _ := v.(float64)
+		return int64(f)
+	}
+	toString := func(v interface{}) string {
+		s, _ := v.(string)
+		return s
+	}
+
+	return Event{
+		SessionUUID:    toString(row[0]),
+		UnixTime:       toInt64(row[1]),
+		ProcessName:    toString(row[2]),
+		WindowTitle:    toString(row[3]),
+		WindowHandle:   toInt64(row[4]),
+		InputIdleMs:    toInt64(row[5]),
+		InputIntensity: float32(row[6].(float64)),
+		ScreenshotPath: toString(row[7]),
+	}, nil
+}
+
+func (b *rqliteBackend) Close() error {
+	return nil
+}
@@ -0,0 +1,545 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+
+	_ "modernc.org/sqlite"
+
+	"mnemosyne/internal/faultinject"
+)
+
+func init() {
+	RegisterDriver("sqlite", newSQLiteBackend)
+}
+
+// sqliteSchema is the DDL for the single-writer SQLite backend: raw_events,
+// a cascading context_enrichment table and an FTS5 index kept in sync via
+// triggers, matching the shape the storage tests assert against.
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS raw_events (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	session_uuid TEXT NOT NULL,
+	timestamp_utc TEXT NOT NULL DEFAULT (datetime('now')),
+	unix_time INTEGER NOT NULL,
+	process_name TEXT NOT NULL,
+	window_title TEXT,
+	window_hwnd INTEGER NOT NULL,
+	input_idle_ms INTEGER DEFAULT 0,
+	input_intensity REAL DEFAULT 0.0,
+	screenshot_path TEXT,
+	end_unix_time INTEGER DEFAULT 0,
+	sample_count INTEGER DEFAULT 1,
+	input_intensity_sum REAL DEFAULT 0.0,
+	input_idle_min INTEGER DEFAULT 0,
+	screenshot_hash INTEGER DEFAULT 0,
+	cpu_percent REAL DEFAULT 0.0,
+	working_set_rss INTEGER DEFAULT 0,
+	io_read_bytes INTEGER DEFAULT 0,
+	io_write_bytes INTEGER DEFAULT 0,
+	handle_count INTEGER DEFAULT 0,
+	host_uuid TEXT DEFAULT '',
+	keystrokes INTEGER DEFAULT 0,
+	mouse_clicks INTEGER DEFAULT 0,
+	mouse_travel_px INTEGER DEFAULT 0
+);
+
+CREATE INDEX IF NOT EXISTS idx_raw_time ON raw_events(unix_time);
+CREATE INDEX IF NOT EXISTS idx_raw_session ON raw_events(session_uuid);
+CREATE INDEX IF NOT EXISTS idx_raw_process ON raw_events(process_name);
+
+CREATE TABLE IF NOT EXISTS context_enrichment (
+	event_id INTEGER PRIMARY KEY REFERENCES raw_events(id) ON DELETE CASCADE,
+	ocr_content TEXT,
+	vlm_description TEXT,
+	user_intent TEXT
+);
+
+CREATE VIRTUAL TABLE IF NOT EXISTS fts_search USING fts5(
+	ocr_content, vlm_description, user_intent, content=''
+);
+
+CREATE TRIGGER IF NOT EXISTS trg_fts_insert AFTER INSERT ON context_enrichment BEGIN
+	INSERT INTO fts_search(rowid, ocr_content, vlm_description, user_intent)
+	VALUES (new.event_id, new.ocr_content, new.vlm_description, new.user_intent);
+END;
+
+CREATE TRIGGER IF NOT EXISTS trg_fts_update AFTER UPDATE ON context_enrichment BEGIN
+	UPDATE fts_search SET ocr_content = new.ocr_content,
+		vlm_description = new.vlm_description,
+		user_intent = new.user_intent
+	WHERE rowid = new.event_id;
+END;
+
+CREATE TRIGGER IF NOT EXISTS trg_fts_delete AFTER DELETE ON context_enrichment BEGIN
+	DELETE FROM fts_search WHERE rowid = old.event_id;
+END;
+
+CREATE TABLE IF NOT EXISTS buffer_pressure_events (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	unix_time INTEGER NOT NULL,
+	on_battery INTEGER NOT NULL DEFAULT 0,
+	disk_queue_length REAL NOT NULL DEFAULT 0.0,
+	cpu_percent REAL NOT NULL DEFAULT 0.0,
+	deferral_ms INTEGER NOT NULL DEFAULT 0,
+	relaxed_durability INTEGER NOT NULL DEFAULT 0
+);
+
+CREATE INDEX IF NOT EXISTS idx_pressure_time ON buffer_pressure_events(unix_time);
+
+CREATE TABLE IF NOT EXISTS wal_checkpoint (
+	id INTEGER PRIMARY KEY CHECK (id = 1),
+	lsn INTEGER NOT NULL DEFAULT 0
+);
+
+CREATE TABLE IF NOT EXISTS window_snapshots (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	unix_time INTEGER NOT NULL,
+	host_uuid TEXT DEFAULT '',
+	window_hwnd INTEGER NOT NULL,
+	process_name TEXT NOT NULL,
+	window_title TEXT,
+	rect_left INTEGER DEFAULT 0,
+	rect_top INTEGER DEFAULT 0,
+	rect_right INTEGER DEFAULT 0,
+	rect_bottom INTEGER DEFAULT 0,
+	z_order INTEGER NOT NULL DEFAULT 0
+);
+
+CREATE INDEX IF NOT EXISTS idx_window_snapshots_time ON window_snapshots(unix_time);
+`
+
+// sqliteBackend is the single-machine Backend driver: a modernc.org/sqlite
+// file with the schema above.
+type sqliteBackend struct {
+	// mu guards db itself (not the duration of each query) against the
+	// brief window VacuumSwap closes and reopens it in - see conn().
+	mu sync.RWMutex
+	db *sql.DB
+
+	cfg Config // retained so VacuumSwap can reopen with the same path/pragmas/encryption
+
+	// mgr/mgrKey are set when Config.Manager routed db through a shared
+	// registry, so Close releases a reference instead of closing the
+	// handle outright - see (*Manager).Close.
+	mgr    *Manager
+	mgrKey string
+}
+
+func newSQLiteBackend(cfg Config) (Backend, error) {
+	if cfg.DBPath == "" {
+		return nil, fmt.Errorf("sqlite backend requires DBPath")
+	}
+
+	open := func() (*sql.DB, error) { return openSQLiteDB(cfg) }
+
+	var db *sql.DB
+	var err error
+	if cfg.Manager != nil {
+		db, err = cfg.Manager.GetSQL(cfg.DBPath, open)
+	} else {
+		db, err = open()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &sqliteBackend{db: db, cfg: cfg, mgr: cfg.Manager, mgrKey: cfg.DBPath}, nil
+}
+
+// conn returns the live *sql.DB, synchronized against VacuumSwap swapping it
+// out from under concurrent callers. The returned handle can still error
+// with "database is closed" if a swap lands mid-call; callers already treat
+// backend errors as best-effort and log them (see monitor.recordPressureEvent
+// and friends), which is an acceptable cost for the brief vacuum window.
+func (b *sqliteBackend) conn() *sql.DB {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.db
+}
+
+// openSQLiteDB opens cfg.DBPath, applies encryption-at-rest (if
+// configured) and the pragmas this backend depends on. Factored out of
+// newSQLiteBackend so it can run exactly once per path even when several
+// callers share the connection via Config.Manager.
+func openSQLiteDB(cfg Config) (*sql.DB, error) {
+	db, err := sql.Open("sqlite", fmt.Sprintf("file:%s?_foreign_keys=on", cfg.DBPath))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+
+	if err := applyEncryption(db, cfg.DBPath, EncryptionConfig{Key: cfg.DBKey, AllowPlaintext: cfg.AllowPlaintext}); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	pragmas := []string{
+		"PRAGMA journal_mode = WAL",
+		"PRAGMA synchronous = NORMAL",
+		"PRAGMA busy_timeout = 5000",
+		"PRAGMA foreign_keys = ON",
+	}
+	for _, pragma := range pragmas {
+		if _, err := db.Exec(pragma); err != nil {
+			db.Close()
+			return nil, fmt.Errorf("failed to set pragma %q: %w", pragma, err)
+		}
+	}
+
+	return db, nil
+}
+
+func (b *sqliteBackend) Migrate(ctx context.Context) error {
+	db := b.conn()
+	if _, err := db.ExecContext(ctx, sqliteSchema); err != nil {
+		return err
+	}
+	if err := migrateCoalesceColumns(ctx, db); err != nil {
+		return err
+	}
+	if err := migrateColumns(ctx, db, dedupeColumns); err != nil {
+		return err
+	}
+	if err := migrateColumns(ctx, db, processStatsColumns); err != nil {
+		return err
+	}
+	if err := migrateColumns(ctx, db, hostIDColumns); err != nil {
+		return err
+	}
+	return migrateColumns(ctx, db, inputHookColumns)
+}
+
+// coalesceColumns are the run-length-dedup columns added to raw_events after
+// its initial release. CREATE TABLE IF NOT EXISTS above only covers brand
+// new databases, so pre-existing ones need these added with ALTER TABLE -
+// which SQLite doesn't let us guard with IF NOT EXISTS, hence the
+// "duplicate column name" tolerance in migrateColumns.
+var coalesceColumns = []string{
+	"ALTER TABLE raw_events ADD COLUMN end_unix_time INTEGER DEFAULT 0",
+	"ALTER TABLE raw_events ADD COLUMN sample_count INTEGER DEFAULT 1",
+	"ALTER TABLE raw_events ADD COLUMN input_intensity_sum REAL DEFAULT 0.0",
+	"ALTER TABLE raw_events ADD COLUMN input_idle_min INTEGER DEFAULT 0",
+}
+
+// dedupeColumns is the screenshot-content-hash column added for
+// vision/dedupe, added the same way as coalesceColumns above.
+var dedupeColumns = []string{
+	"ALTER TABLE raw_events ADD COLUMN screenshot_hash INTEGER DEFAULT 0",
+}
+
+// processStatsColumns are the per-process resource usage columns added for
+// sysinfo.ProcessSampler, added the same way as coalesceColumns above.
+var processStatsColumns = []string{
+	"ALTER TABLE raw_events ADD COLUMN cpu_percent REAL DEFAULT 0.0",
+	"ALTER TABLE raw_events ADD COLUMN working_set_rss INTEGER DEFAULT 0",
+	"ALTER TABLE raw_events ADD COLUMN io_read_bytes INTEGER DEFAULT 0",
+	"ALTER TABLE raw_events ADD COLUMN io_write_bytes INTEGER DEFAULT 0",
+	"ALTER TABLE raw_events ADD COLUMN handle_count INTEGER DEFAULT 0",
+}
+
+// hostIDColumns is the per-machine identifier column added for
+// hostid.Get, added the same way as coalesceColumns above.
+var hostIDColumns = []string{
+	"ALTER TABLE raw_events ADD COLUMN host_uuid TEXT DEFAULT ''",
+}
+
+// inputHookColumns are the low-level keyboard/mouse hook counters added for
+// sysinfo.InputActivity, added the same way as coalesceColumns above.
+var inputHookColumns = []string{
+	"ALTER TABLE raw_events ADD COLUMN keystrokes INTEGER DEFAULT 0",
+	"ALTER TABLE raw_events ADD COLUMN mouse_clicks INTEGER DEFAULT 0",
+	"ALTER TABLE raw_events ADD COLUMN mouse_travel_px INTEGER DEFAULT 0",
+}
+
+// migrateCoalesceColumns adds the coalesce columns to a raw_events table
+// that predates them, ignoring "already exists" errors on a fresh database
+// where CREATE TABLE above already included them.
+func migrateCoalesceColumns(ctx context.Context, db *sql.DB) error {
+	return migrateColumns(ctx, db, coalesceColumns)
+}
+
+// migrateColumns runs each ALTER TABLE statement in columns, ignoring
+// "already exists" errors on a fresh database where CREATE TABLE above
+// already included them.
+func migrateColumns(ctx context.Context, db *sql.DB, columns []string) error {
+	for _, stmt := range columns {
+		if _, err := db.ExecContext(ctx, stmt); err != nil {
+			if strings.Contains(err.Error(), "duplicate column name") {
+				continue
+			}
+			return fmt.Errorf("failed to migrate raw_events columns: %w", err)
+		}
+	}
+	return nil
+}
+
+func (b *sqliteBackend) Append(ctx context.Context, event Event) error {
+	return b.FlushBatch(ctx, []Event{event})
+}
+
+func (b *sqliteBackend) FlushBatch(ctx context.Context, events []Event) error {
+	if len(events) == 0 {
+		return nil
+	}
+
+	if err := faultinject.Trigger("storage.sqliteBackend.flush"); err != nil {
+		return err
+	}
+
+	db := b.conn()
+
+	if RelaxedDurability(ctx) {
+		// openSQLiteDB already runs with synchronous=NORMAL as the baseline;
+		// under system pressure (see buffer.AdaptivePolicy) this backend
+		// relaxes one step further to OFF - skipping the WAL fsync
+		// entirely - trading a (already WAL-protected-against-corruption,
+		// but not against an OS-crash-at-the-wrong-instant) durability
+		// window for fewer writes while disk/battery are under pressure.
+		// Restored after so a quiet period goes back to the NORMAL baseline.
+		if _, err := db.ExecContext(ctx, "PRAGMA synchronous=OFF"); err != nil {
+			return fmt.Errorf("failed to relax synchronous pragma: %w", err)
+		}
+		defer func() {
+			if _, err := db.ExecContext(ctx, "PRAGMA synchronous=NORMAL"); err != nil {
+				log.Printf("storage: failed to restore synchronous pragma: %v", err)
+			}
+		}()
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	// Record the WAL checkpoint in the same transaction as the inserts
+	// below, so a crash can't commit one without the other: on restart,
+	// buffer.Recover's replay compares the WAL's LSNs against this row via
+	// LastWALCheckpoint and only redoes what didn't make it in.
+	if lsn, ok := WALCheckpointFromContext(ctx); ok {
+		if _, err := tx.ExecContext(ctx, `
+			INSERT INTO wal_checkpoint (id, lsn) VALUES (1, ?)
+			ON CONFLICT(id) DO UPDATE SET lsn = excluded.lsn
+		`, lsn); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("failed to record WAL checkpoint: %w", err)
+		}
+	}
+
+	stmt, err := tx.PrepareContext(ctx, `
+		INSERT INTO raw_events
+		(session_uuid, unix_time, process_name, window_title, window_hwnd, input_idle_ms, input_intensity, screenshot_path,
+		 end_unix_time, sample_count, input_intensity_sum, input_idle_min, screenshot_hash,
+		 cpu_percent, working_set_rss, io_read_bytes, io_write_bytes, handle_count, host_uuid,
+		 keystrokes, mouse_clicks, mouse_travel_px)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`)
+	if err != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to prepare statement: %w", err)
+	}
+	defer stmt.Close()
+
+	for _, e := range events {
+		if _, err := stmt.ExecContext(ctx, e.SessionUUID, e.UnixTime, e.ProcessName, e.WindowTitle,
+			e.WindowHandle, e.InputIdleMs, e.InputIntensity, e.ScreenshotPath,
+			e.EndUnixTime, e.SampleCount, e.InputIntensitySum, e.InputIdleMin, e.ScreenshotHash,
+			e.CPUPercent, e.WorkingSetRSS, e.IOReadBytes, e.IOWriteBytes, e.HandleCount, e.HostUUID,
+			e.Keystrokes, e.MouseClicks, e.MouseTravelPx); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("failed to insert event: %w", err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (b *sqliteBackend) Query(ctx context.Context, filter QueryFilter) ([]Event, error) {
+	query := "SELECT session_uuid, unix_time, process_name, window_title, window_hwnd, input_idle_ms, input_intensity, screenshot_path, end_unix_time, sample_count, input_intensity_sum, input_idle_min, screenshot_hash, cpu_percent, working_set_rss, io_read_bytes, io_write_bytes, handle_count, host_uuid, keystrokes, mouse_clicks, mouse_travel_px FROM raw_events WHERE 1=1"
+	var args []interface{}
+
+	if filter.SessionUUID != "" {
+		query += " AND session_uuid = ?"
+		args = append(args, filter.SessionUUID)
+	}
+	if filter.Since > 0 {
+		query += " AND unix_time >= ?"
+		args = append(args, filter.Since)
+	}
+	if filter.Until > 0 {
+		query += " AND unix_time < ?"
+		args = append(args, filter.Until)
+	}
+	query += " ORDER BY unix_time DESC"
+	if filter.Limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, filter.Limit)
+	}
+
+	rows, err := b.conn().QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query events: %w", err)
+	}
+	defer rows.Close()
+
+	var events []Event
+	for rows.Next() {
+		var e Event
+		if err := rows.Scan(&e.SessionUUID, &e.UnixTime, &e.ProcessName, &e.WindowTitle,
+			&e.WindowHandle, &e.InputIdleMs, &e.InputIntensity, &e.ScreenshotPath,
+			&e.EndUnixTime, &e.SampleCount, &e.InputIntensitySum, &e.InputIdleMin, &e.ScreenshotHash,
+			&e.CPUPercent, &e.WorkingSetRSS, &e.IOReadBytes, &e.IOWriteBytes, &e.HandleCount, &e.HostUUID,
+			&e.Keystrokes, &e.MouseClicks, &e.MouseTravelPx); err != nil {
+			return nil, fmt.Errorf("failed to scan event: %w", err)
+		}
+		events = append(events, e)
+	}
+	return events, rows.Err()
+}
+
+// RecordPressureEvent persists event to buffer_pressure_events, implementing
+// PressureEventRecorder.
+func (b *sqliteBackend) RecordPressureEvent(ctx context.Context, event PressureEvent) error {
+	_, err := b.conn().ExecContext(ctx, `
+		INSERT INTO buffer_pressure_events
+		(unix_time, on_battery, disk_queue_length, cpu_percent, deferral_ms, relaxed_durability)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`, event.UnixTime, event.OnBattery, event.DiskQueueLength, event.CPUPercent, event.DeferralMs, event.RelaxedDurability)
+	if err != nil {
+		return fmt.Errorf("failed to insert pressure event: %w", err)
+	}
+	return nil
+}
+
+// RecordWindowSnapshots persists windows to window_snapshots in a single
+// transaction, implementing WindowSnapshotRecorder.
+func (b *sqliteBackend) RecordWindowSnapshots(ctx context.Context, windows []WindowSnapshot) error {
+	if len(windows) == 0 {
+		return nil
+	}
+
+	tx, err := b.conn().BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	stmt, err := tx.PrepareContext(ctx, `
+		INSERT INTO window_snapshots
+		(unix_time, host_uuid, window_hwnd, process_name, window_title, rect_left, rect_top, rect_right, rect_bottom, z_order)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`)
+	if err != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to prepare statement: %w", err)
+	}
+	defer stmt.Close()
+
+	for _, w := range windows {
+		if _, err := stmt.ExecContext(ctx,
+			w.UnixTime, w.HostUUID, w.WindowHandle, w.ProcessName, w.WindowTitle,
+			w.Left, w.Top, w.Right, w.Bottom, w.ZOrder,
+		); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("failed to insert window snapshot: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// LastWALCheckpoint implements storage.WALCheckpointStore, reading the LSN
+// last recorded by FlushBatch.
+func (b *sqliteBackend) LastWALCheckpoint(ctx context.Context) (int64, error) {
+	var lsn int64
+	err := b.conn().QueryRowContext(ctx, "SELECT lsn FROM wal_checkpoint WHERE id = 1").Scan(&lsn)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to read WAL checkpoint: %w", err)
+	}
+	return lsn, nil
+}
+
+func (b *sqliteBackend) Close() error {
+	if b.mgr != nil {
+		return b.mgr.Close(b.mgrKey)
+	}
+	return b.conn().Close()
+}
+
+// DB exposes the underlying *sql.DB for callers (e.g. storage.Maintainer)
+// that need direct access to SQLite-specific PRAGMAs. Only the sqlite
+// backend implements this; callers should type-assert. The handle can be
+// swapped out from under the caller by a concurrent VacuumSwap - callers
+// that hold onto it across more than one statement should re-fetch it via
+// DB() rather than caching it (see Maintainer's per-run calls).
+func (b *sqliteBackend) DB() *sql.DB {
+	return b.conn()
+}
+
+// VacuumSwap implements VacuumSwapper: it runs VACUUM INTO tmpPath, then
+// closes this backend's connection, renames tmpPath over the live database
+// file, and reopens a fresh connection - in that order - so the pooled
+// connection never keeps the old, now-unlinked inode open while the rename
+// happens (the bug a naive "rename out from under an open *sql.DB" has: the
+// connection keeps writing to the orphaned file, and on Windows the rename
+// fails outright because the file is still open). When this backend shares
+// its connection via Config.Manager, closing it here would normally pull
+// the rug out from under other holders of the same registry entry - so this
+// only proceeds if the registry shows this backend as the sole holder
+// (refcount 1); any other subsystem sharing the same DBPath makes it refuse.
+func (b *sqliteBackend) VacuumSwap(ctx context.Context, tmpPath string) (int64, error) {
+	if b.mgr != nil && b.mgr.RefCount(b.mgrKey) != 1 {
+		return 0, fmt.Errorf("vacuum swap unsupported: database connection is shared via storage.Manager with other holders")
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if _, err := b.db.ExecContext(ctx, fmt.Sprintf("VACUUM INTO '%s'", tmpPath)); err != nil {
+		os.Remove(tmpPath)
+		return 0, fmt.Errorf("VACUUM INTO failed: %w", err)
+	}
+
+	var size int64
+	if info, err := os.Stat(tmpPath); err == nil {
+		size = info.Size()
+	}
+
+	if err := b.db.Close(); err != nil {
+		os.Remove(tmpPath)
+		return 0, fmt.Errorf("failed to close database ahead of vacuum swap: %w", err)
+	}
+
+	if err := os.Rename(tmpPath, b.cfg.DBPath); err != nil {
+		os.Remove(tmpPath)
+		// The live connection is already closed; best-effort reopen the
+		// original file so the backend doesn't stay permanently unusable.
+		if reopened, reopenErr := openSQLiteDB(b.cfg); reopenErr == nil {
+			b.db = reopened
+			if b.mgr != nil {
+				b.mgr.ReplaceSQL(b.mgrKey, reopened)
+			}
+		}
+		return 0, fmt.Errorf("failed to swap vacuumed database into place: %w", err)
+	}
+
+	newDB, err := openSQLiteDB(b.cfg)
+	if err != nil {
+		return size, fmt.Errorf("vacuum swap succeeded but reopening the database failed: %w", err)
+	}
+	b.db = newDB
+	if b.mgr != nil {
+		b.mgr.ReplaceSQL(b.mgrKey, newDB)
+	}
+
+	return size, nil
+}
@@ -0,0 +1,154 @@
+package storage
+
+import (
+	"bytes"
+	"database/sql"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// sqliteFormat3Header is the fixed 16-byte magic every plaintext SQLite
+// file starts with. A SQLCipher-encrypted file's first page is
+// indistinguishable from random bytes, so its absence is the only reliable,
+// driver-agnostic signal that encryption actually took effect (see
+// fileIsEncrypted).
+var sqliteFormat3Header = []byte("SQLite format 3\x00")
+
+// EncryptionConfig controls opt-in encryption-at-rest for the SQLite tier.
+// Because Mnemosyne records screen OCR, VLM descriptions and inferred user
+// intent, an operator may want the database file itself encrypted rather
+// than relying on filesystem-level encryption alone.
+type EncryptionConfig struct {
+	// Key, if non-empty, is passed to the database as a PRAGMA key. This
+	// requires the binary to be built against a SQLCipher-compatible driver
+	// (cgo build tag); the pure-Go modernc.org/sqlite driver used by default
+	// does not implement page encryption and will fail PRAGMA key silently,
+	// which is exactly why we verify it below instead of trusting it blindly.
+	Key string
+
+	// AllowPlaintext permits opening an existing database that turns out not
+	// to be encrypted (or a driver that can't encrypt it) instead of refusing
+	// to start.
+	AllowPlaintext bool
+}
+
+// applyEncryption sets PRAGMA key (if configured) against the database at
+// path and verifies it actually took effect. A driver without cipher
+// support (the default pure-Go modernc.org/sqlite) silently ignores the
+// unknown PRAGMA and happily reads sqlite_master back as plaintext SQL, so
+// neither the Exec nor the read-back erroring is a reliable signal by
+// itself - fileIsEncrypted's header check is what actually catches it.
+func applyEncryption(db *sql.DB, path string, cfg EncryptionConfig) error {
+	if cfg.Key == "" {
+		return nil
+	}
+
+	// PRAGMA statements don't accept bound parameters, so the key has to be
+	// string-interpolated; escape embedded quotes the standard SQL way
+	// (doubling them) rather than string-formatting the raw key, which
+	// would break or inject on a key containing one.
+	escapedKey := strings.ReplaceAll(cfg.Key, "'", "''")
+	if _, err := db.Exec(fmt.Sprintf("PRAGMA key = '%s'", escapedKey)); err != nil {
+		return fmt.Errorf("failed to set PRAGMA key: %w", err)
+	}
+
+	if _, err := db.Query("SELECT count(*) FROM sqlite_master"); err != nil {
+		if cfg.AllowPlaintext {
+			return nil
+		}
+		return fmt.Errorf("database does not appear to accept encryption key "+
+			"(build without a SQLCipher-compatible driver?); pass --allow-plaintext to proceed anyway: %w", err)
+	}
+
+	encrypted, err := fileIsEncrypted(path)
+	if err != nil {
+		return fmt.Errorf("failed to verify encryption took effect: %w", err)
+	}
+	if !encrypted {
+		if cfg.AllowPlaintext {
+			return nil
+		}
+		return fmt.Errorf("database at %q is still plaintext after PRAGMA key "+
+			"(build without a SQLCipher-compatible driver?); pass --allow-plaintext to proceed anyway", path)
+	}
+
+	return nil
+}
+
+// fileIsEncrypted reports whether path's first page lacks the well-known
+// plaintext SQLite header - the only driver-agnostic signal that PRAGMA key
+// actually encrypted the file, since a SQLCipher-compatible driver's first
+// page looks like random bytes where the header would be. A file too short
+// to contain a full header yet (e.g. freshly created, nothing written)
+// can't be confirmed encrypted, so it's reported as plaintext.
+func fileIsEncrypted(path string) (bool, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+
+	header := make([]byte, len(sqliteFormat3Header))
+	if _, err := io.ReadFull(f, header); err != nil {
+		return false, nil
+	}
+
+	return !bytes.Equal(header, sqliteFormat3Header), nil
+}
+
+// Rekey streams the database at srcPath into a freshly (re-)encrypted copy
+// at dstPath under dstCfg's key, unlocking srcPath first with srcCfg's key
+// if it's already encrypted. It uses SQLCipher's documented ATTACH ... KEY
+// + sqlcipher_export() path rather than VACUUM INTO: VACUUM INTO only ever
+// copies pages byte-for-byte, so a VACUUM-INTO'd copy of an existing file is
+// - and with a real SQLCipher driver can only ever be - plaintext, since a
+// PRAGMA key applied afterwards cannot retroactively encrypt pages that are
+// already written. sqlcipher_export streams the main connection's data into
+// an attached, differently-keyed (or freshly-keyed) database instead, which
+// is the only way SQLCipher supports actually changing a file's key.
+func Rekey(srcPath, dstPath string, srcCfg, dstCfg EncryptionConfig) error {
+	os.Remove(dstPath)
+
+	srcDB, err := sql.Open("sqlite", srcPath)
+	if err != nil {
+		return fmt.Errorf("failed to open source database: %w", err)
+	}
+	defer srcDB.Close()
+
+	if srcCfg.Key != "" {
+		escapedSrcKey := strings.ReplaceAll(srcCfg.Key, "'", "''")
+		if _, err := srcDB.Exec(fmt.Sprintf("PRAGMA key = '%s'", escapedSrcKey)); err != nil {
+			return fmt.Errorf("failed to unlock source database: %w", err)
+		}
+	}
+
+	escapedDstKey := strings.ReplaceAll(dstCfg.Key, "'", "''")
+	if _, err := srcDB.Exec(fmt.Sprintf("ATTACH DATABASE '%s' AS rekeyed KEY '%s'", dstPath, escapedDstKey)); err != nil {
+		return fmt.Errorf("failed to attach destination database: %w", err)
+	}
+	defer srcDB.Exec("DETACH DATABASE rekeyed")
+
+	if _, err := srcDB.Exec("SELECT sqlcipher_export('rekeyed')"); err != nil {
+		os.Remove(dstPath)
+		return fmt.Errorf("sqlcipher_export failed: %w", err)
+	}
+
+	if _, err := srcDB.Exec("DETACH DATABASE rekeyed"); err != nil {
+		return fmt.Errorf("failed to detach destination database: %w", err)
+	}
+
+	dstDB, err := sql.Open("sqlite", dstPath)
+	if err != nil {
+		return fmt.Errorf("failed to open destination database: %w", err)
+	}
+	defer dstDB.Close()
+
+	if err := applyEncryption(dstDB, dstPath, dstCfg); err != nil {
+		os.Remove(dstPath)
+		return fmt.Errorf("failed to verify rekeyed database: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,160 @@
+package storage
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// extendLeaseScript renews the lease's TTL only if we still own it, so two
+// racing holders can never both believe they're the leader after a restart.
+const extendLeaseScript = `
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("PEXPIRE", KEYS[1], ARGV[2])
+else
+	return 0
+end
+`
+
+// releaseLeaseScript deletes the lease key only if we still own it.
+const releaseLeaseScript = `
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+else
+	return 0
+end
+`
+
+// Lease is a Redis SETNX-based advisory lock for leader election among
+// multiple Watchers sharing one Redis queue. Only the current holder should
+// perform exclusive work (e.g. draining the shared stream into a local
+// SQLite mirror, see redisSQLiteBackend); everyone else keeps capturing
+// into Redis without interruption.
+type Lease struct {
+	client  redis.UniversalClient
+	key     string
+	ownerID string
+	ttl     time.Duration
+
+	mu        sync.Mutex
+	held      bool
+	onAcquire func()
+	onLose    func()
+
+	stopCh chan struct{}
+	doneCh chan struct{}
+}
+
+// NewLease builds a Lease identified by key, contested under ownerID
+// (typically hostname+pid), refreshed well inside ttl.
+func NewLease(client redis.UniversalClient, key, ownerID string, ttl time.Duration) *Lease {
+	return &Lease{client: client, key: key, ownerID: ownerID, ttl: ttl}
+}
+
+// OnAcquire registers a callback run (from the heartbeat goroutine) whenever
+// leadership is gained, including on initial acquisition. Must be set before
+// Start.
+func (l *Lease) OnAcquire(fn func()) { l.onAcquire = fn }
+
+// OnLose registers a callback run whenever leadership is lost. Must be set
+// before Start.
+func (l *Lease) OnLose(fn func()) { l.onLose = fn }
+
+// Held reports whether this process currently believes it holds the lease.
+func (l *Lease) Held() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.held
+}
+
+// Start launches the background acquire/heartbeat loop and returns
+// immediately. The loop exits (releasing the lease first) when ctx is
+// cancelled or Stop is called, whichever comes first - this is what gives
+// SIGTERM a graceful hand-off instead of waiting out a full TTL.
+func (l *Lease) Start(ctx context.Context) {
+	l.stopCh = make(chan struct{})
+	l.doneCh = make(chan struct{})
+	go l.run(ctx)
+}
+
+// Stop releases the lease (if held) and waits for the heartbeat loop to exit.
+func (l *Lease) Stop() {
+	if l.stopCh == nil {
+		return
+	}
+	close(l.stopCh)
+	<-l.doneCh
+}
+
+func (l *Lease) run(ctx context.Context) {
+	defer close(l.doneCh)
+
+	interval := l.ttl / 3
+	if interval <= 0 {
+		interval = time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	l.attempt(ctx)
+	for {
+		select {
+		case <-ctx.Done():
+			l.release(context.Background())
+			return
+		case <-l.stopCh:
+			l.release(context.Background())
+			return
+		case <-ticker.C:
+			l.attempt(ctx)
+		}
+	}
+}
+
+func (l *Lease) attempt(ctx context.Context) {
+	wasHeld := l.Held()
+
+	nowHeld := false
+	if wasHeld {
+		res, err := l.client.Eval(ctx, extendLeaseScript, []string{l.key}, l.ownerID, l.ttl.Milliseconds()).Int64()
+		nowHeld = err == nil && res != 0
+	}
+	if !nowHeld {
+		ok, err := l.client.SetNX(ctx, l.key, l.ownerID, l.ttl).Result()
+		nowHeld = err == nil && ok
+	}
+
+	l.mu.Lock()
+	changed := nowHeld != l.held
+	l.held = nowHeld
+	onAcquire, onLose := l.onAcquire, l.onLose
+	l.mu.Unlock()
+
+	if !changed {
+		return
+	}
+	if nowHeld && onAcquire != nil {
+		onAcquire()
+	} else if !nowHeld && onLose != nil {
+		onLose()
+	}
+}
+
+func (l *Lease) release(ctx context.Context) {
+	if !l.Held() {
+		return
+	}
+
+	l.client.Eval(ctx, releaseLeaseScript, []string{l.key}, l.ownerID)
+
+	l.mu.Lock()
+	l.held = false
+	onLose := l.onLose
+	l.mu.Unlock()
+
+	if onLose != nil {
+		onLose()
+	}
+}
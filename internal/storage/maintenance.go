@@ -0,0 +1,245 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// MaintenanceConfig holds configuration for the periodic database maintenance
+// subsystem (VACUUM, retention pruning and WAL checkpointing), shared by
+// cmd/watcher and the storage tests.
+type MaintenanceConfig struct {
+	// JournalMode names the journal mode the database runs in: "DELETE" or
+	// "WAL". Only "WAL" starts the periodic wal_checkpoint loop. The
+	// Maintainer does not set the journal mode itself; the database keeps
+	// whatever mode it was opened with.
+	JournalMode string
+
+	// VacuumInterval is how often to attempt a VACUUM INTO swap. Zero disables it.
+	VacuumInterval time.Duration
+
+	// VacuumMinFreelist is the minimum number of free pages (from
+	// PRAGMA freelist_count) required before a vacuum is worth the I/O cost.
+	VacuumMinFreelist int
+
+	// RetentionDays is how many days of raw_events to keep. Zero disables pruning.
+	RetentionDays int
+
+	// CheckpointInterval is how often to run wal_checkpoint(TRUNCATE) when
+	// JournalMode is WAL. Zero disables periodic checkpointing.
+	CheckpointInterval time.Duration
+}
+
+// DefaultMaintenanceConfig returns a conservative, opt-in-by-flags configuration.
+func DefaultMaintenanceConfig() MaintenanceConfig {
+	return MaintenanceConfig{
+		JournalMode:        "DELETE",
+		VacuumInterval:     0,
+		VacuumMinFreelist:  1000,
+		RetentionDays:      0,
+		CheckpointInterval: 5 * time.Minute,
+	}
+}
+
+// dbProvider is implemented by backends that expose their live *sql.DB
+// (currently only sqlite, via its DB() accessor). Maintainer re-fetches it
+// on every run rather than caching it, since VacuumSwap can swap it out.
+type dbProvider interface {
+	DB() *sql.DB
+}
+
+// VacuumSwapper is implemented by storage backends that can safely rebuild
+// themselves via VACUUM INTO and swap the result into place (sqlite today -
+// see backend_sqlite.go). Maintainer type-asserts for it rather than driving
+// VACUUM INTO + os.Rename itself, since only the backend can safely close
+// and reopen its own *sql.DB - renaming the vacuumed file over the live one
+// while a pooled connection still holds it open would orphan every write
+// that lands afterward (and fail outright on Windows).
+type VacuumSwapper interface {
+	// VacuumSwap runs VACUUM INTO tmpPath, closes the backend's connection,
+	// renames tmpPath over the live database file, and reopens a fresh
+	// connection, returning the vacuumed file's size.
+	VacuumSwap(ctx context.Context, tmpPath string) (int64, error)
+}
+
+// Maintainer runs the background VACUUM/retention/checkpoint loop for a
+// single SQLite database. It coordinates with the Watcher's flush path via
+// TryLock/Unlock so a vacuum never starts while a flush transaction is open.
+type Maintainer struct {
+	backend dbProvider
+	dbPath  string
+	config  MaintenanceConfig
+
+	// flushMu is shared with the caller (e.g. monitor.Monitor) so the
+	// maintainer can refuse to run while a flush transaction holds it.
+	flushMu *sync.Mutex
+
+	stopOnce sync.Once
+	stopCh   chan struct{}
+	wg       sync.WaitGroup
+
+	vacuumRuns     uint64
+	prunedEvents   uint64
+	lastVacuumSize int64
+}
+
+// NewMaintainer creates a Maintainer for dbPath, backed by backend's live
+// *sql.DB (see dbProvider). flushMu must be the same mutex the Watcher's
+// flush path takes for the duration of its transaction; pass nil if no
+// flush coordination is required (e.g. in tests).
+func NewMaintainer(backend dbProvider, dbPath string, config MaintenanceConfig, flushMu *sync.Mutex) *Maintainer {
+	return &Maintainer{
+		backend: backend,
+		dbPath:  dbPath,
+		config:  config,
+		flushMu: flushMu,
+		stopCh:  make(chan struct{}),
+	}
+}
+
+// Start launches the background maintenance loop. It returns immediately;
+// call Stop (or cancel ctx) to shut it down.
+func (m *Maintainer) Start(ctx context.Context) {
+	if m.config.VacuumInterval > 0 {
+		m.wg.Add(1)
+		go m.loop(ctx, m.config.VacuumInterval, m.runVacuumAndRetention)
+	}
+	if m.config.JournalMode == "WAL" && m.config.CheckpointInterval > 0 {
+		m.wg.Add(1)
+		go m.loop(ctx, m.config.CheckpointInterval, m.runCheckpoint)
+	}
+}
+
+// Stop halts the background loops and waits for them to exit.
+func (m *Maintainer) Stop() {
+	m.stopOnce.Do(func() { close(m.stopCh) })
+	m.wg.Wait()
+}
+
+func (m *Maintainer) loop(ctx context.Context, interval time.Duration, fn func() error) {
+	defer m.wg.Done()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-m.stopCh:
+			return
+		case <-ticker.C:
+			if err := fn(); err != nil {
+				log.Printf("storage: maintenance run failed: %v", err)
+			}
+		}
+	}
+}
+
+// runVacuumAndRetention prunes expired rows (cascading to context_enrichment
+// and the FTS index via the existing triggers/foreign keys) and then, if the
+// freelist is large enough to be worth it, rebuilds the file with VACUUM INTO
+// and atomically swaps it in. This avoids both the 2x-space requirement and
+// the long writer stall of a plain `VACUUM`.
+func (m *Maintainer) runVacuumAndRetention() error {
+	if !m.tryLockFlush() {
+		return fmt.Errorf("skipping maintenance run: flush in progress")
+	}
+	defer m.unlockFlush()
+
+	if m.config.RetentionDays > 0 {
+		pruned, err := m.pruneOldEvents()
+		if err != nil {
+			return fmt.Errorf("retention prune failed: %w", err)
+		}
+		atomic.AddUint64(&m.prunedEvents, uint64(pruned))
+	}
+
+	var freelist int
+	if err := m.backend.DB().QueryRow("PRAGMA freelist_count").Scan(&freelist); err != nil {
+		return fmt.Errorf("failed to read freelist_count: %w", err)
+	}
+	if freelist < m.config.VacuumMinFreelist {
+		return nil
+	}
+
+	return m.vacuumIntoSwap()
+}
+
+// pruneOldEvents deletes raw_events older than RetentionDays. Deletion
+// cascades to context_enrichment (ON DELETE CASCADE) and the FTS shadow
+// tables via the schema's trg_fts_delete trigger.
+func (m *Maintainer) pruneOldEvents() (int64, error) {
+	cutoff := time.Now().AddDate(0, 0, -m.config.RetentionDays).UnixMilli()
+
+	res, err := m.backend.DB().Exec("DELETE FROM raw_events WHERE unix_time < ?", cutoff)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// vacuumIntoSwap rebuilds the database via VACUUM INTO and atomically swaps
+// it into place, rather than running `VACUUM` in place (which needs ~2x disk
+// space and holds a long-lived write lock). The swap itself is delegated to
+// the backend's VacuumSwapper (see backend_sqlite.go): renaming the
+// vacuumed file over the live one while m.backend's pooled connection still
+// held it open would silently orphan every write that landed afterward, so
+// only the backend - which owns that connection - can do this safely.
+func (m *Maintainer) vacuumIntoSwap() error {
+	swapper, ok := m.backend.(VacuumSwapper)
+	if !ok {
+		return fmt.Errorf("backend does not support atomic vacuum swap")
+	}
+
+	tmpPath := m.dbPath + ".vacuum.tmp"
+	os.Remove(tmpPath) // best-effort cleanup of a previous failed attempt
+
+	size, err := swapper.VacuumSwap(context.Background(), tmpPath)
+	if err != nil {
+		return err
+	}
+	m.lastVacuumSize = size
+
+	atomic.AddUint64(&m.vacuumRuns, 1)
+	log.Printf("storage: vacuumed %s into place (%d bytes)", m.dbPath, m.lastVacuumSize)
+	return nil
+}
+
+// runCheckpoint truncates the WAL file via wal_checkpoint(TRUNCATE), keeping
+// it from growing unbounded between vacuum cycles.
+func (m *Maintainer) runCheckpoint() error {
+	if !m.tryLockFlush() {
+		return fmt.Errorf("skipping checkpoint: flush in progress")
+	}
+	defer m.unlockFlush()
+
+	_, err := m.backend.DB().Exec("PRAGMA wal_checkpoint(TRUNCATE)")
+	return err
+}
+
+// tryLockFlush reports whether the maintainer may proceed: true if there is
+// no shared flush mutex to coordinate with, or if it was acquired.
+func (m *Maintainer) tryLockFlush() bool {
+	if m.flushMu == nil {
+		return true
+	}
+	return m.flushMu.TryLock()
+}
+
+func (m *Maintainer) unlockFlush() {
+	if m.flushMu != nil {
+		m.flushMu.Unlock()
+	}
+}
+
+// Stats returns a snapshot of maintenance counters for logging/metrics.
+func (m *Maintainer) Stats() (vacuumRuns, prunedEvents uint64, lastVacuumSize int64) {
+	return atomic.LoadUint64(&m.vacuumRuns), atomic.LoadUint64(&m.prunedEvents), m.lastVacuumSize
+}
@@ -0,0 +1,167 @@
+package storage
+
+import (
+	"database/sql"
+	"sync"
+)
+
+// Manager is a registry of shared, refcounted connections keyed by a
+// caller-chosen URI (e.g. a DBPath or RedisAddr), so multiple in-process
+// subsystems that want the "same" database or Redis instance - the
+// Watcher, the Processor tier, a future HTTP stats endpoint - reuse one
+// handle instead of each opening their own. This matters most for SQLite:
+// a second subsystem opening that same file as its own *sql.DB contends
+// with the first for the database's single write lock, rather than
+// sharing the one connection pool that already exists.
+//
+// GetSQL/GetRedis call open() only for the first caller to ask for a given
+// uri; every later caller gets the same handle back and bumps its
+// refcount. Close(uri) releases one reference, tearing the handle down
+// once the count reaches zero; CloseAll tears everything down
+// unconditionally, for process shutdown.
+type Manager struct {
+	mu    sync.Mutex
+	sql   map[string]*sqlConn
+	redis map[string]*redisConn
+}
+
+type sqlConn struct {
+	db   *sql.DB
+	refs int
+}
+
+type redisConn struct {
+	client *RedisClient
+	refs   int
+}
+
+// NewManager returns an empty connection registry.
+func NewManager() *Manager {
+	return &Manager{
+		sql:   make(map[string]*sqlConn),
+		redis: make(map[string]*redisConn),
+	}
+}
+
+// GetSQL returns the *sql.DB registered under uri, calling open to create
+// it if uri hasn't been seen before. Pair every successful call with
+// exactly one Close(uri).
+func (m *Manager) GetSQL(uri string, open func() (*sql.DB, error)) (*sql.DB, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if c, ok := m.sql[uri]; ok {
+		c.refs++
+		return c.db, nil
+	}
+
+	db, err := open()
+	if err != nil {
+		return nil, err
+	}
+	m.sql[uri] = &sqlConn{db: db, refs: 1}
+	return db, nil
+}
+
+// GetRedis returns the *RedisClient registered under uri, calling open to
+// create it if uri hasn't been seen before. Pair every successful call
+// with exactly one Close(uri).
+func (m *Manager) GetRedis(uri string, open func() (*RedisClient, error)) (*RedisClient, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if c, ok := m.redis[uri]; ok {
+		c.refs++
+		return c.client, nil
+	}
+
+	client, err := open()
+	if err != nil {
+		return nil, err
+	}
+	m.redis[uri] = &redisConn{client: client, refs: 1}
+	return client, nil
+}
+
+// RefCount reports how many outstanding references uri currently has
+// (whichever of the SQL/Redis registries it was opened under), or 0 if
+// uri isn't registered. Used by callers like sqliteBackend.VacuumSwap that
+// need to know whether they're the connection's sole holder before doing
+// something that requires closing and reopening it.
+func (m *Manager) RefCount(uri string) int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if c, ok := m.sql[uri]; ok {
+		return c.refs
+	}
+	if c, ok := m.redis[uri]; ok {
+		return c.refs
+	}
+	return 0
+}
+
+// ReplaceSQL swaps the *sql.DB registered under uri for db, without touching
+// its refcount. Used by callers like sqliteBackend.VacuumSwap that close and
+// reopen the connection they hold through the registry - without this, the
+// registry would keep handing out the old, now-closed handle to every later
+// GetSQL(uri) caller. A no-op if uri isn't registered.
+func (m *Manager) ReplaceSQL(uri string, db *sql.DB) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if c, ok := m.sql[uri]; ok {
+		c.db = db
+	}
+}
+
+// Close releases one reference to uri - whichever of the SQL/Redis
+// registries it was opened under - closing the underlying connection once
+// its refcount reaches zero. A no-op if uri isn't registered.
+func (m *Manager) Close(uri string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if c, ok := m.sql[uri]; ok {
+		c.refs--
+		if c.refs > 0 {
+			return nil
+		}
+		delete(m.sql, uri)
+		return c.db.Close()
+	}
+
+	if c, ok := m.redis[uri]; ok {
+		c.refs--
+		if c.refs > 0 {
+			return nil
+		}
+		delete(m.redis, uri)
+		return c.client.Close()
+	}
+
+	return nil
+}
+
+// CloseAll tears down every registered connection regardless of refcount.
+// Call once at process shutdown, after every subsystem holding a Manager
+// reference has stopped using it.
+func (m *Manager) CloseAll() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var firstErr error
+	for uri, c := range m.sql {
+		if err := c.db.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+		delete(m.sql, uri)
+	}
+	for uri, c := range m.redis {
+		if err := c.client.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+		delete(m.redis, uri)
+	}
+	return firstErr
+}
@@ -2,44 +2,329 @@ package storage
 
 import (
 	"context"
+	"crypto/tls"
 	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/redis/go-redis/v9"
+
+	"mnemosyne/internal/faultinject"
+	"mnemosyne/internal/metrics"
 )
 
-// RedisClient wraps the go-redis client.
+// RedisClient wraps a go-redis UniversalClient, so the same code path
+// serves a single node, a Sentinel-fronted failover group, or a Cluster -
+// see ParseRedisURI for how the addr string picks which.
 type RedisClient struct {
-	client *redis.Client
+	client  redis.UniversalClient
+	metrics metrics.Sink
+}
+
+// RedisClientOptions carries connection security settings that go beyond
+// the plain addr/password/db triple NewRedisClient historically took. These
+// override whatever ParseRedisURI derived from the addr string, so callers
+// can keep username/password out of the URI (e.g. read them from a secret
+// store instead).
+type RedisClientOptions struct {
+	Username string
+	Password string
+	DB       int
+	UseTLS   bool
 }
 
 // NewRedisClient creates a new Redis client and verifies connection.
 func NewRedisClient(addr string, password string, db int) (*RedisClient, error) {
-	client := redis.NewClient(&redis.Options{
-		Addr:     addr,
-		Password: password,
-		DB:       db,
-	})
+	return NewRedisClientWithOptions(addr, RedisClientOptions{Password: password, DB: db})
+}
+
+// NewRedisClientWithOptions creates a new Redis client with TLS/ACL support
+// and verifies the connection. addr is a URI parsed by ParseRedisURI - a
+// bare "host:port" (the historical RedisAddr format) works unchanged as a
+// single-node redis:// URI; redis+sentinel:// and redis+cluster:// addrs
+// get routed to Sentinel/Cluster mode via redis.NewUniversalClient.
+func NewRedisClientWithOptions(addr string, opts RedisClientOptions) (*RedisClient, error) {
+	uopts, err := ParseRedisURI(addr)
+	if err != nil {
+		return nil, err
+	}
+	if opts.Username != "" {
+		uopts.Username = opts.Username
+	}
+	if opts.Password != "" {
+		uopts.Password = opts.Password
+	}
+	if opts.DB != 0 {
+		uopts.DB = opts.DB
+	}
+	if opts.UseTLS && uopts.TLSConfig == nil {
+		uopts.TLSConfig = &tls.Config{MinVersion: tls.VersionTLS12}
+	}
+
+	client := redis.NewUniversalClient(uopts)
 
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
 	if err := client.Ping(ctx).Err(); err != nil {
+		client.Close()
 		return nil, fmt.Errorf("failed to connect to redis: %w", err)
 	}
 
-	return &RedisClient{client: client}, nil
+	return &RedisClient{client: client, metrics: metrics.NoopSink{}}, nil
+}
+
+// ParseRedisURI parses a Redis connection URI into UniversalOptions, which
+// redis.NewUniversalClient turns into a plain Client, a Sentinel-aware
+// FailoverClient, or a ClusterClient depending on what's populated
+// (MasterName set -> Sentinel; more than one Addrs and no MasterName ->
+// Cluster; otherwise a single node). Three schemes are recognized:
+//
+//	redis://host:port/db                       single node
+//	redis+sentinel://host1,host2/mymaster/db   Sentinel, failover group mymaster
+//	redis+cluster://host1,host2,host3          Cluster
+//
+// A bare "host:port" with no "://" (the format RedisAddr historically took)
+// is treated as a single-node redis:// URI. "rediss"/"rediss+..." variants
+// enable TLS. Query parameters tune the connection pool: ?pool_size=<n>,
+// ?read_timeout=<duration>, ?route_by_latency=true.
+func ParseRedisURI(uri string) (*redis.UniversalOptions, error) {
+	if !strings.Contains(uri, "://") {
+		uri = "redis://" + uri
+	}
+
+	parsed, err := url.Parse(uri)
+	if err != nil {
+		return nil, fmt.Errorf("invalid redis URI %q: %w", uri, err)
+	}
+
+	opts := &redis.UniversalOptions{}
+	if parsed.User != nil {
+		opts.Username = parsed.User.Username()
+		if pw, ok := parsed.User.Password(); ok {
+			opts.Password = pw
+		}
+	}
+
+	path := strings.TrimPrefix(parsed.Path, "/")
+	switch parsed.Scheme {
+	case "redis", "rediss":
+		opts.Addrs = strings.Split(parsed.Host, ",")
+		if path != "" {
+			db, err := strconv.Atoi(path)
+			if err != nil {
+				return nil, fmt.Errorf("invalid redis URI %q: bad db %q: %w", uri, path, err)
+			}
+			opts.DB = db
+		}
+
+	case "redis+sentinel", "rediss+sentinel":
+		opts.Addrs = strings.Split(parsed.Host, ",")
+		parts := strings.SplitN(path, "/", 2)
+		if parts[0] == "" {
+			return nil, fmt.Errorf("invalid redis URI %q: missing master name", uri)
+		}
+		opts.MasterName = parts[0]
+		if len(parts) > 1 && parts[1] != "" {
+			db, err := strconv.Atoi(parts[1])
+			if err != nil {
+				return nil, fmt.Errorf("invalid redis URI %q: bad db %q: %w", uri, parts[1], err)
+			}
+			opts.DB = db
+		}
+
+	case "redis+cluster", "rediss+cluster":
+		opts.Addrs = strings.Split(parsed.Host, ",")
+
+	default:
+		return nil, fmt.Errorf("unknown redis URI scheme %q", parsed.Scheme)
+	}
+
+	if strings.HasPrefix(parsed.Scheme, "rediss") {
+		opts.TLSConfig = &tls.Config{MinVersion: tls.VersionTLS12}
+	}
+
+	query := parsed.Query()
+	if v := query.Get("pool_size"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid redis URI %q: bad pool_size %q: %w", uri, v, err)
+		}
+		opts.PoolSize = n
+	}
+	if v := query.Get("read_timeout"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid redis URI %q: bad read_timeout %q: %w", uri, v, err)
+		}
+		opts.ReadTimeout = d
+	}
+	if v := query.Get("route_by_latency"); v != "" {
+		opts.RouteByLatency = v == "true" || v == "1"
+	}
+
+	return opts, nil
+}
+
+// SetMetricsSink attaches a metrics.Sink so enqueue failures are counted
+// under the "redis" component without this package importing Prometheus.
+func (r *RedisClient) SetMetricsSink(sink metrics.Sink) {
+	r.metrics = sink
 }
 
 // PublishEvent sends an event to a Redis Stream using XADD.
 // Phase 7: Uses MaxLen=5000 with Approx to cap memory usage.
 func (r *RedisClient) PublishEvent(ctx context.Context, stream string, data map[string]interface{}) error {
-	return r.client.XAdd(ctx, &redis.XAddArgs{
+	_, err := r.PublishEventWithID(ctx, stream, data)
+	return err
+}
+
+// PublishEventWithID behaves like PublishEvent but also returns the stream
+// entry ID XADD assigned, so callers tracking a drain cursor (see
+// storage.Lease / redisSQLiteBackend) can record how far they've mirrored
+// the stream into a secondary store.
+func (r *RedisClient) PublishEventWithID(ctx context.Context, stream string, data map[string]interface{}) (string, error) {
+	if err := faultinject.Trigger("storage.RedisClient.enqueue"); err != nil {
+		r.metrics.IncError("redis")
+		return "", err
+	}
+
+	id, err := r.client.XAdd(ctx, &redis.XAddArgs{
 		Stream: stream,
 		Values: data,
 		MaxLen: 5000, // Cap stream size for memory optimization
 		Approx: true, // Allow ~5000 for better performance
-	}).Err()
+	}).Result()
+	if err != nil {
+		r.metrics.IncError("redis")
+	}
+	return id, err
+}
+
+// EnsureGroup creates group on stream (and stream itself via MKSTREAM, if it
+// doesn't exist yet) starting from "$" - i.e. only messages added after the
+// group is created are new to it. Safe to call on every Processor startup:
+// Redis's "BUSYGROUP" error for an already-existing group is swallowed.
+func (r *RedisClient) EnsureGroup(ctx context.Context, stream, group string) error {
+	err := r.client.XGroupCreateMkStream(ctx, stream, group, "$").Err()
+	if err != nil && !strings.Contains(err.Error(), "BUSYGROUP") {
+		return fmt.Errorf("failed to create consumer group %q on stream %q: %w", group, stream, err)
+	}
+	return nil
+}
+
+// ReadGroup wraps XREADGROUP for one consumer in group. Pass id=">" to
+// receive only messages never delivered to this group before; pass a
+// specific ID (e.g. "0") to instead replay consumer's own still-pending
+// entries after a restart. A nil, nil return means block elapsed with
+// nothing new.
+func (r *RedisClient) ReadGroup(ctx context.Context, stream, group, consumer, id string, count int64, block time.Duration) ([]redis.XMessage, error) {
+	res, err := r.client.XReadGroup(ctx, &redis.XReadGroupArgs{
+		Group:    group,
+		Consumer: consumer,
+		Streams:  []string{stream, id},
+		Count:    count,
+		Block:    block,
+	}).Result()
+	if err != nil {
+		if err == redis.Nil {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("XREADGROUP %s/%s failed: %w", stream, group, err)
+	}
+	if len(res) == 0 {
+		return nil, nil
+	}
+	return res[0].Messages, nil
+}
+
+// Ack wraps XACK, removing ids from group's pending-entries list once
+// they've been durably handled. A no-op for an empty ids.
+func (r *RedisClient) Ack(ctx context.Context, stream, group string, ids ...string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	if err := r.client.XAck(ctx, stream, group, ids...).Err(); err != nil {
+		return fmt.Errorf("XACK %s/%s failed: %w", stream, group, err)
+	}
+	return nil
+}
+
+// Claim wraps XAUTOCLAIM, transferring ownership of stream/group entries
+// idle at least minIdle (i.e. abandoned by a crashed consumer) to consumer.
+// start is the scan cursor - pass "0-0" for a fresh sweep and whatever this
+// call returns to page through the rest of the pending-entries list. Pass
+// count<=0 for the server default.
+func (r *RedisClient) Claim(ctx context.Context, stream, group, consumer string, minIdle time.Duration, start string, count int64) ([]redis.XMessage, string, error) {
+	msgs, cursor, err := r.client.XAutoClaim(ctx, &redis.XAutoClaimArgs{
+		Stream:   stream,
+		Group:    group,
+		Consumer: consumer,
+		MinIdle:  minIdle,
+		Start:    start,
+		Count:    count,
+	}).Result()
+	if err != nil {
+		return nil, "", fmt.Errorf("XAUTOCLAIM %s/%s failed: %w", stream, group, err)
+	}
+	return msgs, cursor, nil
+}
+
+// PendingSummary is group's pending-entries list at a glance: XPENDING's
+// summary form.
+type PendingSummary struct {
+	Count     int64
+	Lowest    string
+	Highest   string
+	Consumers map[string]int64 // consumer name -> pending count
+}
+
+// PendingSummary wraps XPENDING's summary form for stream/group.
+func (r *RedisClient) PendingSummary(ctx context.Context, stream, group string) (PendingSummary, error) {
+	res, err := r.client.XPending(ctx, stream, group).Result()
+	if err != nil {
+		return PendingSummary{}, fmt.Errorf("XPENDING %s/%s failed: %w", stream, group, err)
+	}
+	return PendingSummary{
+		Count:     res.Count,
+		Lowest:    res.Lower,
+		Highest:   res.Higher,
+		Consumers: res.Consumers,
+	}, nil
+}
+
+// PendingEntry is one row of XPENDING's extended form: an entry currently
+// owned by some consumer, how long it's been idle, and how many times it's
+// been delivered - the signal needed to decide whether a reclaimed entry
+// should be retried again or dead-lettered.
+type PendingEntry struct {
+	ID            string
+	Consumer      string
+	Idle          time.Duration
+	DeliveryCount int64
+}
+
+// PendingDetail wraps XPENDING's extended form, listing up to count entries
+// in [start, end] (use "-"/"+" for the whole list).
+func (r *RedisClient) PendingDetail(ctx context.Context, stream, group, start, end string, count int64) ([]PendingEntry, error) {
+	res, err := r.client.XPendingExt(ctx, &redis.XPendingExtArgs{
+		Stream: stream,
+		Group:  group,
+		Start:  start,
+		End:    end,
+		Count:  count,
+	}).Result()
+	if err != nil {
+		return nil, fmt.Errorf("XPENDING %s/%s (extended) failed: %w", stream, group, err)
+	}
+
+	entries := make([]PendingEntry, len(res))
+	for i, e := range res {
+		entries[i] = PendingEntry{ID: e.ID, Consumer: e.Consumer, Idle: e.Idle, DeliveryCount: e.RetryCount}
+	}
+	return entries, nil
 }
 
 // Close closes the Redis connection.
@@ -0,0 +1,471 @@
+// Package spool implements a durable, crash-safe on-disk queue of
+// storage.Event values sitting between buffer.Buffer and a storage.Backend.
+// Where buffer's own WAL (see buffer.Recover) only protects against a crash
+// between a tick and its next flush, the spool protects against the flush
+// itself failing to land - e.g. a Redis outage - by keeping every event on
+// disk until the backend has confirmed it, so a Watcher producing into a
+// flaky or temporarily-down backend is at-least-once rather than best-effort.
+package spool
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/vmihailenco/msgpack/v5"
+
+	"mnemosyne/internal/storage"
+)
+
+// spoolMagic marks the start of a framed record, mirroring buffer's wal.go
+// so a reader can tell a corrupted length prefix from a truncated tail.
+const spoolMagic uint32 = 0x53504C31 // "SPL1"
+
+const (
+	segmentSuffix       = ".seg"
+	cursorFileName      = "cursor"
+	defaultMaxBytes     = 1 << 30 // 1GB
+	defaultSegmentBytes = 8 << 20 // 8MB; rotate so Ack can reclaim disk incrementally
+)
+
+// Record is one entry read back by Iterate: its assigned sequence number
+// plus the storage.Event it wraps.
+type Record struct {
+	Seq   uint64
+	Event storage.Event
+}
+
+// segment is one rotation of the spool's append-only log. Segments are
+// named by their first sequence number, zero-padded, so a directory listing
+// already sorts oldest-first.
+type segment struct {
+	path     string
+	firstSeq uint64
+	lastSeq  uint64
+	size     int64
+	count    int
+	file     *os.File // non-nil only for the currently-open write segment
+
+	// validBytes is the offset immediately past the last valid record
+	// loadSegment found, which can be less than the file's actual size if a
+	// crash left a torn record at the tail. openTailForWrite truncates to
+	// this before reopening for append, so new writes land right after the
+	// last good record instead of after the garbage (see buffer/wal.go's
+	// openWAL, which does the same thing for the same reason).
+	validBytes int64
+}
+
+// Spool is an on-disk, crash-safe queue of storage.Event values. Append
+// durably records an event and returns its sequence number; Iterate replays
+// everything not yet Acked (e.g. at Watcher startup); Ack advances the
+// persisted cursor and reclaims segments now fully covered by it. MaxBytes
+// bounds on-disk growth: once exceeded, the oldest segment is evicted even
+// if un-acked, and its entries are counted in Dropped.
+type Spool struct {
+	mu       sync.Mutex
+	dir      string
+	maxBytes int64
+
+	segments []*segment // oldest first; last is always the open write segment
+	cur      *segment
+
+	nextSeq uint64
+	cursor  uint64 // last acked seq; 0 means nothing acked yet
+	dropped uint64
+}
+
+// Open opens (or creates) a spool rooted at dir, replaying its segment and
+// cursor files. maxBytes<=0 uses a 1GB default budget.
+func Open(dir string, maxBytes int64) (*Spool, error) {
+	if maxBytes <= 0 {
+		maxBytes = defaultMaxBytes
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create spool dir %q: %w", dir, err)
+	}
+
+	s := &Spool{dir: dir, maxBytes: maxBytes, nextSeq: 1}
+	if err := s.loadCursor(); err != nil {
+		return nil, err
+	}
+	if err := s.loadSegments(); err != nil {
+		return nil, err
+	}
+	if err := s.openTailForWrite(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Append msgpack-encodes event, writes a framed record to the open segment
+// and fsyncs before returning, so a successful Append means the event
+// survives a crash even if the backend never sees it. Rotates to a fresh
+// segment and evicts oldest data past MaxBytes as a side effect.
+func (s *Spool) Append(event storage.Event) (uint64, error) {
+	payload, err := msgpack.Marshal(event)
+	if err != nil {
+		return 0, fmt.Errorf("spool: failed to msgpack-encode event: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	seq := s.nextSeq
+	s.nextSeq++
+
+	header := make([]byte, 20)
+	binary.BigEndian.PutUint32(header[0:4], spoolMagic)
+	binary.BigEndian.PutUint64(header[4:12], seq)
+	binary.BigEndian.PutUint32(header[12:16], uint32(len(payload)))
+	binary.BigEndian.PutUint32(header[16:20], crc32.ChecksumIEEE(payload))
+
+	if _, err := s.cur.file.Write(header); err != nil {
+		return 0, fmt.Errorf("spool: failed to write record header: %w", err)
+	}
+	if _, err := s.cur.file.Write(payload); err != nil {
+		return 0, fmt.Errorf("spool: failed to write record payload: %w", err)
+	}
+	if err := s.cur.file.Sync(); err != nil {
+		return 0, fmt.Errorf("spool: failed to sync segment: %w", err)
+	}
+
+	s.cur.size += int64(len(header) + len(payload))
+	s.cur.count++
+	s.cur.lastSeq = seq
+
+	if s.cur.size >= defaultSegmentBytes {
+		if err := s.rotateLocked(); err != nil {
+			return seq, err
+		}
+	}
+	s.evictLocked()
+
+	return seq, nil
+}
+
+// Iterate returns every record with Seq > from, in order, reading across
+// segments oldest-first. Callers draining un-acked entries at startup
+// should pass Cursor().
+func (s *Spool) Iterate(from uint64) ([]Record, error) {
+	s.mu.Lock()
+	segs := append([]*segment(nil), s.segments...)
+	s.mu.Unlock()
+
+	var records []Record
+	for _, seg := range segs {
+		if seg.lastSeq <= from {
+			continue
+		}
+		recs, err := readSegment(seg.path)
+		if err != nil {
+			return records, fmt.Errorf("spool: failed to read segment %q: %w", seg.path, err)
+		}
+		for _, rec := range recs {
+			if rec.Seq > from {
+				records = append(records, rec)
+			}
+		}
+	}
+	return records, nil
+}
+
+// Ack advances the persisted cursor to upTo (a no-op if upTo is not past the
+// current cursor) and removes any closed segment now fully covered by it, so
+// a crash after this point never replays entries the backend already
+// accepted.
+func (s *Spool) Ack(upTo uint64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if upTo <= s.cursor {
+		return nil
+	}
+	s.cursor = upTo
+	if err := s.persistCursorLocked(); err != nil {
+		return err
+	}
+
+	for len(s.segments) > 0 && s.segments[0] != s.cur && s.segments[0].lastSeq <= s.cursor {
+		removed := s.segments[0]
+		if err := os.Remove(removed.path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("spool: failed to remove acked segment %q: %w", removed.path, err)
+		}
+		s.segments = s.segments[1:]
+	}
+	return nil
+}
+
+// Cursor returns the last acked sequence number (0 if nothing has been
+// acked yet).
+func (s *Spool) Cursor() uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.cursor
+}
+
+// Depth reports how many un-acked records currently sit on disk, for
+// logStats/metrics.Sink.SetSpoolDepth.
+func (s *Spool) Depth() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	depth := 0
+	for _, seg := range s.segments {
+		if seg.lastSeq <= s.cursor {
+			continue
+		}
+		if seg.firstSeq > s.cursor {
+			depth += seg.count
+		} else {
+			// cursor falls inside this segment (the common case: the open
+			// write segment hasn't rotated since the last Ack) - count only
+			// the records after it. Seq numbers are contiguous per segment,
+			// so this is exact without re-reading the file.
+			depth += int(seg.lastSeq - s.cursor)
+		}
+	}
+	return depth
+}
+
+// Dropped returns how many un-acked records have been evicted by the
+// MaxBytes budget before the backend ever saw them.
+func (s *Spool) Dropped() uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.dropped
+}
+
+// Close closes the currently-open segment file. Safe to call once.
+func (s *Spool) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.cur != nil && s.cur.file != nil {
+		return s.cur.file.Close()
+	}
+	return nil
+}
+
+func (s *Spool) totalSizeLocked() int64 {
+	var total int64
+	for _, seg := range s.segments {
+		total += seg.size
+	}
+	return total
+}
+
+// evictLocked drops the oldest segment(s) - even un-acked - once total
+// on-disk size exceeds maxBytes. It never touches the open write segment,
+// so Append always has somewhere to land even under sustained backend
+// outages; the tradeoff is the oldest un-acked events are lost, counted in
+// dropped.
+func (s *Spool) evictLocked() {
+	for len(s.segments) > 1 && s.totalSizeLocked() > s.maxBytes {
+		oldest := s.segments[0]
+		if oldest == s.cur {
+			break
+		}
+		if err := os.Remove(oldest.path); err != nil && !os.IsNotExist(err) {
+			log.Printf("spool: failed to evict oldest segment %q: %v", oldest.path, err)
+			break
+		}
+		s.dropped += uint64(oldest.count)
+		if oldest.lastSeq > s.cursor {
+			s.cursor = oldest.lastSeq
+			if err := s.persistCursorLocked(); err != nil {
+				log.Printf("spool: failed to persist cursor after eviction: %v", err)
+			}
+		}
+		s.segments = s.segments[1:]
+	}
+}
+
+func (s *Spool) rotateLocked() error {
+	if s.cur != nil && s.cur.file != nil {
+		if err := s.cur.file.Close(); err != nil {
+			return fmt.Errorf("spool: failed to close segment before rotation: %w", err)
+		}
+		s.cur.file = nil
+	}
+
+	path := filepath.Join(s.dir, fmt.Sprintf("%020d%s", s.nextSeq, segmentSuffix))
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("spool: failed to create segment %q: %w", path, err)
+	}
+
+	seg := &segment{path: path, file: f, firstSeq: s.nextSeq}
+	s.segments = append(s.segments, seg)
+	s.cur = seg
+	return nil
+}
+
+func (s *Spool) openTailForWrite() error {
+	if len(s.segments) == 0 {
+		return s.rotateLocked()
+	}
+
+	tail := s.segments[len(s.segments)-1]
+	// Strip any torn record a crash left at the tail before reopening for
+	// append, so new writes land right after the last good record instead of
+	// after the garbage - otherwise readRecord/readSegment/Iterate break at
+	// the tear and never reach anything appended past it.
+	if err := os.Truncate(tail.path, tail.validBytes); err != nil {
+		return fmt.Errorf("spool: failed to truncate segment %q to last valid record: %w", tail.path, err)
+	}
+	tail.size = tail.validBytes
+
+	f, err := os.OpenFile(tail.path, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("spool: failed to reopen segment %q: %w", tail.path, err)
+	}
+	tail.file = f
+	s.cur = tail
+	return nil
+}
+
+func (s *Spool) loadSegments() error {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return fmt.Errorf("spool: failed to list dir %q: %w", s.dir, err)
+	}
+
+	var paths []string
+	for _, e := range entries {
+		if !e.IsDir() && strings.HasSuffix(e.Name(), segmentSuffix) {
+			paths = append(paths, filepath.Join(s.dir, e.Name()))
+		}
+	}
+	sort.Strings(paths) // zero-padded firstSeq in the filename sorts correctly
+
+	for _, path := range paths {
+		seg, err := loadSegment(path)
+		if err != nil {
+			return err
+		}
+		if seg.count == 0 {
+			// Empty/corrupt-from-the-start segment left by a crash right
+			// after rotation; nothing in it is worth keeping.
+			os.Remove(path)
+			continue
+		}
+		if seg.lastSeq+1 > s.nextSeq {
+			s.nextSeq = seg.lastSeq + 1
+		}
+		s.segments = append(s.segments, seg)
+	}
+	return nil
+}
+
+func loadSegment(path string) (*segment, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("spool: failed to open segment %q: %w", path, err)
+	}
+	defer f.Close()
+
+	seg := &segment{path: path}
+	r := bufio.NewReader(f)
+	for {
+		seq, payload, err := readRecord(r)
+		if err != nil {
+			// A truncated or corrupt record is exactly what a crash
+			// mid-write looks like - stop here, same as buffer.Recover.
+			break
+		}
+		if seg.count == 0 {
+			seg.firstSeq = seq
+		}
+		seg.lastSeq = seq
+		seg.count++
+		seg.validBytes += int64(20 + len(payload))
+	}
+
+	seg.size = seg.validBytes
+	return seg, nil
+}
+
+func readSegment(path string) ([]Record, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	r := bufio.NewReader(f)
+	var records []Record
+	for {
+		seq, payload, err := readRecord(r)
+		if err != nil {
+			break
+		}
+		var event storage.Event
+		if err := msgpack.Unmarshal(payload, &event); err != nil {
+			break
+		}
+		records = append(records, Record{Seq: seq, Event: event})
+	}
+	return records, nil
+}
+
+// readRecord reads one framed record: magic, seq, length, CRC32, then
+// payload. Any error (including io.EOF) means stop - the caller treats that
+// as "nothing more to read", whether that's a clean end of file or a
+// truncated tail left by a crash mid-write.
+func readRecord(r *bufio.Reader) (uint64, []byte, error) {
+	header := make([]byte, 20)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return 0, nil, err
+	}
+
+	magic := binary.BigEndian.Uint32(header[0:4])
+	if magic != spoolMagic {
+		return 0, nil, fmt.Errorf("spool: bad record magic")
+	}
+	seq := binary.BigEndian.Uint64(header[4:12])
+	length := binary.BigEndian.Uint32(header[12:16])
+	wantCRC := binary.BigEndian.Uint32(header[16:20])
+
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return 0, nil, err
+	}
+	if crc32.ChecksumIEEE(payload) != wantCRC {
+		return 0, nil, fmt.Errorf("spool: record CRC mismatch")
+	}
+
+	return seq, payload, nil
+}
+
+func (s *Spool) persistCursorLocked() error {
+	tmp := filepath.Join(s.dir, cursorFileName+".tmp")
+	if err := os.WriteFile(tmp, []byte(strconv.FormatUint(s.cursor, 10)), 0644); err != nil {
+		return fmt.Errorf("spool: failed to write cursor: %w", err)
+	}
+	return os.Rename(tmp, filepath.Join(s.dir, cursorFileName))
+}
+
+func (s *Spool) loadCursor() error {
+	data, err := os.ReadFile(filepath.Join(s.dir, cursorFileName))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("spool: failed to read cursor: %w", err)
+	}
+
+	cursor, err := strconv.ParseUint(strings.TrimSpace(string(data)), 10, 64)
+	if err != nil {
+		return fmt.Errorf("spool: corrupt cursor file: %w", err)
+	}
+	s.cursor = cursor
+	return nil
+}
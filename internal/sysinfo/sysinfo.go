@@ -0,0 +1,153 @@
+// Package sysinfo abstracts the per-OS probes the Watcher's collector loop
+// depends on - which window has focus, how long the user has gone without
+// input, and whether the OS considers them unreachable (full-screen game,
+// presentation mode, Do Not Disturb). Before this package existed that data
+// came straight from internal/win32, which hard-coupled monitor.Monitor to
+// Windows; now each platform gets its own build-tagged implementation
+// (sysinfo_windows.go, sysinfo_linux.go, sysinfo_darwin.go) behind these
+// interfaces, in the style of gopsutil's per-OS host/cpu split, and
+// monitor.Monitor depends only on Provider so tests and headless builds can
+// inject a fake.
+package sysinfo
+
+import "time"
+
+// WindowHandle is a portable window identifier: an HWND on Windows, an X11
+// Window ID on Linux, a Core Graphics window number on macOS. Callers should
+// treat it as opaque beyond equality/zero checks - 0 means "no window" (e.g.
+// a locked workstation), mirroring win32.GetForegroundWindow's existing
+// zero-means-none contract.
+type WindowHandle uint64
+
+// WindowInfo describes the foreground window at the moment Foreground was
+// called.
+type WindowInfo struct {
+	Handle      WindowHandle
+	Title       string
+	ProcessName string
+	PID         uint32 // 0 if the platform couldn't resolve one (e.g. no window focused)
+}
+
+// ForegroundWindow reports which window currently has focus.
+type ForegroundWindow interface {
+	// Foreground returns the current foreground window, or a zero-value
+	// WindowInfo (Handle == 0) if none exists (e.g. workstation locked).
+	Foreground() (WindowInfo, error)
+}
+
+// IdleClock reports how long the user has gone without keyboard/mouse
+// input.
+type IdleClock interface {
+	// IdleDuration returns how long it's been since the last input event.
+	IdleDuration() (time.Duration, error)
+}
+
+// PresenceState reports OS-level states the collector loop treats
+// specially.
+type PresenceState interface {
+	// FullScreenExclusive reports whether a full-screen game or video is
+	// currently occupying the display (Smart Full Stop relies on this to
+	// skip ticks entirely rather than log an empty desktop).
+	FullScreenExclusive() (bool, error)
+
+	// Busy reports whether the OS considers the user unreachable (e.g.
+	// presentation mode, Do Not Disturb).
+	Busy() (bool, error)
+}
+
+// ProcessStats is a process' resource usage at sample time, enriching the
+// WindowInfo.PID ProcessSampler was asked about. All-zero on platforms
+// without a sampler (see ProcessSampler).
+type ProcessStats struct {
+	CPUPercent    float64 // percent of one core since the previous sample of this PID; 0 on a PID's first sample
+	WorkingSetRSS uint64  // bytes
+	IOReadBytes   uint64  // cumulative bytes read by the process
+	IOWriteBytes  uint64  // cumulative bytes written by the process
+	HandleCount   uint32  // open OS handle count
+}
+
+// ProcessSampler reports resource usage for a given process.
+type ProcessSampler interface {
+	// SampleProcess returns pid's resource usage, or a zero-value
+	// ProcessStats and nil error on a platform with no sampler (only
+	// Windows has one today, via win32.ProcessSampler) or if pid is 0.
+	SampleProcess(pid uint32) (ProcessStats, error)
+}
+
+// InputStats is a snapshot of real keystroke/mouse activity over
+// InputActivity's rolling window, replacing the idle-duration-only guess
+// monitor.calculateInputScore otherwise falls back to.
+type InputStats struct {
+	Keystrokes    uint32
+	MouseClicks   uint32
+	MouseTravelPx uint64
+	Intensity     float32 // normalized 0-1 over the window; only meaningful when Supported
+	Supported     bool    // false on platforms with no low-level hook (only Windows has one today)
+}
+
+// InputActivity reports real input-device activity, as measured by a
+// platform's low-level hooks rather than inferred from idle time alone.
+type InputActivity interface {
+	// InputActivity returns the current rolling-window snapshot, or a
+	// zero-value, unsupported InputStats and nil error on a platform
+	// without a hook subsystem (or if installing the hooks failed).
+	InputActivity() (InputStats, error)
+}
+
+// SystemPressure is a snapshot of OS-level resource pressure: battery state,
+// disk I/O queueing on the volume hosting the database, and system-wide CPU
+// load. buffer.AdaptivePolicy uses this to decide how aggressively to
+// coalesce flushes - on AC power with an idle disk, small frequent flushes
+// are fine; on battery or under disk pressure, they should coalesce up to a
+// configurable ceiling instead.
+type SystemPressure struct {
+	OnBattery       bool
+	BatteryPercent  int     // 0-100; only meaningful when OnBattery
+	DiskQueueLength float64 // average outstanding disk I/Os on the system volume
+	DiskBytesPerSec uint64  // bytes/sec written to the system volume
+	CPUPercent      float64 // system-wide CPU utilization, 0-100
+	Supported       bool    // false on platforms with no pressure probe (only Windows has one today)
+}
+
+// SystemPressureProbe reports live OS resource pressure.
+type SystemPressureProbe interface {
+	// SystemPressure returns the current snapshot, or a zero-value,
+	// unsupported SystemPressure and nil error on a platform without a
+	// probe.
+	SystemPressure() (SystemPressure, error)
+}
+
+// WindowSnapshotInfo describes one visible top-level window, as enumerated
+// by DesktopSnapshot - not just the single foreground window Foreground
+// reports.
+type WindowSnapshotInfo struct {
+	Handle                   WindowHandle
+	PID                      uint32
+	ProcessName              string // resolved the same way WindowInfo.ProcessName is
+	Title                    string
+	Left, Top, Right, Bottom int32
+	ZOrder                   int // 0-based, front-to-back
+}
+
+// DesktopSnapshot enumerates the full visible, z-ordered set of top-level
+// windows, useful for reconstructing multi-monitor context a single
+// foreground window can't ("what was I looking at").
+type DesktopSnapshot interface {
+	// EnumWindows returns every visible, non-tool, non-cloaked top-level
+	// window, front-to-back by z-order, or nil and nil error on a platform
+	// without an enumerator (only Windows has one today - see
+	// win32.EnumTopLevelWindows). deadline bounds the whole walk; zero
+	// means no deadline.
+	EnumWindows(deadline time.Duration) ([]WindowSnapshotInfo, error)
+}
+
+// Provider bundles all seven probes behind one value, as returned by New.
+type Provider interface {
+	ForegroundWindow
+	IdleClock
+	PresenceState
+	ProcessSampler
+	InputActivity
+	SystemPressureProbe
+	DesktopSnapshot
+}
@@ -0,0 +1,192 @@
+//go:build darwin
+
+package sysinfo
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/ebitengine/purego"
+)
+
+// kCGEventSourceStateCombinedSessionState, per CoreGraphics/CGEventSource.h.
+const cgEventSourceStateCombinedSessionState = 0
+
+// kCGAnyInputEventType, per CoreGraphics/CGEventTypes.h - "since last event
+// of any kind".
+const cgAnyInputEventType = ^uint32(0)
+
+// CGWindowListOption/CGWindowID constants, per CoreGraphics/CGWindow.h.
+const (
+	kCGWindowListOptionOnScreenOnly = 1 << 0
+	kCGNullWindowID                 = 0
+)
+
+// kCFNumberSInt64Type, per CoreFoundation/CFNumber.h.
+const kCFNumberSInt64Type = 4
+
+// kCFStringEncodingUTF8, per CoreFoundation/CFString.h.
+const kCFStringEncodingUTF8 = 0x08000100
+
+// darwinProvider talks to CoreGraphics and CoreFoundation via purego
+// (dynamic dlopen/dlsym - no cgo) for the foreground window and idle time.
+type darwinProvider struct {
+	cgWindowListCopyWindowInfo             func(option uint32, relativeToWindow uint32) uintptr
+	cgEventSourceSecondsSinceLastEventType func(stateID uint32, eventType uint32) float64
+
+	cfArrayGetCount        func(arr uintptr) int64
+	cfArrayGetValueAtIndex func(arr uintptr, idx int64) uintptr
+	cfDictionaryGetValue   func(dict, key uintptr) uintptr
+	cfNumberGetValue       func(num uintptr, theType int32, out *int64) bool
+	cfStringGetCString     func(str uintptr, buf *byte, bufSize int64, encoding uint32) bool
+	cfRelease              func(ref uintptr)
+
+	kCGWindowOwnerPID uintptr
+	kCGWindowName     uintptr
+	kCGWindowNumber   uintptr
+}
+
+// New returns the macOS Provider, backed by CoreGraphics and CoreFoundation.
+func New() (Provider, error) {
+	cg, err := purego.Dlopen("/System/Library/Frameworks/CoreGraphics.framework/CoreGraphics", purego.RTLD_LAZY|purego.RTLD_GLOBAL)
+	if err != nil {
+		return nil, fmt.Errorf("sysinfo: dlopen CoreGraphics: %w", err)
+	}
+	cf, err := purego.Dlopen("/System/Library/Frameworks/CoreFoundation.framework/CoreFoundation", purego.RTLD_LAZY|purego.RTLD_GLOBAL)
+	if err != nil {
+		return nil, fmt.Errorf("sysinfo: dlopen CoreFoundation: %w", err)
+	}
+
+	p := &darwinProvider{}
+	purego.RegisterLibFunc(&p.cgWindowListCopyWindowInfo, cg, "CGWindowListCopyWindowInfo")
+	purego.RegisterLibFunc(&p.cgEventSourceSecondsSinceLastEventType, cg, "CGEventSourceSecondsSinceLastEventType")
+	purego.RegisterLibFunc(&p.cfArrayGetCount, cf, "CFArrayGetCount")
+	purego.RegisterLibFunc(&p.cfArrayGetValueAtIndex, cf, "CFArrayGetValueAtIndex")
+	purego.RegisterLibFunc(&p.cfDictionaryGetValue, cf, "CFDictionaryGetValue")
+	purego.RegisterLibFunc(&p.cfNumberGetValue, cf, "CFNumberGetValue")
+	purego.RegisterLibFunc(&p.cfStringGetCString, cf, "CFStringGetCString")
+	purego.RegisterLibFunc(&p.cfRelease, cf, "CFRelease")
+
+	var cfStringCreateWithCString func(alloc uintptr, s string, encoding uint32) uintptr
+	purego.RegisterLibFunc(&cfStringCreateWithCString, cf, "CFStringCreateWithCString")
+
+	p.kCGWindowOwnerPID = cfStringCreateWithCString(0, "kCGWindowOwnerPID", kCFStringEncodingUTF8)
+	p.kCGWindowName = cfStringCreateWithCString(0, "kCGWindowName", kCFStringEncodingUTF8)
+	p.kCGWindowNumber = cfStringCreateWithCString(0, "kCGWindowNumber", kCFStringEncodingUTF8)
+
+	return p, nil
+}
+
+func (p *darwinProvider) Foreground() (WindowInfo, error) {
+	list := p.cgWindowListCopyWindowInfo(kCGWindowListOptionOnScreenOnly, kCGNullWindowID)
+	if list == 0 {
+		return WindowInfo{}, nil
+	}
+	defer p.cfRelease(list)
+
+	// CGWindowListCopyWindowInfo orders windows front-to-back, so index 0
+	// is the foreground one.
+	if p.cfArrayGetCount(list) == 0 {
+		return WindowInfo{}, nil
+	}
+	win := p.cfArrayGetValueAtIndex(list, 0)
+
+	number, _ := p.cfNumber(p.cfDictionaryGetValue(win, p.kCGWindowNumber))
+	pid, _ := p.cfNumber(p.cfDictionaryGetValue(win, p.kCGWindowOwnerPID))
+	title := p.cfString(p.cfDictionaryGetValue(win, p.kCGWindowName))
+
+	return WindowInfo{
+		Handle:      WindowHandle(number),
+		Title:       title,
+		ProcessName: processNameForPID(uint32(pid)),
+		PID:         uint32(pid),
+	}, nil
+}
+
+// cfNumber reads a CFNumberRef as an int64. Returns 0 if ref is NULL (the
+// key was absent from the window's dictionary).
+func (p *darwinProvider) cfNumber(ref uintptr) (int64, bool) {
+	if ref == 0 {
+		return 0, false
+	}
+	var out int64
+	if !p.cfNumberGetValue(ref, kCFNumberSInt64Type, &out) {
+		return 0, false
+	}
+	return out, true
+}
+
+// cfString reads a CFStringRef into a Go string via a fixed-size scratch
+// buffer, matching win32.TextBufferPool's approach of avoiding a length
+// round-trip for the common case of short window titles.
+func (p *darwinProvider) cfString(ref uintptr) string {
+	if ref == 0 {
+		return "Unknown"
+	}
+	buf := make([]byte, 1024)
+	if !p.cfStringGetCString(ref, &buf[0], int64(len(buf)), kCFStringEncodingUTF8) {
+		return "Unknown"
+	}
+	n := 0
+	for n < len(buf) && buf[n] != 0 {
+		n++
+	}
+	return string(buf[:n])
+}
+
+func (p *darwinProvider) IdleDuration() (time.Duration, error) {
+	secs := p.cgEventSourceSecondsSinceLastEventType(cgEventSourceStateCombinedSessionState, cgAnyInputEventType)
+	return time.Duration(secs * float64(time.Second)), nil
+}
+
+// FullScreenExclusive has no direct CoreGraphics equivalent of Windows'
+// QUNS_RUNNING_D3D_FULL_SCREEN; a full-screen app on macOS is just a window
+// sized to the display, which isn't distinguishable here without private
+// API, so it's reported as false.
+func (p *darwinProvider) FullScreenExclusive() (bool, error) {
+	return false, nil
+}
+
+// Busy has no direct CoreGraphics equivalent either (Do Not Disturb state
+// is only exposed via the private NSUserDefaults-backed notification
+// center plist, not a public framework call), so it's reported as false.
+func (p *darwinProvider) Busy() (bool, error) {
+	return false, nil
+}
+
+// SampleProcess has no macOS implementation yet (no equivalent of
+// win32.ProcessSampler's NtQuerySystemInformation walk), so it always
+// reports a zero-value ProcessStats.
+func (p *darwinProvider) SampleProcess(pid uint32) (ProcessStats, error) {
+	return ProcessStats{}, nil
+}
+
+// InputActivity has no macOS implementation yet (no equivalent of
+// win32.HookCollector's WH_KEYBOARD_LL/WH_MOUSE_LL hooks - CGEventTap
+// would be the analog, but isn't wired in), so it always reports a
+// zero-value, unsupported InputStats.
+func (p *darwinProvider) InputActivity() (InputStats, error) {
+	return InputStats{}, nil
+}
+
+// SystemPressure has no macOS implementation yet (no equivalent of
+// win32.GetSystemPowerStatus/DiskPressureSampler/CPUSampler - IOKit's power
+// source APIs and host_statistics would be the analogs, but aren't wired
+// in), so it always reports a zero-value, unsupported SystemPressure.
+func (p *darwinProvider) SystemPressure() (SystemPressure, error) {
+	return SystemPressure{}, nil
+}
+
+// EnumWindows has no macOS implementation yet (CGWindowListCopyWindowInfo
+// would be the analog - it already reports z-order via kCGWindowLayer and
+// bounds via kCGWindowBounds - but isn't wired in), so it always reports
+// nil, nil.
+func (p *darwinProvider) EnumWindows(deadline time.Duration) ([]WindowSnapshotInfo, error) {
+	return nil, nil
+}
+
+// processNameForPID is a stub until a public libproc call (proc_name) is
+// wired in; the PID is still useful on its own for now.
+func processNameForPID(pid uint32) string {
+	return fmt.Sprintf("PID_%d", pid)
+}
@@ -0,0 +1,226 @@
+//go:build linux
+
+package sysinfo
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jezek/xgb"
+	"github.com/jezek/xgb/screensaver"
+	"github.com/jezek/xgb/xproto"
+)
+
+// linuxProvider talks to the X server over XCB (via jezek/xgb, a pure-Go
+// binding - no cgo) for the foreground window and idle time, and reads
+// /proc/<pid>/stat for the process name behind it.
+type linuxProvider struct {
+	conn *xgb.Conn
+	root xproto.Window
+
+	netActiveWindow xproto.Atom
+	netWMPid        xproto.Atom
+}
+
+// New returns the Linux Provider, backed by XCB and /proc.
+func New() (Provider, error) {
+	conn, err := xgb.NewConn()
+	if err != nil {
+		return nil, fmt.Errorf("sysinfo: connect to X server: %w", err)
+	}
+
+	if err := screensaver.Init(conn); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("sysinfo: init XScreenSaver extension: %w", err)
+	}
+
+	setup := xproto.Setup(conn)
+	root := setup.DefaultScreen(conn).Root
+
+	p := &linuxProvider{conn: conn, root: root}
+	p.netActiveWindow, err = internAtom(conn, "_NET_ACTIVE_WINDOW")
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	p.netWMPid, err = internAtom(conn, "_NET_WM_PID")
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	return p, nil
+}
+
+func internAtom(conn *xgb.Conn, name string) (xproto.Atom, error) {
+	reply, err := xproto.InternAtom(conn, false, uint16(len(name)), name).Reply()
+	if err != nil {
+		return 0, fmt.Errorf("sysinfo: intern atom %q: %w", name, err)
+	}
+	return reply.Atom, nil
+}
+
+func (p *linuxProvider) Foreground() (WindowInfo, error) {
+	prop, err := xproto.GetProperty(p.conn, false, p.root, p.netActiveWindow,
+		xproto.AtomWindow, 0, 1).Reply()
+	if err != nil {
+		return WindowInfo{}, fmt.Errorf("sysinfo: read _NET_ACTIVE_WINDOW: %w", err)
+	}
+	if len(prop.Value) < 4 {
+		// No window manager support, or nothing focused.
+		return WindowInfo{}, nil
+	}
+
+	win := xproto.Window(xgb.Get32(prop.Value))
+	if win == 0 {
+		return WindowInfo{}, nil
+	}
+
+	title := windowTitle(p.conn, win)
+	var pid uint32
+	processName := ""
+	if wpid, err := windowPID(p.conn, win, p.netWMPid); err == nil {
+		pid = wpid
+		processName = processNameForPID(pid)
+	}
+
+	return WindowInfo{
+		Handle:      WindowHandle(win),
+		Title:       title,
+		ProcessName: processName,
+		PID:         pid,
+	}, nil
+}
+
+// windowTitle tries _NET_WM_NAME (UTF-8) first, falling back to the legacy
+// WM_NAME (Latin-1/ICCCM COMPOUND_TEXT, treated as plain bytes) property.
+func windowTitle(conn *xgb.Conn, win xproto.Window) string {
+	for _, prop := range []string{"_NET_WM_NAME", "WM_NAME"} {
+		atom, err := internAtom(conn, prop)
+		if err != nil {
+			continue
+		}
+		reply, err := xproto.GetProperty(conn, false, win, atom, xproto.AtomAny, 0, 1024).Reply()
+		if err != nil || len(reply.Value) == 0 {
+			continue
+		}
+		return string(reply.Value)
+	}
+	return "Unknown"
+}
+
+// windowPID reads _NET_WM_PID, the EWMH-standard way a window advertises
+// the PID of the process that created it.
+func windowPID(conn *xgb.Conn, win xproto.Window, netWMPid xproto.Atom) (uint32, error) {
+	reply, err := xproto.GetProperty(conn, false, win, netWMPid, xproto.AtomCardinal, 0, 1).Reply()
+	if err != nil {
+		return 0, err
+	}
+	if len(reply.Value) < 4 {
+		return 0, fmt.Errorf("sysinfo: window has no _NET_WM_PID")
+	}
+	return xgb.Get32(reply.Value), nil
+}
+
+// processNameForPID reads comm out of /proc/<pid>/stat (field 2, between
+// the first '(' and last ')' so names containing spaces/parens survive).
+func processNameForPID(pid uint32) string {
+	data, err := os.ReadFile("/proc/" + strconv.FormatUint(uint64(pid), 10) + "/stat")
+	if err != nil {
+		return fmt.Sprintf("PID_%d", pid)
+	}
+	stat := string(data)
+	open := strings.IndexByte(stat, '(')
+	closeIdx := strings.LastIndexByte(stat, ')')
+	if open < 0 || closeIdx < open {
+		return fmt.Sprintf("PID_%d", pid)
+	}
+	return stat[open+1 : closeIdx]
+}
+
+func (p *linuxProvider) IdleDuration() (time.Duration, error) {
+	info, err := screensaver.QueryInfo(p.conn, xproto.Drawable(p.root)).Reply()
+	if err != nil {
+		return 0, fmt.Errorf("sysinfo: XScreenSaver QueryInfo: %w", err)
+	}
+	return time.Duration(info.MsSinceUserInput) * time.Millisecond, nil
+}
+
+// FullScreenExclusive reports whether the active window is in the EWMH
+// fullscreen state - the closest Linux equivalent of Windows' exclusive
+// full-screen D3D detection.
+func (p *linuxProvider) FullScreenExclusive() (bool, error) {
+	win, err := p.activeWindow()
+	if err != nil || win == 0 {
+		return false, err
+	}
+
+	netWMState, err := internAtom(p.conn, "_NET_WM_STATE")
+	if err != nil {
+		return false, err
+	}
+	netWMStateFullscreen, err := internAtom(p.conn, "_NET_WM_STATE_FULLSCREEN")
+	if err != nil {
+		return false, err
+	}
+
+	reply, err := xproto.GetProperty(p.conn, false, win, netWMState, xproto.AtomAtom, 0, 64).Reply()
+	if err != nil {
+		return false, fmt.Errorf("sysinfo: read _NET_WM_STATE: %w", err)
+	}
+	for i := 0; i+4 <= len(reply.Value); i += 4 {
+		if xproto.Atom(xgb.Get32(reply.Value[i:])) == netWMStateFullscreen {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// Busy has no standard EWMH/X11 equivalent of Windows' Do Not
+// Disturb/presentation-mode query, so it's reported as false.
+func (p *linuxProvider) Busy() (bool, error) {
+	return false, nil
+}
+
+// SampleProcess has no Linux implementation yet (no equivalent of
+// win32.ProcessSampler's NtQuerySystemInformation walk), so it always
+// reports a zero-value ProcessStats.
+func (p *linuxProvider) SampleProcess(pid uint32) (ProcessStats, error) {
+	return ProcessStats{}, nil
+}
+
+// InputActivity has no Linux implementation yet (no equivalent of
+// win32.HookCollector's WH_KEYBOARD_LL/WH_MOUSE_LL hooks), so it always
+// reports a zero-value, unsupported InputStats.
+func (p *linuxProvider) InputActivity() (InputStats, error) {
+	return InputStats{}, nil
+}
+
+// EnumWindows has no Linux implementation yet (no equivalent of
+// win32.EnumTopLevelWindows - an EWMH _NET_CLIENT_LIST_STACKING walk would
+// be the analog, but isn't wired in), so it always reports nil, nil.
+func (p *linuxProvider) EnumWindows(deadline time.Duration) ([]WindowSnapshotInfo, error) {
+	return nil, nil
+}
+
+// SystemPressure has no Linux implementation yet (no equivalent of
+// win32.GetSystemPowerStatus/DiskPressureSampler/CPUSampler), so it always
+// reports a zero-value, unsupported SystemPressure.
+func (p *linuxProvider) SystemPressure() (SystemPressure, error) {
+	return SystemPressure{}, nil
+}
+
+func (p *linuxProvider) activeWindow() (xproto.Window, error) {
+	prop, err := xproto.GetProperty(p.conn, false, p.root, p.netActiveWindow,
+		xproto.AtomWindow, 0, 1).Reply()
+	if err != nil {
+		return 0, fmt.Errorf("sysinfo: read _NET_ACTIVE_WINDOW: %w", err)
+	}
+	if len(prop.Value) < 4 {
+		return 0, nil
+	}
+	return xproto.Window(xgb.Get32(prop.Value)), nil
+}
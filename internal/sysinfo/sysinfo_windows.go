@@ -0,0 +1,201 @@
+//go:build windows
+
+package sysinfo
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"syscall"
+	"time"
+
+	"mnemosyne/internal/win32"
+)
+
+// windowsProvider adapts the pre-existing win32 package to Provider.
+type windowsProvider struct {
+	procSampler *win32.ProcessSampler
+	hooks       *win32.HookCollector // nil if the low-level hooks failed to install (see New)
+	cpuSampler  *win32.CPUSampler
+	diskSampler *win32.DiskPressureSampler // nil if the PDH counters failed to open (see New)
+}
+
+// New returns the Windows Provider, backed by internal/win32.
+func New() (Provider, error) {
+	hooks := win32.NewHookCollector()
+	if err := hooks.Start(context.Background()); err != nil {
+		// Best-effort: a locked-down session (e.g. no desktop, restricted
+		// token) can refuse SetWindowsHookExW. The rest of the provider
+		// still works - InputActivity just falls back to "unsupported" and
+		// monitor keeps its idle-duration heuristic.
+		log.Printf("sysinfo: low-level input hooks unavailable, falling back to idle-based intensity: %v", err)
+		hooks = nil
+	}
+
+	diskSampler, err := win32.NewDiskPressureSampler()
+	if err != nil {
+		// Best-effort: a restricted service account can be denied access to
+		// the PDH counters. SystemPressure still reports battery/CPU, just
+		// with the disk fields left at zero.
+		log.Printf("sysinfo: disk pressure counters unavailable, SystemPressure will report disk metrics as zero: %v", err)
+		diskSampler = nil
+	}
+
+	return windowsProvider{
+		procSampler: win32.NewProcessSampler(),
+		hooks:       hooks,
+		cpuSampler:  win32.NewCPUSampler(),
+		diskSampler: diskSampler,
+	}, nil
+}
+
+func (windowsProvider) Foreground() (WindowInfo, error) {
+	hwnd, err := win32.GetForegroundWindow()
+	if err != nil {
+		// No foreground window (e.g. workstation locked) - same
+		// zero-means-none contract win32 already exposes.
+		return WindowInfo{}, nil
+	}
+
+	title, err := win32.GetWindowText(hwnd)
+	if err != nil {
+		title = "Unknown"
+	}
+
+	var pid uint32
+	processName := ""
+	if _, p, err := win32.GetWindowThreadProcessId(hwnd); err == nil {
+		pid = p
+		processName = processNameForPID(pid)
+	}
+
+	return WindowInfo{
+		Handle:      WindowHandle(hwnd),
+		Title:       title,
+		ProcessName: processName,
+		PID:         pid,
+	}, nil
+}
+
+// SampleProcess reports pid's resource usage via win32.ProcessSampler
+// (NtQuerySystemInformation under the hood), or a zero-value ProcessStats
+// if pid is 0 (no foreground window resolved this tick).
+func (p windowsProvider) SampleProcess(pid uint32) (ProcessStats, error) {
+	if pid == 0 {
+		return ProcessStats{}, nil
+	}
+
+	stats, err := p.procSampler.Sample(pid)
+	if err != nil {
+		return ProcessStats{}, err
+	}
+
+	return ProcessStats{
+		CPUPercent:    stats.CPUPercent,
+		WorkingSetRSS: stats.WorkingSetRSS,
+		IOReadBytes:   stats.IOReadBytes,
+		IOWriteBytes:  stats.IOWriteBytes,
+		HandleCount:   stats.HandleCount,
+	}, nil
+}
+
+// InputActivity reports real keystroke/mouse counts from the low-level
+// hooks win32.HookCollector installs in New, or a zero-value, unsupported
+// InputStats if they failed to install.
+func (p windowsProvider) InputActivity() (InputStats, error) {
+	if p.hooks == nil {
+		return InputStats{}, nil
+	}
+
+	snap := p.hooks.Snapshot()
+	return InputStats{
+		Keystrokes:    snap.Keystrokes,
+		MouseClicks:   snap.MouseClicks,
+		MouseTravelPx: snap.MouseTravelPx,
+		Intensity:     snap.Intensity,
+		Supported:     true,
+	}, nil
+}
+
+// SystemPressure reports battery, disk queue, and CPU pressure via
+// win32.GetSystemPowerStatus, win32.DiskPressureSampler, and
+// win32.CPUSampler. Disk metrics stay zero if the PDH counters failed to
+// open (see New); everything else is still reported.
+func (p windowsProvider) SystemPressure() (SystemPressure, error) {
+	onBattery, batteryPercent, err := win32.GetSystemPowerStatus()
+	if err != nil {
+		return SystemPressure{}, err
+	}
+
+	cpuPercent, err := p.cpuSampler.Sample()
+	if err != nil {
+		return SystemPressure{}, err
+	}
+
+	var queueLength float64
+	var bytesPerSec uint64
+	if p.diskSampler != nil {
+		queueLength, bytesPerSec, err = p.diskSampler.Sample()
+		if err != nil {
+			return SystemPressure{}, err
+		}
+	}
+
+	return SystemPressure{
+		OnBattery:       onBattery,
+		BatteryPercent:  batteryPercent,
+		DiskQueueLength: queueLength,
+		DiskBytesPerSec: bytesPerSec,
+		CPUPercent:      cpuPercent,
+		Supported:       true,
+	}, nil
+}
+
+func (windowsProvider) IdleDuration() (time.Duration, error) {
+	ms, err := win32.GetIdleTime()
+	if err != nil {
+		return 0, err
+	}
+	return time.Duration(ms) * time.Millisecond, nil
+}
+
+func (windowsProvider) FullScreenExclusive() (bool, error) {
+	return win32.IsGameRunning()
+}
+
+func (windowsProvider) Busy() (bool, error) {
+	return win32.IsBusy()
+}
+
+// EnumWindows reports the full visible, z-ordered desktop via
+// win32.EnumTopLevelWindows (user32!EnumWindows + IsWindowVisible +
+// GWL_EXSTYLE + DWM's DWMWA_CLOAKED), respecting deadline so a hung window
+// can't stall the caller (see win32's SendMessageTimeoutW use).
+func (windowsProvider) EnumWindows(deadline time.Duration) ([]WindowSnapshotInfo, error) {
+	var snapshots []WindowSnapshotInfo
+	err := win32.EnumTopLevelWindows(deadline, func(hwnd syscall.Handle, pid uint32, title string, rect win32.RECT, zorder int) bool {
+		snapshots = append(snapshots, WindowSnapshotInfo{
+			Handle:      WindowHandle(hwnd),
+			PID:         pid,
+			ProcessName: processNameForPID(pid),
+			Title:       title,
+			Left:        rect.Left,
+			Top:         rect.Top,
+			Right:       rect.Right,
+			Bottom:      rect.Bottom,
+			ZOrder:      zorder,
+		})
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	return snapshots, nil
+}
+
+// processNameForPID resolves a PID to a process name. Simplified - in
+// production would use OpenProcess + QueryFullProcessImageName; win32
+// doesn't wrap that API yet.
+func processNameForPID(pid uint32) string {
+	return fmt.Sprintf("PID_%d", pid)
+}
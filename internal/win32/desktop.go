@@ -0,0 +1,257 @@
+//go:build windows
+
+package win32
+
+import (
+	"fmt"
+	"sync"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+var (
+	dwmapi = syscall.NewLazyDLL("dwmapi.dll")
+
+	procEnumWindows           = user32.NewProc("EnumWindows")
+	procIsWindowVisible       = user32.NewProc("IsWindowVisible")
+	procGetWindowLongPtrW     = user32.NewProc("GetWindowLongPtrW")
+	procSendMessageTimeoutW   = user32.NewProc("SendMessageTimeoutW")
+	procDwmGetWindowAttribute = dwmapi.NewProc("DwmGetWindowAttribute")
+)
+
+// gwlExStyle is GWL_EXSTYLE, GetWindowLongPtrW's nIndex for the window's
+// extended style bits. It's a var rather than a const: Go rejects
+// converting a negative constant straight to the unsigned uintptr
+// Call expects, but the same conversion on a variable sign-extends at
+// runtime exactly the way the 64-bit Windows calling convention wants.
+var gwlExStyle int32 = -20
+
+const (
+	wsExToolWindow = 0x00000080 // WS_EX_TOOLWINDOW
+
+	dwmwaCloaked = 14 // DWMWA_CLOAKED
+
+	wmGetText       = 0x000D
+	wmGetTextLength = 0x000E
+
+	smtoAbortIfHung = 0x0002 // SMTO_ABORTIFHUNG
+
+	// defaultTitleTimeout bounds a single SendMessageTimeoutW call against
+	// one window's title, so a hung window can only cost enumeration this
+	// much before EnumTopLevelWindows moves on to the next one.
+	defaultTitleTimeout = 200 * time.Millisecond
+)
+
+// windowTitleCacheEntry is one LRU slot: the UTF-16 length GetWindowText
+// last saw for a HWND, and the Go string that was marshalled that time. A
+// matching length on the next tick is treated as "title unchanged", the
+// common case for a static window, and skips the UTF16ToString conversion.
+type windowTitleCacheEntry struct {
+	length int
+	title  string
+}
+
+// windowTitleCache is an LRU of the last-seen title per HWND, bounded so
+// enumerating a long-running desktop's worth of transient windows over many
+// ticks doesn't grow it unbounded.
+type windowTitleCache struct {
+	mu       sync.Mutex
+	capacity int
+	order    []syscall.Handle // least-recently-used first
+	entries  map[syscall.Handle]windowTitleCacheEntry
+}
+
+// defaultWindowTitleCacheCapacity is generous enough to cover a desktop's
+// worth of top-level windows across several ticks.
+const defaultWindowTitleCacheCapacity = 256
+
+var windowTitleLRU = newWindowTitleCache(defaultWindowTitleCacheCapacity)
+
+func newWindowTitleCache(capacity int) *windowTitleCache {
+	return &windowTitleCache{
+		capacity: capacity,
+		entries:  make(map[syscall.Handle]windowTitleCacheEntry),
+	}
+}
+
+// touch marks hwnd as most-recently-used, evicting the oldest entry once
+// capacity is exceeded. Caller must hold c.mu.
+func (c *windowTitleCache) touch(hwnd syscall.Handle) {
+	for i, h := range c.order {
+		if h == hwnd {
+			c.order = append(c.order[:i], c.order[i+1:]...)
+			break
+		}
+	}
+	c.order = append(c.order, hwnd)
+	for len(c.order) > c.capacity {
+		delete(c.entries, c.order[0])
+		c.order = c.order[1:]
+	}
+}
+
+func (c *windowTitleCache) lookup(hwnd syscall.Handle, length int) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[hwnd]
+	if !ok || entry.length != length {
+		return "", false
+	}
+	c.touch(hwnd)
+	return entry.title, true
+}
+
+func (c *windowTitleCache) store(hwnd syscall.Handle, length int, title string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[hwnd] = windowTitleCacheEntry{length: length, title: title}
+	c.touch(hwnd)
+}
+
+// windowTitleTimeout reports hwnd's window text via SendMessageTimeoutW
+// with SMTO_ABORTIFHUNG, so a window stuck processing another message
+// can't stall the caller past timeout. Reuses textBufferPool for the
+// marshal, and windowTitleLRU to skip it entirely when the title's length
+// hasn't changed since the last call for this hwnd.
+func windowTitleTimeout(hwnd syscall.Handle, timeout time.Duration) (string, error) {
+	timeoutMs := uintptr(timeout.Milliseconds())
+
+	var length uintptr
+	ret, _, err := procSendMessageTimeoutW.Call(
+		uintptr(hwnd), wmGetTextLength, 0, 0,
+		smtoAbortIfHung, timeoutMs, uintptr(unsafe.Pointer(&length)),
+	)
+	if ret == 0 {
+		return "", fmt.Errorf("win32: SendMessageTimeoutW(WM_GETTEXTLENGTH) timed out or failed: %w", err)
+	}
+	if length == 0 {
+		return "", nil
+	}
+
+	if title, ok := windowTitleLRU.lookup(hwnd, int(length)); ok {
+		return title, nil
+	}
+
+	buf := textBufferPool.Get()
+	defer textBufferPool.Put(buf)
+	need := int(length) + 1
+	if need > len(buf) {
+		buf = make([]uint16, need)
+	}
+
+	var copied uintptr
+	ret, _, err = procSendMessageTimeoutW.Call(
+		uintptr(hwnd), wmGetText, uintptr(len(buf)), uintptr(unsafe.Pointer(&buf[0])),
+		smtoAbortIfHung, timeoutMs, uintptr(unsafe.Pointer(&copied)),
+	)
+	if ret == 0 {
+		return "", fmt.Errorf("win32: SendMessageTimeoutW(WM_GETTEXT) timed out or failed: %w", err)
+	}
+
+	title := UTF16ToString(buf[:copied])
+	windowTitleLRU.store(hwnd, int(length), title)
+	return title, nil
+}
+
+// isToolWindow reports whether hwnd carries WS_EX_TOOLWINDOW - a window
+// (e.g. a floating toolbar) that deliberately hides from the taskbar and
+// alt-tab, and so shouldn't appear in a "what was I looking at" snapshot
+// either.
+func isToolWindow(hwnd syscall.Handle) bool {
+	ret, _, _ := procGetWindowLongPtrW.Call(uintptr(hwnd), uintptr(gwlExStyle))
+	return ret&wsExToolWindow != 0
+}
+
+// isWindowCloaked reports whether DWM has cloaked hwnd - e.g. a window
+// living on a virtual desktop other than the current one, which
+// IsWindowVisible alone doesn't account for.
+func isWindowCloaked(hwnd syscall.Handle) bool {
+	var cloaked uint32
+	ret, _, _ := procDwmGetWindowAttribute.Call(
+		uintptr(hwnd), dwmwaCloaked,
+		uintptr(unsafe.Pointer(&cloaked)), unsafe.Sizeof(cloaked),
+	)
+	return ret == 0 && cloaked != 0
+}
+
+// enumWalkState carries one EnumTopLevelWindows call's parameters and
+// progress across the synchronous EnumWindows callback, the same pattern
+// hook.go's activeCollector uses for its own HOOKPROC.
+type enumWalkState struct {
+	deadline time.Time
+	fn       func(hwnd syscall.Handle, pid uint32, title string, rect RECT, zorder int) bool
+	zorder   int
+}
+
+var (
+	enumMu       sync.Mutex
+	currentEnum  *enumWalkState
+	enumCallback = syscall.NewCallback(enumWindowsProc)
+)
+
+// enumWindowsProc is the WNDENUMPROC EnumWindows invokes for each top-level
+// window, already in z-order (front to back) per MSDN - no separate
+// GW_HWNDNEXT walk needed. Returning FALSE stops enumeration early, either
+// because the deadline elapsed or because the caller's fn asked to stop.
+func enumWindowsProc(hwnd, _ uintptr) uintptr {
+	st := currentEnum
+	if st == nil {
+		return 0
+	}
+	if !st.deadline.IsZero() && time.Now().After(st.deadline) {
+		return 0
+	}
+
+	h := syscall.Handle(hwnd)
+	if visible, _, _ := procIsWindowVisible.Call(hwnd); visible == 0 {
+		return 1
+	}
+	if isToolWindow(h) || isWindowCloaked(h) {
+		return 1
+	}
+
+	rect, err := GetWindowRect(h)
+	if err != nil {
+		return 1
+	}
+
+	title, err := windowTitleTimeout(h, defaultTitleTimeout)
+	if err != nil {
+		title = ""
+	}
+
+	_, pid, _ := GetWindowThreadProcessId(h)
+
+	zorder := st.zorder
+	st.zorder++
+
+	if !st.fn(h, pid, title, *rect, zorder) {
+		return 0
+	}
+	return 1
+}
+
+// EnumTopLevelWindows walks every visible, non-tool, non-cloaked top-level
+// window on the desktop, front-to-back by z-order, calling fn for each one.
+// fn's bool return follows WNDENUMPROC's own convention: false stops the
+// walk early.
+//
+// deadline bounds the whole walk - not just one hung window's
+// SendMessageTimeoutW call - so a desktop with many windows, or several
+// hung ones in a row, can't stall a caller (see monitor.Monitor.tick)
+// indefinitely. Zero means no deadline.
+func EnumTopLevelWindows(deadline time.Duration, fn func(hwnd syscall.Handle, pid uint32, title string, rect RECT, zorder int) bool) error {
+	enumMu.Lock()
+	defer enumMu.Unlock()
+
+	st := &enumWalkState{fn: fn}
+	if deadline > 0 {
+		st.deadline = time.Now().Add(deadline)
+	}
+	currentEnum = st
+	defer func() { currentEnum = nil }()
+
+	procEnumWindows.Call(enumCallback, 0)
+	return nil
+}
@@ -0,0 +1,313 @@
+//go:build windows
+
+package win32
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+// nowUnix is time.Now().Unix(), broken out so bucket math reads the same
+// wall-clock second regardless of which goroutine/thread calls it.
+func nowUnix() int64 { return time.Now().Unix() }
+
+var (
+	procSetWindowsHookExW   = user32.NewProc("SetWindowsHookExW")
+	procUnhookWindowsHookEx = user32.NewProc("UnhookWindowsHookEx")
+	procCallNextHookEx      = user32.NewProc("CallNextHookEx")
+	procGetMessageW         = user32.NewProc("GetMessageW")
+	procPostThreadMessageW  = user32.NewProc("PostThreadMessageW")
+	procGetCurrentThreadId  = kernel32.NewProc("GetCurrentThreadId")
+)
+
+const (
+	whKeyboardLL = 13
+	whMouseLL    = 14
+	hcAction     = 0
+
+	wmKeyDown     = 0x0100
+	wmSysKeyDown  = 0x0104
+	wmLButtonDown = 0x0201
+	wmRButtonDown = 0x0204
+	wmMButtonDown = 0x0207
+	wmMouseMove   = 0x0200
+	wmQuit        = 0x0012
+)
+
+// point mirrors POINT.
+type point struct{ X, Y int32 }
+
+// msllHookStruct mirrors MSLLHOOKSTRUCT, the lParam WH_MOUSE_LL passes to
+// its hook procedure.
+type msllHookStruct struct {
+	Pt          point
+	MouseData   uint32
+	Flags       uint32
+	Time        uint32
+	DwExtraInfo uintptr
+}
+
+// msg mirrors MSG, as filled in by GetMessageW.
+type msg struct {
+	HWND    uintptr
+	Message uint32
+	WParam  uintptr
+	LParam  uintptr
+	Time    uint32
+	Pt      point
+}
+
+// inputBucketSeconds is both the ring size and the rolling window
+// HookCollector.Snapshot sums over - matches the 5s window
+// monitor.calculateInputScoreWindow already uses for its idle-based
+// heuristic, so the two stay comparable.
+const inputBucketSeconds = 5
+
+// bucket holds one second's worth of input counts. sec is the Unix second
+// this bucket currently represents; a mismatch between sec and "now" means
+// the bucket is stale (from a previous lap around the ring) and should be
+// treated as empty rather than reused as-is.
+type bucket struct {
+	sec         int64
+	keystrokes  uint32
+	mouseClicks uint32
+	moveTravel  uint64 // cumulative pixel distance moved
+}
+
+// InputStats is a snapshot of low-level input activity over HookCollector's
+// rolling window.
+type InputStats struct {
+	Keystrokes    uint32
+	MouseClicks   uint32
+	MouseTravelPx uint64
+	Intensity     float32 // normalized 0-1, see HookCollector.Snapshot
+}
+
+// Normalization caps for Snapshot's Intensity score: roughly "typing at a
+// brisk pace", "a few clicks a second" and "a full screen-width mouse
+// sweep", each over the whole rolling window. Deliberately approximate -
+// the goal is a usable 0-1 signal, not a precise model of human input.
+const (
+	maxKeystrokesPerWindow  = 40
+	maxMouseClicksPerWindow = 15
+	maxMouseTravelPerWindow = 6000
+)
+
+// HookCollector installs WH_KEYBOARD_LL/WH_MOUSE_LL global hooks on a
+// dedicated OS thread (SetWindowsHookExW requires the installing thread to
+// keep pumping messages for as long as the hook should stay live) and
+// counts events into a ring of 1-second buckets, lock-free on the hot path
+// so the hook callback - which runs on every keystroke/mouse move,
+// system-wide - never blocks.
+type HookCollector struct {
+	buckets [inputBucketSeconds]bucket
+
+	lastX, lastY int32 // atomic; last WM_MOUSEMOVE point, for travel-distance deltas
+	haveLast     uint32 // atomic bool; false until the first WM_MOUSEMOVE arrives
+
+	threadID uint32 // atomic; pump's OS thread ID, for PostThreadMessageW(WM_QUIT)
+	stopped  chan struct{}
+	wg       sync.WaitGroup
+}
+
+// NewHookCollector returns a HookCollector that isn't running yet - call
+// Start to install the hooks.
+func NewHookCollector() *HookCollector {
+	return &HookCollector{}
+}
+
+// activeCollector is the HookCollector currently receiving hook callbacks.
+// There's only ever one pump thread per process (sysinfo.New constructs a
+// single Provider), so a package-level slot is simpler than threading a
+// context pointer through SetWindowsHookExW's C-callable HOOKPROC.
+var activeCollector atomic.Value // holds *HookCollector
+
+// Start installs the low-level hooks on a dedicated OS thread and begins
+// counting input events. It returns once the hooks are confirmed installed
+// (or returns the install error); the pump thread then runs until ctx is
+// canceled or Stop is called. Safe to call again after Stop - e.g. after a
+// WM_ENDSESSION notification - each Start spins up a fresh pump thread.
+func (c *HookCollector) Start(ctx context.Context) error {
+	c.stopped = make(chan struct{})
+	ready := make(chan error, 1)
+	c.wg.Add(1)
+	go c.pump(ctx, ready)
+	return <-ready
+}
+
+// Stop unhooks and waits for the pump thread to exit. Safe to call on a
+// HookCollector that was never started, or more than once.
+func (c *HookCollector) Stop() {
+	if c.stopped == nil {
+		return
+	}
+	select {
+	case <-c.stopped:
+	default:
+		close(c.stopped)
+	}
+	c.wg.Wait()
+}
+
+func (c *HookCollector) pump(ctx context.Context, ready chan<- error) {
+	defer c.wg.Done()
+
+	// SetWindowsHookExW ties a hook to the thread that installed it, and
+	// WH_KEYBOARD_LL/WH_MOUSE_LL additionally require that thread to run a
+	// GetMessageW loop for the callback to actually fire - both reasons
+	// this needs its own locked OS thread rather than running on whatever
+	// goroutine happened to call Start.
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	threadIDRet, _, _ := procGetCurrentThreadId.Call()
+	atomic.StoreUint32(&c.threadID, uint32(threadIDRet))
+	activeCollector.Store(c)
+	defer activeCollector.Store((*HookCollector)(nil))
+
+	// dwThreadId is 0 for both hooks: WH_KEYBOARD_LL/WH_MOUSE_LL are always
+	// installed system-wide regardless of that parameter, per MSDN.
+	kbHook, _, err := procSetWindowsHookExW.Call(uintptr(whKeyboardLL), keyboardHookCallback, 0, 0)
+	if kbHook == 0 {
+		ready <- fmt.Errorf("win32: SetWindowsHookExW(WH_KEYBOARD_LL): %w", err)
+		return
+	}
+	defer procUnhookWindowsHookEx.Call(kbHook)
+
+	mouseHook, _, err := procSetWindowsHookExW.Call(uintptr(whMouseLL), mouseHookCallback, 0, 0)
+	if mouseHook == 0 {
+		ready <- fmt.Errorf("win32: SetWindowsHookExW(WH_MOUSE_LL): %w", err)
+		return
+	}
+	defer procUnhookWindowsHookEx.Call(mouseHook)
+
+	ready <- nil
+
+	// Unblock the pump's GetMessageW loop below by posting WM_QUIT to its
+	// own thread once the caller asks us to stop, from whichever goroutine
+	// that happens on.
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-c.stopped:
+		}
+		procPostThreadMessageW.Call(uintptr(atomic.LoadUint32(&c.threadID)), wmQuit, 0, 0)
+	}()
+
+	var m msg
+	for {
+		ret, _, _ := procGetMessageW.Call(uintptr(unsafe.Pointer(&m)), 0, 0, 0)
+		// GetMessageW returns 0 for WM_QUIT, -1 on error, nonzero otherwise.
+		if int32(ret) <= 0 {
+			return
+		}
+	}
+}
+
+// bucketFor returns the ring slot for sec, resetting its counts first if it
+// still holds a stale second's data. A hook callback landing on the exact
+// second a bucket rolls over can race another callback doing the same
+// reset - acceptably rare for a heuristic intensity signal.
+func (c *HookCollector) bucketFor(sec int64) *bucket {
+	b := &c.buckets[sec%inputBucketSeconds]
+	if atomic.SwapInt64(&b.sec, sec) != sec {
+		atomic.StoreUint32(&b.keystrokes, 0)
+		atomic.StoreUint32(&b.mouseClicks, 0)
+		atomic.StoreUint64(&b.moveTravel, 0)
+	}
+	return b
+}
+
+func (c *HookCollector) addKeystroke(now int64) {
+	atomic.AddUint32(&c.bucketFor(now).keystrokes, 1)
+}
+
+func (c *HookCollector) addClick(now int64) {
+	atomic.AddUint32(&c.bucketFor(now).mouseClicks, 1)
+}
+
+func (c *HookCollector) addMouseMove(now int64, x, y int32) {
+	lastX := atomic.SwapInt32(&c.lastX, x)
+	lastY := atomic.SwapInt32(&c.lastY, y)
+	if !atomic.CompareAndSwapUint32(&c.haveLast, 0, 1) {
+		dx := float64(x - lastX)
+		dy := float64(y - lastY)
+		dist := uint64(math.Hypot(dx, dy))
+		atomic.AddUint64(&c.bucketFor(now).moveTravel, dist)
+	}
+}
+
+// Snapshot sums the last inputBucketSeconds of activity into totals and a
+// normalized 0-1 Intensity score, for the collector's tick loop to stamp
+// onto buffer.LogEntry in place of the idle-duration-only heuristic.
+func (c *HookCollector) Snapshot() InputStats {
+	now := nowUnix()
+
+	var stats InputStats
+	for i := int64(0); i < inputBucketSeconds; i++ {
+		sec := now - i
+		b := &c.buckets[sec%inputBucketSeconds]
+		if atomic.LoadInt64(&b.sec) != sec {
+			continue // bucket holds a different (stale or not-yet-used) second
+		}
+		stats.Keystrokes += atomic.LoadUint32(&b.keystrokes)
+		stats.MouseClicks += atomic.LoadUint32(&b.mouseClicks)
+		stats.MouseTravelPx += atomic.LoadUint64(&b.moveTravel)
+	}
+
+	score := float64(stats.Keystrokes)/maxKeystrokesPerWindow +
+		float64(stats.MouseClicks)/maxMouseClicksPerWindow +
+		float64(stats.MouseTravelPx)/maxMouseTravelPerWindow
+	if score > 1 {
+		score = 1
+	}
+	stats.Intensity = float32(score)
+	return stats
+}
+
+// keyboardHookCallback/mouseHookCallback are the HOOKPROC entry points
+// SetWindowsHookExW invokes, wrapped via syscall.NewCallback so Windows can
+// call back into Go code. Per MSDN, a low-level hook must call
+// CallNextHookEx unconditionally and return its result, or every other
+// hook in the chain (including the OS's own) stops receiving events.
+var (
+	keyboardHookCallback = syscall.NewCallback(lowLevelKeyboardProc)
+	mouseHookCallback    = syscall.NewCallback(lowLevelMouseProc)
+)
+
+func lowLevelKeyboardProc(nCode, wParam, lParam uintptr) uintptr {
+	if nCode == hcAction {
+		if c, ok := activeCollector.Load().(*HookCollector); ok && c != nil {
+			switch wParam {
+			case wmKeyDown, wmSysKeyDown:
+				c.addKeystroke(nowUnix())
+			}
+		}
+	}
+	ret, _, _ := procCallNextHookEx.Call(0, nCode, wParam, lParam)
+	return ret
+}
+
+func lowLevelMouseProc(nCode, wParam, lParam uintptr) uintptr {
+	if nCode == hcAction {
+		if c, ok := activeCollector.Load().(*HookCollector); ok && c != nil {
+			now := nowUnix()
+			switch wParam {
+			case wmLButtonDown, wmRButtonDown, wmMButtonDown:
+				c.addClick(now)
+			case wmMouseMove:
+				info := (*msllHookStruct)(unsafe.Pointer(lParam))
+				c.addMouseMove(now, info.Pt.X, info.Pt.Y)
+			}
+		}
+	}
+	ret, _, _ := procCallNextHookEx.Call(0, nCode, wParam, lParam)
+	return ret
+}
@@ -0,0 +1,202 @@
+//go:build windows
+
+package win32
+
+import (
+	"fmt"
+	"sync"
+	"syscall"
+	"unsafe"
+)
+
+var (
+	pdh = syscall.NewLazyDLL("pdh.dll")
+
+	procGetSystemPowerStatus        = kernel32.NewProc("GetSystemPowerStatus")
+	procGetSystemTimes              = kernel32.NewProc("GetSystemTimes")
+	procPdhOpenQueryW               = pdh.NewProc("PdhOpenQueryW")
+	procPdhAddEnglishCounterW       = pdh.NewProc("PdhAddEnglishCounterW")
+	procPdhCollectQueryData         = pdh.NewProc("PdhCollectQueryData")
+	procPdhGetFormattedCounterValue = pdh.NewProc("PdhGetFormattedCounterValue")
+	procPdhCloseQuery               = pdh.NewProc("PdhCloseQuery")
+)
+
+// systemPowerStatus mirrors the Windows SYSTEM_POWER_STATUS structure.
+type systemPowerStatus struct {
+	ACLineStatus        byte
+	BatteryFlag         byte
+	BatteryLifePercent  byte
+	Reserved1           byte
+	BatteryLifeTime     uint32
+	BatteryFullLifeTime uint32
+}
+
+const (
+	acLineStatusOffline = 0
+	batteryFlagUnknown  = 255
+)
+
+// GetSystemPowerStatus reports whether the machine is currently running on
+// battery and, if so, its remaining charge percentage.
+func GetSystemPowerStatus() (onBattery bool, batteryPercent int, err error) {
+	var status systemPowerStatus
+	ret, _, callErr := procGetSystemPowerStatus.Call(uintptr(unsafe.Pointer(&status)))
+	if ret == 0 {
+		return false, 0, fmt.Errorf("GetSystemPowerStatus failed: %w", callErr)
+	}
+
+	onBattery = status.ACLineStatus == acLineStatusOffline
+	if status.BatteryFlag == batteryFlagUnknown {
+		return onBattery, 0, nil
+	}
+	return onBattery, int(status.BatteryLifePercent), nil
+}
+
+// fileTime mirrors the Windows FILETIME structure as returned by
+// GetSystemTimes.
+type fileTime struct {
+	LowDateTime  uint32
+	HighDateTime uint32
+}
+
+func (f fileTime) ticks() uint64 {
+	return uint64(f.HighDateTime)<<32 | uint64(f.LowDateTime)
+}
+
+// CPUSampler tracks system-wide CPU load between successive Sample calls,
+// differencing GetSystemTimes' cumulative idle/kernel/user counters - the
+// same delta-since-last-sample shape ProcessSampler uses for per-process
+// CPU%, just rolled up to the whole machine.
+type CPUSampler struct {
+	mu                             sync.Mutex
+	haveLast                       bool
+	lastIdle, lastKernel, lastUser uint64
+}
+
+// NewCPUSampler returns a ready-to-use system-wide CPU sampler.
+func NewCPUSampler() *CPUSampler {
+	return &CPUSampler{}
+}
+
+// Sample returns system-wide CPU utilization (0-100) since the previous
+// call, or 0 on the first call since there's nothing to diff against yet.
+func (c *CPUSampler) Sample() (float64, error) {
+	var idle, kernel, user fileTime
+	ret, _, err := procGetSystemTimes.Call(
+		uintptr(unsafe.Pointer(&idle)),
+		uintptr(unsafe.Pointer(&kernel)),
+		uintptr(unsafe.Pointer(&user)),
+	)
+	if ret == 0 {
+		return 0, fmt.Errorf("GetSystemTimes failed: %w", err)
+	}
+
+	idleTicks, kernelTicks, userTicks := idle.ticks(), kernel.ticks(), user.ticks()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.haveLast {
+		c.lastIdle, c.lastKernel, c.lastUser = idleTicks, kernelTicks, userTicks
+		c.haveLast = true
+		return 0, nil
+	}
+
+	idleDelta := idleTicks - c.lastIdle
+	// kernelTicks already includes idle time on Windows, so total elapsed
+	// CPU time is kernel+user, not kernel+user+idle.
+	totalDelta := (kernelTicks - c.lastKernel) + (userTicks - c.lastUser)
+	c.lastIdle, c.lastKernel, c.lastUser = idleTicks, kernelTicks, userTicks
+
+	if totalDelta == 0 {
+		return 0, nil
+	}
+	busyDelta := totalDelta - idleDelta
+	return float64(busyDelta) / float64(totalDelta) * 100, nil
+}
+
+// pdhFmtCounterValue mirrors the double-typed case of the Windows
+// PDH_FMT_COUNTERVALUE union (CStatus followed by 4 bytes of padding so the
+// double lands on an 8-byte boundary).
+type pdhFmtCounterValue struct {
+	CStatus uint32
+	_       uint32
+	Value   float64
+}
+
+const pdhFmtDouble = 0x00000200
+
+// DiskPressureSampler reads the "Avg. Disk Queue Length" and "Disk
+// Bytes/sec" PDH counters for the machine's physical disks as a whole,
+// close enough for a single-volume workstation install and avoiding having
+// to resolve a DB path to a physical disk index.
+type DiskPressureSampler struct {
+	query        uintptr
+	queueCounter uintptr
+	bytesCounter uintptr
+}
+
+// NewDiskPressureSampler opens a PDH query against the "_Total" physical
+// disk instance.
+func NewDiskPressureSampler() (*DiskPressureSampler, error) {
+	var query uintptr
+	if ret, _, _ := procPdhOpenQueryW.Call(0, 0, uintptr(unsafe.Pointer(&query))); ret != 0 {
+		return nil, fmt.Errorf("PdhOpenQuery failed: 0x%x", ret)
+	}
+
+	addCounter := func(path string) (uintptr, error) {
+		ptr, err := syscall.UTF16PtrFromString(path)
+		if err != nil {
+			return 0, err
+		}
+		var counter uintptr
+		if ret, _, _ := procPdhAddEnglishCounterW.Call(query, uintptr(unsafe.Pointer(ptr)), 0, uintptr(unsafe.Pointer(&counter))); ret != 0 {
+			return 0, fmt.Errorf("PdhAddEnglishCounter %q failed: 0x%x", path, ret)
+		}
+		return counter, nil
+	}
+
+	queueCounter, err := addCounter(`\PhysicalDisk(_Total)\Avg. Disk Queue Length`)
+	if err != nil {
+		procPdhCloseQuery.Call(query)
+		return nil, err
+	}
+	bytesCounter, err := addCounter(`\PhysicalDisk(_Total)\Disk Bytes/sec`)
+	if err != nil {
+		procPdhCloseQuery.Call(query)
+		return nil, err
+	}
+
+	return &DiskPressureSampler{query: query, queueCounter: queueCounter, bytesCounter: bytesCounter}, nil
+}
+
+// Sample collects one round of both counters. PDH counters need at least
+// two CollectQueryData calls before they're meaningful, so the Sample
+// immediately after NewDiskPressureSampler returns zeros.
+func (d *DiskPressureSampler) Sample() (queueLength float64, bytesPerSec uint64, err error) {
+	if ret, _, _ := procPdhCollectQueryData.Call(d.query); ret != 0 {
+		return 0, 0, fmt.Errorf("PdhCollectQueryData failed: 0x%x", ret)
+	}
+
+	queueLength = d.formatted(d.queueCounter)
+	bytes := d.formatted(d.bytesCounter)
+	return queueLength, uint64(bytes), nil
+}
+
+// formatted reads counter as a double, treating any PDH error (most
+// commonly PDH_CSTATUS_INVALID_DATA on the first sample, before a second
+// CollectQueryData) as "no data yet" rather than a hard failure.
+func (d *DiskPressureSampler) formatted(counter uintptr) float64 {
+	var value pdhFmtCounterValue
+	ret, _, _ := procPdhGetFormattedCounterValue.Call(counter, pdhFmtDouble, 0, uintptr(unsafe.Pointer(&value)))
+	if ret != 0 {
+		return 0
+	}
+	return value.Value
+}
+
+// Close releases the PDH query.
+func (d *DiskPressureSampler) Close() error {
+	procPdhCloseQuery.Call(d.query)
+	return nil
+}
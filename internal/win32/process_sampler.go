@@ -0,0 +1,328 @@
+//go:build windows
+
+package win32
+
+import (
+	"fmt"
+	"sync"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+var (
+	ntdll    = syscall.NewLazyDLL("ntdll.dll")
+	advapi32 = syscall.NewLazyDLL("advapi32.dll")
+	psapi    = syscall.NewLazyDLL("psapi.dll")
+
+	procNtQuerySystemInformation = ntdll.NewProc("NtQuerySystemInformation")
+	procOpenProcessToken         = advapi32.NewProc("OpenProcessToken")
+	procLookupPrivilegeValueW    = advapi32.NewProc("LookupPrivilegeValueW")
+	procAdjustTokenPrivileges    = advapi32.NewProc("AdjustTokenPrivileges")
+	procGetProcessTimes          = kernel32.NewProc("GetProcessTimes")
+	procGetProcessIoCounters     = kernel32.NewProc("GetProcessIoCounters")
+	procGetProcessMemoryInfo     = psapi.NewProc("GetProcessMemoryInfo")
+)
+
+// systemInformationClass values, per the undocumented NtQuerySystemInformation
+// (see gosigar's sys/windows/ntquery.go).
+const systemProcessInformationClass = 5
+
+// statusInfoLengthMismatch is the NTSTATUS NtQuerySystemInformation returns
+// when the caller's buffer is too small to hold the process list.
+const statusInfoLengthMismatch = 0xC0000004
+
+// systemProcessInformation mirrors the undocumented SYSTEM_PROCESS_INFORMATION
+// struct (fields after HandleCount are only used by the VM_COUNTERS/IO_COUNTERS
+// readings this sampler needs; ImageName/thread array are skipped by walking
+// via NextEntryOffset rather than parsing the struct's tail).
+type systemProcessInformation struct {
+	NextEntryOffset              uint32
+	NumberOfThreads              uint32
+	WorkingSetPrivateSize        int64
+	HardFaultCount               uint32
+	NumberOfThreadsHighWatermark uint32
+	CycleTime                    uint64
+	CreateTime                   int64
+	UserTime                     int64
+	KernelTime                   int64
+	ImageNameLength              uint16
+	ImageNameMaximumLength       uint16
+	ImageNameBuffer              uintptr
+	BasePriority                 int32
+	UniqueProcessID              uintptr
+	InheritedFromUniqueProcessID uintptr
+	HandleCount                  uint32
+	SessionID                    uint32
+	UniqueProcessKey             uintptr
+	PeakVirtualSize              uintptr
+	VirtualSize                  uintptr
+	PageFaultCount               uint32
+	PeakWorkingSetSize           uintptr
+	WorkingSetSize               uintptr
+	QuotaPeakPagedPoolUsage      uintptr
+	QuotaPagedPoolUsage          uintptr
+	QuotaPeakNonPagedPoolUsage   uintptr
+	QuotaNonPagedPoolUsage       uintptr
+	PagefileUsage                uintptr
+	PeakPagefileUsage            uintptr
+	PrivatePageCount             uintptr
+	ReadOperationCount           int64
+	WriteOperationCount          int64
+	OtherOperationCount          int64
+	ReadTransferCount            int64
+	WriteTransferCount           int64
+	OtherTransferCount           int64
+}
+
+// ProcessStats is a single process' resource usage, as sampled by
+// ProcessSampler.
+type ProcessStats struct {
+	PID           uint32
+	CPUPercent    float64 // (KernelTime+UserTime) delta over wall-clock delta since the previous sample of this PID; 0 on a PID's first sample
+	WorkingSetRSS uint64  // bytes
+	IOReadBytes   uint64
+	IOWriteBytes  uint64
+	HandleCount   uint32
+}
+
+// cpuSample is the per-PID state ProcessSampler needs to turn a cumulative
+// KernelTime+UserTime reading into a CPU% delta.
+type cpuSample struct {
+	kernelPlusUser int64 // 100ns units, as returned by the kernel
+	at             time.Time
+}
+
+// ProcessSampler derives per-process CPU/memory/IO/handle stats for the
+// Watcher's buffer.LogEntry enrichment. Sample walks the whole system
+// process list in one NtQuerySystemInformation(SystemProcessInformation)
+// syscall (in the spirit of gosigar's sys/windows/ntquery.go) - far
+// cheaper per tick than OpenProcess-ing the single PID in question. It
+// caches each PID's previous KernelTime+UserTime so CPU% can be derived
+// without the caller (buffer/monitor) having to remember state itself.
+type ProcessSampler struct {
+	mu      sync.Mutex
+	lastCPU map[uint32]cpuSample
+}
+
+// NewProcessSampler returns a ready-to-use sampler. Call
+// EnableDebugPrivilege once at startup so protected/elevated processes
+// aren't silently skipped.
+func NewProcessSampler() *ProcessSampler {
+	return &ProcessSampler{lastCPU: make(map[uint32]cpuSample)}
+}
+
+// Sample returns resource usage for pid, by walking the system-wide
+// process list and picking pid out of it.
+func (s *ProcessSampler) Sample(pid uint32) (ProcessStats, error) {
+	procs, err := querySystemProcesses()
+	if err != nil {
+		return ProcessStats{}, err
+	}
+
+	for _, p := range procs {
+		if uint32(p.UniqueProcessID) != pid {
+			continue
+		}
+		return s.toStats(p), nil
+	}
+	return ProcessStats{}, fmt.Errorf("win32: pid %d not found in system process list", pid)
+}
+
+func (s *ProcessSampler) toStats(p systemProcessInformation) ProcessStats {
+	pid := uint32(p.UniqueProcessID)
+	total := p.KernelTime + p.UserTime
+	now := time.Now()
+
+	s.mu.Lock()
+	prev, have := s.lastCPU[pid]
+	s.lastCPU[pid] = cpuSample{kernelPlusUser: total, at: now}
+	s.mu.Unlock()
+
+	var cpuPercent float64
+	if have {
+		wallDelta := now.Sub(prev.at)
+		if wallDelta > 0 {
+			cpuDelta := time.Duration(total-prev.kernelPlusUser) * 100 // 100ns units -> ns
+			cpuPercent = 100 * float64(cpuDelta) / float64(wallDelta)
+		}
+	}
+
+	return ProcessStats{
+		PID:           pid,
+		CPUPercent:    cpuPercent,
+		WorkingSetRSS: uint64(p.WorkingSetSize),
+		IOReadBytes:   uint64(p.ReadTransferCount),
+		IOWriteBytes:  uint64(p.WriteTransferCount),
+		HandleCount:   p.HandleCount,
+	}
+}
+
+// querySystemProcesses calls NtQuerySystemInformation(SystemProcessInformation)
+// with a growing buffer until it's large enough, then walks the returned
+// SYSTEM_PROCESS_INFORMATION linked list via NextEntryOffset.
+func querySystemProcesses() ([]systemProcessInformation, error) {
+	bufLen := uint32(64 * 1024)
+	for {
+		buf := make([]byte, bufLen)
+		var returnLength uint32
+		status, _, _ := procNtQuerySystemInformation.Call(
+			uintptr(systemProcessInformationClass),
+			uintptr(unsafe.Pointer(&buf[0])),
+			uintptr(bufLen),
+			uintptr(unsafe.Pointer(&returnLength)),
+		)
+		if status == statusInfoLengthMismatch {
+			bufLen *= 2
+			continue
+		}
+		if status != 0 {
+			return nil, fmt.Errorf("win32: NtQuerySystemInformation failed: 0x%X", status)
+		}
+
+		var procs []systemProcessInformation
+		offset := uint32(0)
+		for {
+			info := *(*systemProcessInformation)(unsafe.Pointer(&buf[offset]))
+			procs = append(procs, info)
+			if info.NextEntryOffset == 0 {
+				break
+			}
+			offset += info.NextEntryOffset
+		}
+		return procs, nil
+	}
+}
+
+// ioCounters mirrors IO_COUNTERS (kernel32's GetProcessIoCounters output).
+type ioCounters struct {
+	ReadOperationCount  uint64
+	WriteOperationCount uint64
+	OtherOperationCount uint64
+	ReadTransferCount   uint64
+	WriteTransferCount  uint64
+	OtherTransferCount  uint64
+}
+
+// processMemoryCounters mirrors PROCESS_MEMORY_COUNTERS (psapi's
+// GetProcessMemoryInfo output) field for field; SampleSingle reads only
+// WorkingSetSize.
+type processMemoryCounters struct {
+	Cb                         uint32
+	PageFaultCount             uint32
+	PeakWorkingSetSize         uintptr
+	WorkingSetSize             uintptr
+	QuotaPeakPagedPoolUsage    uintptr
+	QuotaPagedPoolUsage        uintptr
+	QuotaPeakNonPagedPoolUsage uintptr
+	QuotaNonPagedPoolUsage     uintptr
+	PagefileUsage              uintptr
+	PeakPagefileUsage          uintptr
+}
+
+// SampleSingle is the OpenProcess-based fallback for when the caller only
+// ever needs one PID and doesn't want to pay for a full system walk - e.g.
+// a diagnostics command. Sample (NtQuerySystemInformation) is cheaper per
+// tick when sampling PIDs already resolved from GetWindowThreadProcessId.
+func SampleSingle(pid uint32) (ProcessStats, error) {
+	const (
+		processQueryInformation = 0x0400
+		processVMRead           = 0x0010
+	)
+	handle, err := syscall.OpenProcess(processQueryInformation|processVMRead, false, pid)
+	if err != nil {
+		return ProcessStats{}, fmt.Errorf("win32: OpenProcess(%d): %w", pid, err)
+	}
+	defer syscall.CloseHandle(handle)
+
+	var creation, exit, kernel, user syscall.Filetime
+	if err := syscall.GetProcessTimes(handle, &creation, &exit, &kernel, &user); err != nil {
+		return ProcessStats{}, fmt.Errorf("win32: GetProcessTimes(%d): %w", pid, err)
+	}
+
+	var io ioCounters
+	ret, _, err := procGetProcessIoCounters.Call(uintptr(handle), uintptr(unsafe.Pointer(&io)))
+	if ret == 0 {
+		return ProcessStats{}, fmt.Errorf("win32: GetProcessIoCounters(%d): %w", pid, err)
+	}
+
+	var mem processMemoryCounters
+	mem.Cb = uint32(unsafe.Sizeof(mem))
+	ret, _, err = procGetProcessMemoryInfo.Call(uintptr(handle), uintptr(unsafe.Pointer(&mem)), uintptr(mem.Cb))
+	if ret == 0 {
+		return ProcessStats{}, fmt.Errorf("win32: GetProcessMemoryInfo(%d): %w", pid, err)
+	}
+
+	return ProcessStats{
+		PID:           pid,
+		WorkingSetRSS: uint64(mem.WorkingSetSize),
+		IOReadBytes:   io.ReadTransferCount,
+		IOWriteBytes:  io.WriteTransferCount,
+	}, nil
+}
+
+// luid mirrors LUID.
+type luid struct {
+	LowPart  uint32
+	HighPart int32
+}
+
+// tokenPrivileges mirrors TOKEN_PRIVILEGES with exactly one LUID_AND_ATTRIBUTES,
+// which is all EnableDebugPrivilege needs.
+type tokenPrivileges struct {
+	PrivilegeCount uint32
+	Luid           luid
+	Attributes     uint32
+}
+
+// EnableDebugPrivilege enables SeDebugPrivilege on the current process'
+// token via AdjustTokenPrivileges, so ProcessSampler/SampleSingle can see
+// protected/elevated processes that would otherwise return access-denied.
+// Call once at startup; returns an error if the caller's token doesn't
+// hold the privilege (e.g. not running elevated).
+func EnableDebugPrivilege() error {
+	const (
+		tokenAdjustPrivileges = 0x0020
+		tokenQuery            = 0x0008
+		sePrivilegeEnabled    = 0x00000002
+	)
+
+	currentProcess, err := syscall.GetCurrentProcess()
+	if err != nil {
+		return fmt.Errorf("win32: GetCurrentProcess: %w", err)
+	}
+
+	var token syscall.Token
+	ret, _, err := procOpenProcessToken.Call(
+		uintptr(currentProcess),
+		uintptr(tokenAdjustPrivileges|tokenQuery),
+		uintptr(unsafe.Pointer(&token)),
+	)
+	if ret == 0 {
+		return fmt.Errorf("win32: OpenProcessToken: %w", err)
+	}
+	defer syscall.CloseHandle(syscall.Handle(token))
+
+	namePtr, err := syscall.UTF16PtrFromString("SeDebugPrivilege")
+	if err != nil {
+		return err
+	}
+
+	var priv tokenPrivileges
+	priv.PrivilegeCount = 1
+	priv.Attributes = sePrivilegeEnabled
+	ret, _, err = procLookupPrivilegeValueW.Call(0, uintptr(unsafe.Pointer(namePtr)), uintptr(unsafe.Pointer(&priv.Luid)))
+	if ret == 0 {
+		return fmt.Errorf("win32: LookupPrivilegeValue(SeDebugPrivilege): %w", err)
+	}
+
+	ret, _, err = procAdjustTokenPrivileges.Call(
+		uintptr(token), 0,
+		uintptr(unsafe.Pointer(&priv)), uintptr(unsafe.Sizeof(priv)),
+		0, 0,
+	)
+	if ret == 0 {
+		return fmt.Errorf("win32: AdjustTokenPrivileges(SeDebugPrivilege): %w", err)
+	}
+	return nil
+}
@@ -1,3 +1,5 @@
+//go:build windows
+
 // Package win32 provides low-level Windows API wrappers using syscall (NO CGO).
 // This is the only module allowed to use unsafe operations.
 package win32
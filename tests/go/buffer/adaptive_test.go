@@ -0,0 +1,143 @@
+// Tests for adaptive flush throttling (capacity/timeout tuning, Stats, and
+// PressurePolicy behavior when MaxCapacity is hit).
+package buffer_test
+
+import (
+	"context"
+	"time"
+
+	"testing"
+
+	"mnemosyne/internal/buffer"
+)
+
+// delaySink sleeps for delay before reporting success, simulating a slow
+// flush destination.
+type delaySink struct {
+	delay time.Duration
+}
+
+func (d *delaySink) Name() string { return "delay" }
+
+func (d *delaySink) Write(ctx context.Context, entries []buffer.LogEntry) error {
+	time.Sleep(d.delay)
+	return nil
+}
+
+// TestBufferAdaptiveGrowsUnderLatencyThenRecovers verifies that sustained
+// slow flushes grow the effective capacity past the baseline, and that
+// effective capacity shrinks back down once flushes get fast again.
+func TestBufferAdaptiveGrowsUnderLatencyThenRecovers(t *testing.T) {
+	config := buffer.BufferConfig{
+		Capacity:           5,
+		FlushTimeout:       time.Minute,
+		MaxCapacity:        40,
+		TargetFlushLatency: 10 * time.Millisecond,
+		PressurePolicy:     buffer.DropNewest,
+	}
+	buf := buffer.New(config)
+
+	slow := &delaySink{delay: 50 * time.Millisecond}
+	for i := 0; i < 3; i++ {
+		buf.Add(buffer.LogEntry{SessionUUID: "s", WindowHandle: int64(i)})
+		if err := buf.Flush(slow); err != nil {
+			t.Fatalf("Flush failed: %v", err)
+		}
+	}
+
+	grown := buf.Stats()
+	if grown.EffectiveCapacity <= config.Capacity {
+		t.Fatalf("Expected effective capacity to grow past baseline %d, got %d", config.Capacity, grown.EffectiveCapacity)
+	}
+	if len(grown.RecentFlushLatencies) != 3 {
+		t.Errorf("Expected 3 recorded flush latencies, got %d", len(grown.RecentFlushLatencies))
+	}
+
+	fast := &delaySink{delay: 0}
+	for i := 0; i < 20; i++ {
+		buf.Add(buffer.LogEntry{SessionUUID: "s", WindowHandle: int64(i)})
+		if err := buf.Flush(fast); err != nil {
+			t.Fatalf("Flush failed: %v", err)
+		}
+	}
+
+	recovered := buf.Stats()
+	if recovered.EffectiveCapacity >= grown.EffectiveCapacity {
+		t.Errorf("Expected effective capacity to shrink back down from %d, got %d", grown.EffectiveCapacity, recovered.EffectiveCapacity)
+	}
+}
+
+// TestBufferAdaptiveDisabledWithoutTarget verifies that leaving
+// TargetFlushLatency unset keeps Capacity/FlushTimeout fixed, matching
+// pre-adaptive behavior.
+func TestBufferAdaptiveDisabledWithoutTarget(t *testing.T) {
+	config := buffer.DefaultConfig()
+	buf := buffer.New(config)
+
+	slow := &delaySink{delay: 50 * time.Millisecond}
+	for i := 0; i < 3; i++ {
+		buf.Add(buffer.LogEntry{SessionUUID: "s", WindowHandle: int64(i)})
+		if err := buf.Flush(slow); err != nil {
+			t.Fatalf("Flush failed: %v", err)
+		}
+	}
+
+	stats := buf.Stats()
+	if stats.EffectiveCapacity != config.Capacity {
+		t.Errorf("Expected effective capacity to stay at baseline %d with no target set, got %d", config.Capacity, stats.EffectiveCapacity)
+	}
+}
+
+// TestBufferPressureDropNewest verifies DropNewest discards the incoming
+// entry once MaxCapacity is reached, without touching what's already held.
+func TestBufferPressureDropNewest(t *testing.T) {
+	config := buffer.BufferConfig{
+		Capacity:       100,
+		FlushTimeout:   time.Minute,
+		MaxCapacity:    3,
+		PressurePolicy: buffer.DropNewest,
+	}
+	buf := buffer.New(config)
+
+	for i := 0; i < 5; i++ {
+		buf.Add(buffer.LogEntry{SessionUUID: "s", WindowHandle: int64(i)})
+	}
+
+	if buf.Len() != 3 {
+		t.Errorf("Expected buffer to stay capped at 3 entries, got %d", buf.Len())
+	}
+	entries := buf.GetEntries()
+	if entries[0].WindowHandle != 0 {
+		t.Errorf("Expected DropNewest to keep the oldest entry first, got handle %d", entries[0].WindowHandle)
+	}
+	if buf.Stats().DroppedCount != 2 {
+		t.Errorf("Expected 2 dropped entries, got %d", buf.Stats().DroppedCount)
+	}
+}
+
+// TestBufferPressureDropOldest verifies DropOldest evicts entries[0] to make
+// room for the newest entry once MaxCapacity is reached.
+func TestBufferPressureDropOldest(t *testing.T) {
+	config := buffer.BufferConfig{
+		Capacity:       100,
+		FlushTimeout:   time.Minute,
+		MaxCapacity:    3,
+		PressurePolicy: buffer.DropOldest,
+	}
+	buf := buffer.New(config)
+
+	for i := 0; i < 5; i++ {
+		buf.Add(buffer.LogEntry{SessionUUID: "s", WindowHandle: int64(i)})
+	}
+
+	if buf.Len() != 3 {
+		t.Errorf("Expected buffer to stay capped at 3 entries, got %d", buf.Len())
+	}
+	entries := buf.GetEntries()
+	if entries[len(entries)-1].WindowHandle != 4 {
+		t.Errorf("Expected DropOldest to keep the newest entry last, got handle %d", entries[len(entries)-1].WindowHandle)
+	}
+	if buf.Stats().DroppedCount != 2 {
+		t.Errorf("Expected 2 dropped entries, got %d", buf.Stats().DroppedCount)
+	}
+}
@@ -29,7 +29,17 @@ func setupTestDB(t *testing.T) *sql.DB {
 			window_title TEXT,
 			window_hwnd INTEGER NOT NULL,
 			input_idle_ms INTEGER DEFAULT 0,
-			input_intensity REAL DEFAULT 0.0
+			input_intensity REAL DEFAULT 0.0,
+			screenshot_path TEXT,
+			end_unix_time INTEGER DEFAULT 0,
+			sample_count INTEGER DEFAULT 1,
+			input_intensity_sum REAL DEFAULT 0.0,
+			input_idle_min INTEGER DEFAULT 0,
+			screenshot_hash INTEGER DEFAULT 0,
+			host_uuid TEXT DEFAULT '',
+			keystrokes INTEGER DEFAULT 0,
+			mouse_clicks INTEGER DEFAULT 0,
+			mouse_travel_px INTEGER DEFAULT 0
 		)
 	`)
 	if err != nil {
@@ -135,7 +145,7 @@ func TestBufferFlush(t *testing.T) {
 	}
 
 	// Flush to database
-	err := buf.Flush(db)
+	err := buf.FlushDB(db)
 	if err != nil {
 		t.Fatalf("Failed to flush buffer: %v", err)
 	}
@@ -197,7 +207,7 @@ func TestBufferFlushEmpty(t *testing.T) {
 	buf := buffer.New(config)
 
 	// Flush empty buffer
-	err := buf.Flush(db)
+	err := buf.FlushDB(db)
 	if err != nil {
 		t.Fatalf("Failed to flush empty buffer: %v", err)
 	}
@@ -236,7 +246,7 @@ func TestBufferForceFlush(t *testing.T) {
 	}
 
 	// Force flush
-	err := buf.ForceFlush(db)
+	err := buf.ForceFlushDB(db)
 	if err != nil {
 		t.Fatalf("Failed to force flush: %v", err)
 	}
@@ -437,7 +447,7 @@ func TestBufferLastFlush(t *testing.T) {
 	// Flush
 	db := setupTestDB(t)
 	defer db.Close()
-	err := buf.Flush(db)
+	err := buf.FlushDB(db)
 	if err != nil {
 		t.Fatalf("Flush failed: %v", err)
 	}
@@ -448,6 +458,86 @@ func TestBufferLastFlush(t *testing.T) {
 	}
 }
 
+// TestBufferCoalesce verifies that with Coalesce enabled, a long run of
+// samples for the same window collapses into a single buffered entry with
+// correct range/aggregate fields.
+func TestBufferCoalesce(t *testing.T) {
+	config := buffer.DefaultConfig()
+	config.Coalesce = true
+	buf := buffer.New(config)
+
+	const samples = 1000
+	base := int64(1_000_000)
+	for i := 0; i < samples; i++ {
+		buf.Add(buffer.LogEntry{
+			SessionUUID:    "test-session",
+			UnixTime:       base + int64(i)*1000,
+			ProcessName:    "test.exe",
+			WindowTitle:    "Test Window",
+			WindowHandle:   12345,
+			InputIdleMs:    int64(i),
+			InputIntensity: float32(i%10) / 10,
+		})
+	}
+
+	if buf.Len() != 1 {
+		t.Fatalf("Expected 1000 identical samples to coalesce into 1 entry, got %d", buf.Len())
+	}
+
+	entry := buf.GetEntries()[0]
+	if entry.UnixTime != base {
+		t.Errorf("Expected UnixTime (run start) %d, got %d", base, entry.UnixTime)
+	}
+	if want := base + int64(samples-1)*1000; entry.EndUnixTime != want {
+		t.Errorf("Expected EndUnixTime (run end) %d, got %d", want, entry.EndUnixTime)
+	}
+	if entry.SampleCount != samples {
+		t.Errorf("Expected SampleCount %d, got %d", samples, entry.SampleCount)
+	}
+	if entry.InputIdleMin != 0 {
+		t.Errorf("Expected InputIdleMin 0, got %d", entry.InputIdleMin)
+	}
+	if entry.InputIntensity != 0.9 {
+		t.Errorf("Expected InputIntensity (max) 0.9, got %f", entry.InputIntensity)
+	}
+}
+
+// TestBufferCoalesceWindowChange verifies that a change of window starts a
+// new run instead of merging into the previous one.
+func TestBufferCoalesceWindowChange(t *testing.T) {
+	config := buffer.DefaultConfig()
+	config.Coalesce = true
+	buf := buffer.New(config)
+
+	for i := 0; i < 5; i++ {
+		buf.Add(buffer.LogEntry{
+			SessionUUID:  "test-session",
+			UnixTime:     1_000_000 + int64(i)*1000,
+			ProcessName:  "test.exe",
+			WindowTitle:  "Window A",
+			WindowHandle: 1,
+		})
+	}
+	buf.Add(buffer.LogEntry{
+		SessionUUID:  "test-session",
+		UnixTime:     1_010_000,
+		ProcessName:  "test.exe",
+		WindowTitle:  "Window B",
+		WindowHandle: 2,
+	})
+
+	entries := buf.GetEntries()
+	if len(entries) != 2 {
+		t.Fatalf("Expected window change to flush the prior run into its own entry, got %d entries", len(entries))
+	}
+	if entries[0].SampleCount != 5 {
+		t.Errorf("Expected first run to have SampleCount 5, got %d", entries[0].SampleCount)
+	}
+	if entries[1].WindowTitle != "Window B" {
+		t.Errorf("Expected second entry to be the new window, got %q", entries[1].WindowTitle)
+	}
+}
+
 // TestBufferMultipleFlushes tests multiple flush operations.
 func TestBufferMultipleFlushes(t *testing.T) {
 	db := setupTestDB(t)
@@ -469,7 +559,7 @@ func TestBufferMultipleFlushes(t *testing.T) {
 		}
 		buf.Add(entry)
 	}
-	buf.Flush(db)
+	buf.FlushDB(db)
 
 	// Second batch
 	for i := 0; i < 3; i++ {
@@ -484,7 +574,7 @@ func TestBufferMultipleFlushes(t *testing.T) {
 		}
 		buf.Add(entry)
 	}
-	buf.Flush(db)
+	buf.FlushDB(db)
 
 	// Verify all entries in database
 	var count int
@@ -0,0 +1,173 @@
+// Tests for buffer.Flusher.
+package buffer_test
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"mnemosyne/internal/buffer"
+)
+
+// flakySink is a Sink whose first failCount Write calls fail, and which can
+// simulate a slow sink via delay. Safe for concurrent use by the Flusher's
+// worker goroutine and the test goroutine.
+type flakySink struct {
+	mu        sync.Mutex
+	failCount int
+	delay     time.Duration
+	calls     int
+	written   [][]buffer.LogEntry
+}
+
+func (f *flakySink) Name() string { return "fake" }
+
+func (f *flakySink) Write(ctx context.Context, entries []buffer.LogEntry) error {
+	f.mu.Lock()
+	f.calls++
+	call := f.calls
+	f.mu.Unlock()
+
+	if f.delay > 0 {
+		select {
+		case <-time.After(f.delay):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	if call <= f.failCount {
+		return fmt.Errorf("fake sink failure on call %d", call)
+	}
+
+	cp := make([]buffer.LogEntry, len(entries))
+	copy(cp, entries)
+
+	f.mu.Lock()
+	f.written = append(f.written, cp)
+	f.mu.Unlock()
+	return nil
+}
+
+func (f *flakySink) callCount() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.calls
+}
+
+func (f *flakySink) writtenBatches() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.written)
+}
+
+func waitFor(t *testing.T, timeout time.Duration, cond func() bool) {
+	t.Helper()
+	deadline := time.After(timeout)
+	for {
+		if cond() {
+			return
+		}
+		select {
+		case <-deadline:
+			t.Fatal("condition not met before timeout")
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+}
+
+// TestFlusherTimeoutFlush verifies that a Flusher flushes on FlushTimeout
+// even though Capacity is never reached.
+func TestFlusherTimeoutFlush(t *testing.T) {
+	sink := &flakySink{}
+	cfg := buffer.FlusherConfig{
+		BufferConfig: buffer.BufferConfig{
+			Capacity:     100,
+			FlushTimeout: 50 * time.Millisecond,
+		},
+	}
+	fl := buffer.NewFlusher(cfg, sink)
+	defer fl.Close(context.Background())
+
+	fl.Submit(buffer.LogEntry{ProcessName: "test.exe"})
+
+	waitFor(t, 2*time.Second, func() bool { return sink.writtenBatches() == 1 })
+}
+
+// TestFlusherRetryThenSuccess verifies that a batch which fails a couple of
+// times is retried with backoff and eventually flushed, without ever being
+// reported through OnError.
+func TestFlusherRetryThenSuccess(t *testing.T) {
+	sink := &flakySink{failCount: 2}
+	var onErrCalls int
+	var mu sync.Mutex
+
+	cfg := buffer.FlusherConfig{
+		BufferConfig: buffer.BufferConfig{
+			Capacity:     1,
+			FlushTimeout: time.Hour,
+		},
+		MaxRetryDuration: 2 * time.Second,
+		OnError: func(batch []buffer.LogEntry, err error) {
+			mu.Lock()
+			onErrCalls++
+			mu.Unlock()
+		},
+	}
+	fl := buffer.NewFlusher(cfg, sink)
+	defer fl.Close(context.Background())
+
+	fl.Submit(buffer.LogEntry{ProcessName: "test.exe"})
+
+	waitFor(t, 3*time.Second, func() bool { return sink.writtenBatches() == 1 })
+
+	if calls := sink.callCount(); calls != 3 {
+		t.Errorf("Expected 3 Write attempts (2 failures + success), got %d", calls)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if onErrCalls != 0 {
+		t.Errorf("Expected OnError not to fire once the batch eventually succeeds, got %d calls", onErrCalls)
+	}
+}
+
+// TestFlusherCloseWaitsForInFlightFlush verifies that Close blocks until a
+// flush already underway finishes, instead of racing it - the
+// shutdown-while-flushing case.
+func TestFlusherCloseWaitsForInFlightFlush(t *testing.T) {
+	sink := &flakySink{delay: 200 * time.Millisecond}
+	cfg := buffer.FlusherConfig{
+		BufferConfig: buffer.BufferConfig{
+			Capacity:     1,
+			FlushTimeout: time.Hour,
+		},
+	}
+	fl := buffer.NewFlusher(cfg, sink)
+
+	// Triggers the capacity flush, which the worker goroutine starts
+	// running synchronously and will be sleeping inside by the time Close
+	// is called below. The flush starts at Submit, so the clock starts there
+	// too: Close can't return before Submit + delay.
+	start := time.Now()
+	fl.Submit(buffer.LogEntry{ProcessName: "test.exe"})
+	time.Sleep(20 * time.Millisecond)
+
+	unflushed, err := fl.Close(context.Background())
+	elapsed := time.Since(start)
+
+	if err != nil {
+		t.Fatalf("Close returned unexpected error: %v", err)
+	}
+	if unflushed != 0 {
+		t.Errorf("Expected 0 unflushed entries once the in-flight flush completes, got %d", unflushed)
+	}
+	if elapsed < sink.delay {
+		t.Errorf("Expected Close to wait out the in-flight flush (%v), returned only %v after Submit", sink.delay, elapsed)
+	}
+	if sink.writtenBatches() != 1 {
+		t.Errorf("Expected the in-flight batch to have been written, got %d batches", sink.writtenBatches())
+	}
+}
@@ -0,0 +1,119 @@
+// Tests for pressure-driven adaptive flushing (SystemPressurePolicy
+// hysteresis and Buffer.SetPressureReading/PressureState wiring).
+package buffer_test
+
+import (
+	"testing"
+	"time"
+
+	"mnemosyne/internal/buffer"
+)
+
+// TestSystemPressurePolicyUnsupportedMeansNoPressure verifies an
+// unsupported reading always reports "no pressure" rather than defaulting
+// to aggressive coalescing from a guess.
+func TestSystemPressurePolicyUnsupportedMeansNoPressure(t *testing.T) {
+	policy := buffer.NewSystemPressurePolicy()
+
+	deferral, relaxed := policy.Decide(buffer.PressureReading{
+		OnBattery:       true,
+		DiskQueueLength: 10,
+		Supported:       false,
+	})
+	if deferral != 0 || relaxed {
+		t.Errorf("Expected no deferral/relaxation for an unsupported reading, got deferral=%v relaxed=%v", deferral, relaxed)
+	}
+}
+
+// TestSystemPressurePolicyOnBatteryDefers verifies being on battery alone,
+// with an idle disk, is enough to trigger MaxDeferral and relaxed
+// durability.
+func TestSystemPressurePolicyOnBatteryDefers(t *testing.T) {
+	policy := buffer.NewSystemPressurePolicy()
+
+	deferral, relaxed := policy.Decide(buffer.PressureReading{
+		OnBattery: true,
+		Supported: true,
+	})
+	if deferral != policy.MaxDeferral || !relaxed {
+		t.Errorf("Expected MaxDeferral/relaxed on battery, got deferral=%v relaxed=%v", deferral, relaxed)
+	}
+}
+
+// TestSystemPressurePolicyDiskQueueHysteresis verifies the disk queue
+// watermarks need to cross the high mark to engage and drop back to the
+// low mark to disengage, rather than flipping at a single threshold.
+func TestSystemPressurePolicyDiskQueueHysteresis(t *testing.T) {
+	policy := buffer.NewSystemPressurePolicy()
+
+	if _, relaxed := policy.Decide(buffer.PressureReading{DiskQueueLength: 1.0, Supported: true}); relaxed {
+		t.Fatalf("Expected no pressure below the high watermark")
+	}
+
+	if _, relaxed := policy.Decide(buffer.PressureReading{DiskQueueLength: 2.5, Supported: true}); !relaxed {
+		t.Fatalf("Expected pressure once the disk queue crosses the high watermark")
+	}
+
+	if _, relaxed := policy.Decide(buffer.PressureReading{DiskQueueLength: 1.0, Supported: true}); !relaxed {
+		t.Fatalf("Expected pressure to persist inside the hysteresis band")
+	}
+
+	if _, relaxed := policy.Decide(buffer.PressureReading{DiskQueueLength: 0.2, Supported: true}); relaxed {
+		t.Fatalf("Expected pressure to clear once the disk queue drops below the low watermark")
+	}
+}
+
+// fakePolicy lets tests drive Buffer.SetPressureReading deterministically.
+type fakePolicy struct {
+	deferral time.Duration
+	relaxed  bool
+}
+
+func (f fakePolicy) Decide(buffer.PressureReading) (time.Duration, bool) {
+	return f.deferral, f.relaxed
+}
+
+// TestBufferSetPressureReadingReportsChangeEvents verifies
+// SetPressureReading only reports an event when the policy's decision
+// actually changes, and that PressureState reflects the latest decision.
+func TestBufferSetPressureReadingReportsChangeEvents(t *testing.T) {
+	config := buffer.BufferConfig{
+		Capacity:       10,
+		FlushTimeout:   time.Minute,
+		AdaptivePolicy: fakePolicy{deferral: 30 * time.Second, relaxed: true},
+	}
+	buf := buffer.New(config)
+
+	event, changed := buf.SetPressureReading(buffer.PressureReading{OnBattery: true, Supported: true})
+	if !changed {
+		t.Fatalf("Expected the first reading to report a change")
+	}
+	if event.DeferralMs != (30 * time.Second).Milliseconds() || !event.RelaxedDurability {
+		t.Errorf("Unexpected event: %+v", event)
+	}
+
+	deferral, relaxed := buf.PressureState()
+	if deferral != 30*time.Second || !relaxed {
+		t.Errorf("Expected PressureState to reflect the policy's decision, got deferral=%v relaxed=%v", deferral, relaxed)
+	}
+
+	if _, changed := buf.SetPressureReading(buffer.PressureReading{OnBattery: true, Supported: true}); changed {
+		t.Errorf("Expected no change event when the decision is unchanged")
+	}
+}
+
+// TestBufferSetPressureReadingNoopWithoutPolicy verifies SetPressureReading
+// is a no-op when AdaptivePolicy is unset, matching TargetFlushLatency's
+// opt-in convention.
+func TestBufferSetPressureReadingNoopWithoutPolicy(t *testing.T) {
+	buf := buffer.New(buffer.DefaultConfig())
+
+	event, changed := buf.SetPressureReading(buffer.PressureReading{OnBattery: true, Supported: true})
+	if changed || event != (buffer.PressureEvent{}) {
+		t.Errorf("Expected a no-op without AdaptivePolicy, got event=%+v changed=%v", event, changed)
+	}
+
+	if deferral, relaxed := buf.PressureState(); deferral != 0 || relaxed {
+		t.Errorf("Expected zero PressureState without AdaptivePolicy, got deferral=%v relaxed=%v", deferral, relaxed)
+	}
+}
@@ -0,0 +1,113 @@
+// Tests for the buffer package's pluggable Sink interface and MultiSink.
+package buffer_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"mnemosyne/internal/buffer"
+)
+
+// fakeSink records every batch it receives and can be told to fail.
+type fakeSink struct {
+	name    string
+	fail    bool
+	batches [][]buffer.LogEntry
+}
+
+func (f *fakeSink) Name() string { return f.name }
+
+func (f *fakeSink) Write(ctx context.Context, entries []buffer.LogEntry) error {
+	if f.fail {
+		return fmt.Errorf("%s: simulated failure", f.name)
+	}
+	f.batches = append(f.batches, entries)
+	return nil
+}
+
+func sampleEntry() buffer.LogEntry {
+	return buffer.LogEntry{
+		SessionUUID:  "test-session",
+		UnixTime:     time.Now().UnixMilli(),
+		ProcessName:  "test.exe",
+		WindowHandle: 1,
+	}
+}
+
+// TestFlushWithSink verifies Flush writes through an arbitrary Sink, not
+// just SQLite.
+func TestFlushWithSink(t *testing.T) {
+	buf := buffer.New(buffer.DefaultConfig())
+	buf.Add(sampleEntry())
+	buf.Add(sampleEntry())
+
+	sink := &fakeSink{name: "fake"}
+	if err := buf.Flush(sink); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+
+	if buf.Len() != 0 {
+		t.Errorf("Expected empty buffer after flush, got %d entries", buf.Len())
+	}
+	if len(sink.batches) != 1 || len(sink.batches[0]) != 2 {
+		t.Fatalf("Expected one batch of 2 entries, got %v", sink.batches)
+	}
+}
+
+// TestMultiSinkFailAll verifies a single failing sink fails the whole batch
+// under FailAll.
+func TestMultiSinkFailAll(t *testing.T) {
+	good := &fakeSink{name: "good"}
+	bad := &fakeSink{name: "bad", fail: true}
+	multi := buffer.NewMultiSink(buffer.FailAll, good, bad)
+
+	err := multi.Write(context.Background(), []buffer.LogEntry{sampleEntry()})
+	if err == nil {
+		t.Fatal("Expected FailAll to report an error when one sink fails")
+	}
+}
+
+// TestMultiSinkBestEffort verifies the batch is considered flushed as long
+// as at least one sink succeeds.
+func TestMultiSinkBestEffort(t *testing.T) {
+	good := &fakeSink{name: "good"}
+	bad := &fakeSink{name: "bad", fail: true}
+	multi := buffer.NewMultiSink(buffer.BestEffort, good, bad)
+
+	if err := multi.Write(context.Background(), []buffer.LogEntry{sampleEntry()}); err != nil {
+		t.Fatalf("Expected BestEffort to succeed with one healthy sink, got: %v", err)
+	}
+	if len(good.batches) != 1 {
+		t.Errorf("Expected the healthy sink to receive the batch")
+	}
+}
+
+// TestMultiSinkBestEffortAllFail verifies BestEffort still fails if every
+// sink fails.
+func TestMultiSinkBestEffortAllFail(t *testing.T) {
+	bad1 := &fakeSink{name: "bad1", fail: true}
+	bad2 := &fakeSink{name: "bad2", fail: true}
+	multi := buffer.NewMultiSink(buffer.BestEffort, bad1, bad2)
+
+	if err := multi.Write(context.Background(), []buffer.LogEntry{sampleEntry()}); err == nil {
+		t.Fatal("Expected BestEffort to fail when every sink fails")
+	}
+}
+
+// TestMultiSinkPrimaryOnly verifies only the first sink's failure matters.
+func TestMultiSinkPrimaryOnly(t *testing.T) {
+	primary := &fakeSink{name: "primary"}
+	secondary := &fakeSink{name: "secondary", fail: true}
+	multi := buffer.NewMultiSink(buffer.PrimaryOnly, primary, secondary)
+
+	if err := multi.Write(context.Background(), []buffer.LogEntry{sampleEntry()}); err != nil {
+		t.Fatalf("Expected PrimaryOnly to ignore a failing secondary sink, got: %v", err)
+	}
+
+	primaryFails := buffer.NewMultiSink(buffer.PrimaryOnly, secondary, primary)
+	if err := primaryFails.Write(context.Background(), []buffer.LogEntry{sampleEntry()}); err == nil {
+		t.Fatal("Expected PrimaryOnly to fail when the primary sink fails")
+	}
+}
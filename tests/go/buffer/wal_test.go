@@ -0,0 +1,214 @@
+// Tests for the buffer package's crash-safe, segmented write-ahead log.
+package buffer_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"mnemosyne/internal/buffer"
+)
+
+func walConfig(t *testing.T) (buffer.BufferConfig, string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "buffer.wal")
+	return buffer.BufferConfig{
+		Capacity:      100,
+		FlushTimeout:  5 * time.Minute,
+		IdleThreshold: 60 * time.Second,
+		WALPath:       path,
+	}, path
+}
+
+// TestWALRoundTrip verifies N entries survive a simulated crash (no flush
+// call) and are replayed in LSN order by Recover.
+func TestWALRoundTrip(t *testing.T) {
+	config, path := walConfig(t)
+	buf := buffer.New(config)
+
+	const n = 7
+	for i := 0; i < n; i++ {
+		buf.Add(buffer.LogEntry{
+			SessionUUID:    "test-session",
+			UnixTime:       int64(1000 + i),
+			ProcessName:    "test.exe",
+			WindowTitle:    "Test Window",
+			WindowHandle:   int64(i),
+			InputIdleMs:    int64(i * 10),
+			InputIntensity: float32(i) * 0.1,
+		})
+	}
+	// Simulated crash: no Flush/ForceFlush, just stop touching the buffer.
+
+	records, err := buffer.Recover(path)
+	if err != nil {
+		t.Fatalf("Recover failed: %v", err)
+	}
+	if len(records) != n {
+		t.Fatalf("Expected %d recovered records, got %d", n, len(records))
+	}
+	for i, record := range records {
+		if record.LSN != int64(i) {
+			t.Errorf("Record %d: expected LSN %d, got %d", i, i, record.LSN)
+		}
+		if record.Entry.WindowHandle != int64(i) {
+			t.Errorf("Record %d: expected WindowHandle %d, got %d", i, i, record.Entry.WindowHandle)
+		}
+		if record.Entry.UnixTime != int64(1000+i) {
+			t.Errorf("Record %d: expected UnixTime %d, got %d", i, 1000+i, record.Entry.UnixTime)
+		}
+	}
+}
+
+// TestBufferCheckpointLeavesNothingToReplay verifies that once GetAndClear's
+// LSN has been Checkpoint-ed (simulating a durably confirmed flush), Recover
+// returns no record past that LSN, so nothing is left to replay. Checkpoint
+// keeps the active segment, so the records themselves are still on disk.
+func TestBufferCheckpointLeavesNothingToReplay(t *testing.T) {
+	config, path := walConfig(t)
+	buf := buffer.New(config)
+
+	buf.Add(buffer.LogEntry{SessionUUID: "s", UnixTime: 1, ProcessName: "p.exe", WindowHandle: 1})
+	buf.Add(buffer.LogEntry{SessionUUID: "s", UnixTime: 2, ProcessName: "p.exe", WindowHandle: 2})
+
+	entries, lsn := buf.GetAndClear()
+	if len(entries) != 2 {
+		t.Fatalf("Expected 2 entries from GetAndClear, got %d", len(entries))
+	}
+	if lsn != 1 {
+		t.Fatalf("Expected GetAndClear to report LSN 1 (the second entry), got %d", lsn)
+	}
+
+	// Before the caller confirms durability, a crash must still be able to
+	// replay what was cleared from memory.
+	records, err := buffer.Recover(path)
+	if err != nil {
+		t.Fatalf("Recover failed: %v", err)
+	}
+	if len(records) != 2 {
+		t.Fatalf("Expected 2 records still recoverable before Checkpoint, got %d", len(records))
+	}
+
+	buf.Checkpoint(lsn)
+
+	records, err = buffer.Recover(path)
+	if err != nil {
+		t.Fatalf("Recover failed: %v", err)
+	}
+	replay := 0
+	for _, rec := range records {
+		if rec.LSN > lsn {
+			replay++
+		}
+	}
+	if replay != 0 {
+		t.Errorf("Expected no records past LSN %d left to replay after Checkpoint, got %d", lsn, replay)
+	}
+}
+
+// TestWALCorruptRecordSkipped verifies Recover stops at (and drops) a
+// record whose CRC doesn't match, without erroring.
+func TestWALCorruptRecordSkipped(t *testing.T) {
+	config, path := walConfig(t)
+	buf := buffer.New(config)
+
+	buf.Add(buffer.LogEntry{SessionUUID: "s", UnixTime: 1, ProcessName: "p.exe", WindowHandle: 1})
+	buf.Add(buffer.LogEntry{SessionUUID: "s", UnixTime: 2, ProcessName: "p.exe", WindowHandle: 2})
+
+	segments, err := filepath.Glob(path + ".*")
+	if err != nil || len(segments) != 1 {
+		t.Fatalf("Expected exactly 1 WAL segment, got %v (err: %v)", segments, err)
+	}
+
+	data, err := os.ReadFile(segments[0])
+	if err != nil {
+		t.Fatalf("Failed to read WAL segment: %v", err)
+	}
+	// Flip a byte inside the second record's payload to break its CRC.
+	data[len(data)-1] ^= 0xFF
+	if err := os.WriteFile(segments[0], data, 0644); err != nil {
+		t.Fatalf("Failed to corrupt WAL segment: %v", err)
+	}
+
+	records, err := buffer.Recover(path)
+	if err != nil {
+		t.Fatalf("Recover should not error on a corrupt trailing record: %v", err)
+	}
+	if len(records) != 1 {
+		t.Fatalf("Expected 1 valid record before the corrupt record, got %d", len(records))
+	}
+	if records[0].Entry.WindowHandle != 1 {
+		t.Errorf("Expected the surviving entry to be the first one, got WindowHandle %d", records[0].Entry.WindowHandle)
+	}
+}
+
+// TestWALPartialWriteTruncated verifies Recover tolerates a segment
+// truncated mid-record (simulating a crash during the write itself), and
+// that reopening the WAL afterwards drops the trailing garbage instead of
+// stranding new records behind it.
+func TestWALPartialWriteTruncated(t *testing.T) {
+	config, path := walConfig(t)
+	buf := buffer.New(config)
+
+	buf.Add(buffer.LogEntry{SessionUUID: "s", UnixTime: 1, ProcessName: "p.exe", WindowHandle: 1})
+	buf.Add(buffer.LogEntry{SessionUUID: "s", UnixTime: 2, ProcessName: "p.exe", WindowHandle: 2})
+	buf.Stop()
+
+	segments, err := filepath.Glob(path + ".*")
+	if err != nil || len(segments) != 1 {
+		t.Fatalf("Expected exactly 1 WAL segment, got %v (err: %v)", segments, err)
+	}
+
+	data, err := os.ReadFile(segments[0])
+	if err != nil {
+		t.Fatalf("Failed to read WAL segment: %v", err)
+	}
+	truncated := data[:len(data)-3]
+	if err := os.WriteFile(segments[0], truncated, 0644); err != nil {
+		t.Fatalf("Failed to truncate WAL segment: %v", err)
+	}
+
+	records, err := buffer.Recover(path)
+	if err != nil {
+		t.Fatalf("Recover should not error on a truncated trailing record: %v", err)
+	}
+	if len(records) != 1 {
+		t.Fatalf("Expected 1 valid record before the truncated record, got %d", len(records))
+	}
+
+	// Reopening (as a fresh process would after the crash) must continue
+	// numbering from the surviving record and must not leave the garbage
+	// bytes reachable behind a new append.
+	buf2 := buffer.New(config)
+	buf2.Add(buffer.LogEntry{SessionUUID: "s", UnixTime: 3, ProcessName: "p.exe", WindowHandle: 3})
+	buf2.Stop()
+
+	records, err = buffer.Recover(path)
+	if err != nil {
+		t.Fatalf("Recover failed after reopening: %v", err)
+	}
+	if len(records) != 2 {
+		t.Fatalf("Expected 2 records after reopening and appending, got %d", len(records))
+	}
+	if records[0].Entry.WindowHandle != 1 || records[1].Entry.WindowHandle != 3 {
+		t.Errorf("Expected WindowHandle sequence [1, 3], got [%d, %d]", records[0].Entry.WindowHandle, records[1].Entry.WindowHandle)
+	}
+	if records[1].LSN != 1 {
+		t.Errorf("Expected the reopened WAL to reuse LSN 1 for the next append, got %d", records[1].LSN)
+	}
+}
+
+// TestWALMissingFileRecoversEmpty verifies a fresh path with no prior WAL
+// recovers cleanly with no entries and no error.
+func TestWALMissingFileRecoversEmpty(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "does-not-exist.wal")
+
+	records, err := buffer.Recover(path)
+	if err != nil {
+		t.Fatalf("Recover on a missing file should not error: %v", err)
+	}
+	if len(records) != 0 {
+		t.Errorf("Expected no records, got %d", len(records))
+	}
+}
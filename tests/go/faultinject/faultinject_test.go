@@ -0,0 +1,58 @@
+//go:build faultinject
+
+// Package faultinject_test exercises the fault-injection framework itself,
+// plus the scenarios call sites use it for (flush timeout, Redis disconnect)
+// that are otherwise timing-dependent and hard to reproduce deterministically.
+package faultinject_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"mnemosyne/internal/faultinject"
+)
+
+func TestTrigger_NoActivation(t *testing.T) {
+	faultinject.Disable("tests.noop")
+	require.NoError(t, faultinject.Trigger("tests.noop"))
+}
+
+func TestTrigger_Error(t *testing.T) {
+	faultinject.Enable("tests.flush-timeout", faultinject.Action{ErrorMsg: "simulated flush timeout"})
+	defer faultinject.Disable("tests.flush-timeout")
+
+	err := faultinject.Trigger("tests.flush-timeout")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "simulated flush timeout")
+}
+
+func TestTrigger_Delay(t *testing.T) {
+	faultinject.Enable("tests.redis-disconnect", faultinject.Action{Delay: 20 * time.Millisecond})
+	defer faultinject.Disable("tests.redis-disconnect")
+
+	start := time.Now()
+	require.NoError(t, faultinject.Trigger("tests.redis-disconnect"))
+	assert.GreaterOrEqual(t, time.Since(start), 20*time.Millisecond)
+}
+
+func TestTrigger_Panic(t *testing.T) {
+	faultinject.Enable("tests.cascade-under-load", faultinject.Action{Panic: true})
+	defer faultinject.Disable("tests.cascade-under-load")
+
+	assert.Panics(t, func() {
+		_ = faultinject.Trigger("tests.cascade-under-load")
+	})
+}
+
+func TestLoadConfig(t *testing.T) {
+	err := faultinject.LoadConfig([]byte(`{"tests.loaded": {"error": "from config"}}`))
+	require.NoError(t, err)
+	defer faultinject.Disable("tests.loaded")
+
+	err = faultinject.Trigger("tests.loaded")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "from config")
+}
@@ -0,0 +1,50 @@
+// Tests for the hostid package's disk-persisted fallback UUID, the only
+// path reachable on a platform without a native machine ID (see
+// hostid_other.go).
+package hostid_test
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+
+	"mnemosyne/internal/hostid"
+)
+
+var v4UUIDPattern = regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+
+// TestGet verifies Get returns a cached, well-formed v4 UUID across
+// repeated calls within the same process, and that the fallback it
+// resolved to (the only path reachable off-Windows, see hostid_other.go)
+// was persisted to disk. Get is memoized with sync.Once, so this has to
+// be a single test rather than separate stability/persistence tests.
+func TestGet(t *testing.T) {
+	configDir := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", configDir)
+
+	id, err := hostid.Get()
+	if err != nil {
+		t.Fatalf("Get() returned error: %v", err)
+	}
+	if !v4UUIDPattern.MatchString(id) {
+		t.Fatalf("Get() = %q, want a v4 UUID", id)
+	}
+
+	again, err := hostid.Get()
+	if err != nil {
+		t.Fatalf("second Get() returned error: %v", err)
+	}
+	if again != id {
+		t.Fatalf("Get() = %q on second call, want cached %q", again, id)
+	}
+
+	path := filepath.Join(configDir, "mnemosyne", "host_id")
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("expected persisted fallback at %q: %v", path, err)
+	}
+	if string(data) != id {
+		t.Fatalf("persisted fallback = %q, want %q", data, id)
+	}
+}
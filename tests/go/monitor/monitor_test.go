@@ -0,0 +1,220 @@
+// Tests for the monitor package.
+package monitor_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"mnemosyne/internal/monitor"
+	"mnemosyne/internal/storage"
+	"mnemosyne/internal/sysinfo"
+)
+
+// fakeBackend is a storage.Backend that records flushed events instead of
+// persisting them, so tests can assert on what the collector loop produced.
+type fakeBackend struct {
+	mu     sync.Mutex
+	events []storage.Event
+}
+
+func (f *fakeBackend) Append(ctx context.Context, event storage.Event) error {
+	return f.FlushBatch(ctx, []storage.Event{event})
+}
+
+func (f *fakeBackend) FlushBatch(ctx context.Context, events []storage.Event) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.events = append(f.events, events...)
+	return nil
+}
+
+func (f *fakeBackend) Query(ctx context.Context, filter storage.QueryFilter) ([]storage.Event, error) {
+	return nil, nil
+}
+
+func (f *fakeBackend) Migrate(ctx context.Context) error { return nil }
+func (f *fakeBackend) Close() error                      { return nil }
+
+func (f *fakeBackend) flushedEvents() []storage.Event {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	cp := make([]storage.Event, len(f.events))
+	copy(cp, f.events)
+	return cp
+}
+
+// fakeSysInfo is a sysinfo.Provider with a fixed foreground window and idle
+// time, letting tests drive the collector loop without a real OS probe.
+type fakeSysInfo struct {
+	win    sysinfo.WindowInfo
+	idle   time.Duration
+	procs  sysinfo.ProcessStats
+	inputs sysinfo.InputStats
+}
+
+func (f fakeSysInfo) Foreground() (sysinfo.WindowInfo, error) { return f.win, nil }
+func (f fakeSysInfo) IdleDuration() (time.Duration, error)    { return f.idle, nil }
+func (f fakeSysInfo) FullScreenExclusive() (bool, error)      { return false, nil }
+func (f fakeSysInfo) Busy() (bool, error)                     { return false, nil }
+func (f fakeSysInfo) SampleProcess(pid uint32) (sysinfo.ProcessStats, error) {
+	return f.procs, nil
+}
+func (f fakeSysInfo) InputActivity() (sysinfo.InputStats, error) {
+	return f.inputs, nil
+}
+func (f fakeSysInfo) SystemPressure() (sysinfo.SystemPressure, error) {
+	return sysinfo.SystemPressure{}, nil
+}
+func (f fakeSysInfo) EnumWindows(deadline time.Duration) ([]sysinfo.WindowSnapshotInfo, error) {
+	return nil, nil
+}
+
+// TestMonitorTicksThroughInjectedSysInfo verifies SetSysInfo's fake is what
+// drives tick()'s window/idle detection, and that a changed window produces
+// a flushed entry.
+func TestMonitorTicksThroughInjectedSysInfo(t *testing.T) {
+	backend := &fakeBackend{}
+	m := monitor.New(backend, monitor.Config{
+		TickInterval:   10 * time.Millisecond,
+		IdleThreshold:  time.Minute,
+		BufferCapacity: 1, // force a flush on the first logged entry
+		FlushTimeout:   time.Hour,
+	})
+	m.SetSysInfo(fakeSysInfo{
+		win:  sysinfo.WindowInfo{Handle: 1, Title: "editor", ProcessName: "vim"},
+		idle: 0,
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+
+	done := make(chan error, 1)
+	go func() { done <- m.Start(ctx) }()
+	<-ctx.Done()
+	if err := <-done; err != nil {
+		t.Fatalf("Start returned error: %v", err)
+	}
+
+	events := backend.flushedEvents()
+	if len(events) == 0 {
+		t.Fatal("expected at least one event to have been flushed via the fake sysinfo.Provider")
+	}
+	if events[0].ProcessName != "vim" || events[0].WindowTitle != "editor" {
+		t.Fatalf("unexpected flushed event: %+v", events[0])
+	}
+}
+
+// TestMonitorPropagatesProcessStats verifies tick() enriches each flushed
+// event with the sysinfo.Provider's SampleProcess reading for the
+// foreground window's PID.
+func TestMonitorPropagatesProcessStats(t *testing.T) {
+	backend := &fakeBackend{}
+	m := monitor.New(backend, monitor.Config{
+		TickInterval:   10 * time.Millisecond,
+		IdleThreshold:  time.Minute,
+		BufferCapacity: 1, // force a flush on the first logged entry
+		FlushTimeout:   time.Hour,
+	})
+	m.SetSysInfo(fakeSysInfo{
+		win:  sysinfo.WindowInfo{Handle: 1, Title: "editor", ProcessName: "vim", PID: 1234},
+		idle: 0,
+		procs: sysinfo.ProcessStats{
+			CPUPercent:    12.5,
+			WorkingSetRSS: 1024 * 1024,
+			IOReadBytes:   4096,
+			IOWriteBytes:  2048,
+			HandleCount:   42,
+		},
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+
+	done := make(chan error, 1)
+	go func() { done <- m.Start(ctx) }()
+	<-ctx.Done()
+	if err := <-done; err != nil {
+		t.Fatalf("Start returned error: %v", err)
+	}
+
+	events := backend.flushedEvents()
+	if len(events) == 0 {
+		t.Fatal("expected at least one event to have been flushed via the fake sysinfo.Provider")
+	}
+	got := events[0]
+	if got.CPUPercent != 12.5 || got.WorkingSetRSS != 1024*1024 || got.IOReadBytes != 4096 ||
+		got.IOWriteBytes != 2048 || got.HandleCount != 42 {
+		t.Fatalf("unexpected process stats on flushed event: %+v", got)
+	}
+}
+
+// TestMonitorPropagatesInputActivity verifies tick() carries the
+// sysinfo.Provider's InputActivity counters onto each flushed event, and
+// prefers its Intensity over the idle-duration heuristic when Supported.
+func TestMonitorPropagatesInputActivity(t *testing.T) {
+	backend := &fakeBackend{}
+	m := monitor.New(backend, monitor.Config{
+		TickInterval:   10 * time.Millisecond,
+		IdleThreshold:  time.Minute,
+		BufferCapacity: 1, // force a flush on the first logged entry
+		FlushTimeout:   time.Hour,
+	})
+	m.SetSysInfo(fakeSysInfo{
+		win:  sysinfo.WindowInfo{Handle: 1, Title: "editor", ProcessName: "vim", PID: 1234},
+		idle: 0,
+		inputs: sysinfo.InputStats{
+			Keystrokes:    30,
+			MouseClicks:   5,
+			MouseTravelPx: 1200,
+			Intensity:     0.42,
+			Supported:     true,
+		},
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+
+	done := make(chan error, 1)
+	go func() { done <- m.Start(ctx) }()
+	<-ctx.Done()
+	if err := <-done; err != nil {
+		t.Fatalf("Start returned error: %v", err)
+	}
+
+	events := backend.flushedEvents()
+	if len(events) == 0 {
+		t.Fatal("expected at least one event to have been flushed via the fake sysinfo.Provider")
+	}
+	got := events[0]
+	if got.Keystrokes != 30 || got.MouseClicks != 5 || got.MouseTravelPx != 1200 {
+		t.Fatalf("unexpected input hook counters on flushed event: %+v", got)
+	}
+	if got.InputIntensity != 0.42 {
+		t.Fatalf("InputIntensity = %v, want the InputActivity-reported 0.42 since Supported was true", got.InputIntensity)
+	}
+}
+
+// TestMonitorSkipsTicksWithoutSysInfo verifies tick() no-ops rather than
+// panicking when no sysinfo.Provider is available (e.g. construction
+// failed and nothing was injected).
+func TestMonitorSkipsTicksWithoutSysInfo(t *testing.T) {
+	backend := &fakeBackend{}
+	m := monitor.New(backend, monitor.Config{
+		TickInterval:   10 * time.Millisecond,
+		IdleThreshold:  time.Minute,
+		BufferCapacity: 100,
+		FlushTimeout:   time.Hour,
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	if err := m.Start(ctx); err != nil {
+		t.Fatalf("Start returned error: %v", err)
+	}
+	if len(backend.flushedEvents()) != 0 {
+		t.Fatal("expected no events without a sysinfo.Provider")
+	}
+}
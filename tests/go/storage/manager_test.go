@@ -0,0 +1,100 @@
+package storage_test
+
+import (
+	"database/sql"
+	"testing"
+
+	"mnemosyne/internal/storage"
+)
+
+// TestManagerGetSQLSharesConnection verifies a second GetSQL for the same
+// uri returns the same *sql.DB without calling open again, and that Close
+// only tears it down once every reference has been released.
+func TestManagerGetSQLSharesConnection(t *testing.T) {
+	mgr := storage.NewManager()
+	opens := 0
+	open := func() (*sql.DB, error) {
+		opens++
+		return sql.Open("sqlite", ":memory:")
+	}
+
+	db1, err := mgr.GetSQL("sqlite:///shared.db", open)
+	if err != nil {
+		t.Fatalf("first GetSQL failed: %v", err)
+	}
+	db2, err := mgr.GetSQL("sqlite:///shared.db", open)
+	if err != nil {
+		t.Fatalf("second GetSQL failed: %v", err)
+	}
+	if db1 != db2 {
+		t.Fatal("expected the second GetSQL to return the same *sql.DB")
+	}
+	if opens != 1 {
+		t.Fatalf("expected open to run once, ran %d times", opens)
+	}
+
+	if err := mgr.Close("sqlite:///shared.db"); err != nil {
+		t.Fatalf("first Close failed: %v", err)
+	}
+	if err := db1.Ping(); err != nil {
+		t.Fatal("expected db to still be open after releasing one of two references")
+	}
+
+	if err := mgr.Close("sqlite:///shared.db"); err != nil {
+		t.Fatalf("second Close failed: %v", err)
+	}
+	if err := db1.Ping(); err == nil {
+		t.Fatal("expected db to be closed after releasing the last reference")
+	}
+}
+
+// TestManagerGetSQLDistinctURIsDontShare verifies two different uris get
+// independent connections.
+func TestManagerGetSQLDistinctURIsDontShare(t *testing.T) {
+	mgr := storage.NewManager()
+	open := func() (*sql.DB, error) { return sql.Open("sqlite", ":memory:") }
+
+	db1, err := mgr.GetSQL("sqlite:///a.db", open)
+	if err != nil {
+		t.Fatalf("GetSQL a failed: %v", err)
+	}
+	db2, err := mgr.GetSQL("sqlite:///b.db", open)
+	if err != nil {
+		t.Fatalf("GetSQL b failed: %v", err)
+	}
+	if db1 == db2 {
+		t.Fatal("expected distinct uris to get distinct connections")
+	}
+	mgr.CloseAll()
+}
+
+// TestManagerCloseAllIgnoresRefcount verifies CloseAll tears a connection
+// down even with outstanding references, for process shutdown.
+func TestManagerCloseAllIgnoresRefcount(t *testing.T) {
+	mgr := storage.NewManager()
+	open := func() (*sql.DB, error) { return sql.Open("sqlite", ":memory:") }
+
+	db, err := mgr.GetSQL("sqlite:///held-twice.db", open)
+	if err != nil {
+		t.Fatalf("first GetSQL failed: %v", err)
+	}
+	if _, err := mgr.GetSQL("sqlite:///held-twice.db", open); err != nil {
+		t.Fatalf("second GetSQL failed: %v", err)
+	}
+
+	if err := mgr.CloseAll(); err != nil {
+		t.Fatalf("CloseAll failed: %v", err)
+	}
+	if err := db.Ping(); err == nil {
+		t.Fatal("expected db to be closed by CloseAll despite two outstanding references")
+	}
+}
+
+// TestManagerCloseUnknownURI verifies releasing a uri that was never
+// registered is a harmless no-op.
+func TestManagerCloseUnknownURI(t *testing.T) {
+	mgr := storage.NewManager()
+	if err := mgr.Close("sqlite:///never-opened.db"); err != nil {
+		t.Fatalf("expected no-op Close to succeed, got %v", err)
+	}
+}
@@ -0,0 +1,151 @@
+package storage_test
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/stretchr/testify/require"
+
+	"mnemosyne/internal/storage"
+)
+
+// TestParseRedisURI_Standalone checks the single-node scheme against a real
+// (but in-memory) Redis server, exercising the path every existing RedisAddr
+// value ("host:port" with no scheme) takes through ParseRedisURI.
+func TestParseRedisURI_Standalone(t *testing.T) {
+	mr := miniredis.RunT(t)
+
+	client, err := storage.NewRedisClientWithOptions(mr.Addr(), storage.RedisClientOptions{})
+	require.NoError(t, err, "NewRedisClientWithOptions should connect to a bare host:port address")
+	defer client.Close()
+
+	require.NoError(t, client.PublishEvent(context.Background(), "{mnemosyne}:events", map[string]interface{}{"k": "v"}))
+	require.Equal(t, int64(1), mr.XLen("{mnemosyne}:events"))
+}
+
+// TestParseRedisURI_StandaloneURI is the same as above but through an
+// explicit redis:// URI with a db segment and a pool_size query parameter,
+// to check the URI form end to end rather than just ParseRedisURI in
+// isolation.
+func TestParseRedisURI_StandaloneURI(t *testing.T) {
+	mr := miniredis.RunT(t)
+
+	opts, err := storage.ParseRedisURI(fmt.Sprintf("redis://%s/0?pool_size=5", mr.Addr()))
+	require.NoError(t, err)
+	require.Equal(t, []string{mr.Addr()}, opts.Addrs)
+	require.Equal(t, 0, opts.DB)
+	require.Equal(t, 5, opts.PoolSize)
+}
+
+// TestParseRedisURI_Cluster checks that a redis+cluster:// URI with several
+// hosts populates Addrs without a MasterName - the combination
+// redis.NewUniversalClient uses to pick a ClusterClient.
+func TestParseRedisURI_Cluster(t *testing.T) {
+	opts, err := storage.ParseRedisURI("redis+cluster://h1:6379,h2:6379,h3:6379")
+	require.NoError(t, err)
+	require.Equal(t, []string{"h1:6379", "h2:6379", "h3:6379"}, opts.Addrs)
+	require.Empty(t, opts.MasterName)
+}
+
+// TestParseRedisURI_Sentinel checks scheme parsing against a fake Sentinel
+// that answers just enough of the protocol (SENTINEL get-master-addr-by-name,
+// PING) for go-redis's FailoverClient to resolve and use the real miniredis
+// master - there's no miniredis Sentinel mode, so sentinelStub below stands
+// in for one.
+func TestParseRedisURI_Sentinel(t *testing.T) {
+	master := miniredis.RunT(t)
+
+	sentinel := newSentinelStub(t, "mymaster", master.Addr())
+	defer sentinel.Close()
+
+	opts, err := storage.ParseRedisURI(fmt.Sprintf("redis+sentinel://%s/mymaster/0", sentinel.Addr()))
+	require.NoError(t, err)
+	require.Equal(t, []string{sentinel.Addr()}, opts.Addrs)
+	require.Equal(t, "mymaster", opts.MasterName)
+
+	client, err := storage.NewRedisClientWithOptions(fmt.Sprintf("redis+sentinel://%s/mymaster/0", sentinel.Addr()), storage.RedisClientOptions{})
+	require.NoError(t, err, "NewRedisClientWithOptions should resolve the master through the Sentinel stub and connect")
+	defer client.Close()
+
+	require.NoError(t, client.PublishEvent(context.Background(), "{mnemosyne}:events", map[string]interface{}{"k": "v"}))
+	require.Equal(t, int64(1), master.XLen("{mnemosyne}:events"))
+}
+
+// sentinelStub is a minimal RESP server answering only the commands
+// go-redis's Sentinel client needs to discover a master: PING (health probe)
+// and SENTINEL get-master-addr-by-name (address resolution). Anything else
+// closes the connection, which is enough for these tests since the client
+// talks to masterAddr directly once resolved.
+type sentinelStub struct {
+	ln         net.Listener
+	masterAddr string
+}
+
+func newSentinelStub(t *testing.T, masterName, masterAddr string) *sentinelStub {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	s := &sentinelStub{ln: ln, masterAddr: masterAddr}
+	go s.serve(masterName)
+	return s
+}
+
+func (s *sentinelStub) Addr() string { return s.ln.Addr().String() }
+func (s *sentinelStub) Close() error { return s.ln.Close() }
+
+func (s *sentinelStub) serve(masterName string) {
+	for {
+		conn, err := s.ln.Accept()
+		if err != nil {
+			return
+		}
+		go s.handle(conn, masterName)
+	}
+}
+
+func (s *sentinelStub) handle(conn net.Conn, masterName string) {
+	defer conn.Close()
+
+	host, port, _ := net.SplitHostPort(s.masterAddr)
+	buf := make([]byte, 4096)
+	for {
+		n, err := conn.Read(buf)
+		if err != nil {
+			return
+		}
+		cmd := string(buf[:n])
+
+		switch {
+		case containsCI(cmd, "PING"):
+			conn.Write([]byte("+PONG\r\n"))
+		case containsCI(cmd, "GET-MASTER-ADDR-BY-NAME"):
+			conn.Write([]byte(fmt.Sprintf("*2\r\n$%d\r\n%s\r\n$%d\r\n%s\r\n", len(host), host, len(port), port)))
+		default:
+			conn.Write([]byte("-ERR unsupported in sentinelStub\r\n"))
+		}
+	}
+	_ = masterName
+}
+
+func containsCI(haystack, needle string) bool {
+	for i := 0; i+len(needle) <= len(haystack); i++ {
+		match := true
+		for j := 0; j < len(needle); j++ {
+			c := haystack[i+j]
+			if c >= 'a' && c <= 'z' {
+				c -= 'a' - 'A'
+			}
+			if c != needle[j] {
+				match = false
+				break
+			}
+		}
+		if match {
+			return true
+		}
+	}
+	return false
+}
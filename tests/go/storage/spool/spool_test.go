@@ -0,0 +1,154 @@
+// Tests for the storage/spool package's durable overflow queue.
+package spool_test
+
+import (
+	"testing"
+
+	"mnemosyne/internal/storage"
+	"mnemosyne/internal/storage/spool"
+)
+
+func testEvent(i int) storage.Event {
+	return storage.Event{
+		SessionUUID: "test-session",
+		UnixTime:    int64(1000 + i),
+		ProcessName: "test.exe",
+		WindowTitle: "Test Window",
+	}
+}
+
+// TestSpoolRoundTrip verifies N appended events are replayed in order by
+// Iterate before anything is acked.
+func TestSpoolRoundTrip(t *testing.T) {
+	s, err := spool.Open(t.TempDir(), 0)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer s.Close()
+
+	const n = 5
+	for i := 0; i < n; i++ {
+		if _, err := s.Append(testEvent(i)); err != nil {
+			t.Fatalf("Append %d failed: %v", i, err)
+		}
+	}
+
+	records, err := s.Iterate(0)
+	if err != nil {
+		t.Fatalf("Iterate failed: %v", err)
+	}
+	if len(records) != n {
+		t.Fatalf("Expected %d records, got %d", n, len(records))
+	}
+	for i, rec := range records {
+		if rec.Event.UnixTime != int64(1000+i) {
+			t.Errorf("Record %d: expected UnixTime %d, got %d", i, 1000+i, rec.Event.UnixTime)
+		}
+	}
+	if depth := s.Depth(); depth != n {
+		t.Errorf("Expected depth %d, got %d", n, depth)
+	}
+}
+
+// TestSpoolAckReclaimsAndHidesRecords verifies Ack advances the cursor so
+// Iterate no longer replays acked entries, and Depth reflects it.
+func TestSpoolAckReclaimsAndHidesRecords(t *testing.T) {
+	s, err := spool.Open(t.TempDir(), 0)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer s.Close()
+
+	var seqs []uint64
+	for i := 0; i < 3; i++ {
+		seq, err := s.Append(testEvent(i))
+		if err != nil {
+			t.Fatalf("Append %d failed: %v", i, err)
+		}
+		seqs = append(seqs, seq)
+	}
+
+	if err := s.Ack(seqs[1]); err != nil {
+		t.Fatalf("Ack failed: %v", err)
+	}
+
+	records, err := s.Iterate(s.Cursor())
+	if err != nil {
+		t.Fatalf("Iterate failed: %v", err)
+	}
+	if len(records) != 1 {
+		t.Fatalf("Expected 1 un-acked record after Ack, got %d", len(records))
+	}
+	if records[0].Seq != seqs[2] {
+		t.Errorf("Expected remaining record to be seq %d, got %d", seqs[2], records[0].Seq)
+	}
+	if depth := s.Depth(); depth != 1 {
+		t.Errorf("Expected depth 1 after Ack, got %d", depth)
+	}
+}
+
+// TestSpoolRecoversAcrossReopen simulates a crash: Open a fresh Spool over
+// the same directory and confirm un-acked entries still replay, while acked
+// ones don't.
+func TestSpoolRecoversAcrossReopen(t *testing.T) {
+	dir := t.TempDir()
+
+	s, err := spool.Open(dir, 0)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	seqA, _ := s.Append(testEvent(0))
+	if _, err := s.Append(testEvent(1)); err != nil {
+		t.Fatalf("Append failed: %v", err)
+	}
+	if err := s.Ack(seqA); err != nil {
+		t.Fatalf("Ack failed: %v", err)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	reopened, err := spool.Open(dir, 0)
+	if err != nil {
+		t.Fatalf("reopen failed: %v", err)
+	}
+	defer reopened.Close()
+
+	if cursor := reopened.Cursor(); cursor != seqA {
+		t.Fatalf("Expected recovered cursor %d, got %d", seqA, cursor)
+	}
+
+	records, err := reopened.Iterate(reopened.Cursor())
+	if err != nil {
+		t.Fatalf("Iterate failed: %v", err)
+	}
+	if len(records) != 1 {
+		t.Fatalf("Expected 1 un-acked record after reopen, got %d", len(records))
+	}
+}
+
+// TestSpoolEvictsOldestPastMaxBytes verifies a tight MaxBytes budget drops
+// the oldest closed, un-acked segment once a second segment exists (the
+// open write segment itself is never evicted) and counts it in Dropped
+// rather than growing disk usage without bound. Events carry a 1MB payload
+// so a handful of Appends cross the 8MB segment-rotation boundary.
+func TestSpoolEvictsOldestPastMaxBytes(t *testing.T) {
+	s, err := spool.Open(t.TempDir(), 1) // tiny budget: evicts as soon as a second segment exists
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer s.Close()
+
+	big := testEvent(0)
+	big.ScreenshotData = make([]byte, 1<<20) // 1MB, so ~8 Appends rotate the first segment
+
+	for i := 0; i < 20; i++ {
+		if _, err := s.Append(big); err != nil {
+			t.Fatalf("Append %d failed: %v", i, err)
+		}
+	}
+
+	if dropped := s.Dropped(); dropped == 0 {
+		t.Error("Expected some entries to be dropped once rotation created a second segment, got 0")
+	}
+}
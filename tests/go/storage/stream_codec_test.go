@@ -0,0 +1,101 @@
+package storage_test
+
+import (
+	"testing"
+
+	"mnemosyne/internal/storage"
+)
+
+// realisticEvent shapes an Event the way the Watcher actually flushes one:
+// a 1080p JPEG at quality 75 typically lands around 150-250KB, so a 200KB
+// filler stands in for ScreenshotData without committing a real JPEG
+// fixture to the repo.
+func realisticEvent() storage.Event {
+	return storage.Event{
+		SessionUUID:    "bench-session-0000-0000",
+		UnixTime:       1700000000000,
+		ProcessName:    "chrome.exe",
+		WindowTitle:    "Mnemosyne - Pull Request #482 - vel5id/mnemosyne - Mozilla Firefox",
+		WindowHandle:   123456,
+		InputIdleMs:    250,
+		InputIntensity: 0.42,
+		ScreenshotPath: "RAM",
+		ScreenshotData: make([]byte, 200*1024),
+		ScreenshotHash: 0xdeadbeefcafef00d,
+	}
+}
+
+func BenchmarkEncodeStreamEvent_JSON(b *testing.B) {
+	event := realisticEvent()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := storage.EncodeStreamEvent(storage.EncodingJSON, event); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkEncodeStreamEvent_MsgPack(b *testing.B) {
+	event := realisticEvent()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := storage.EncodeStreamEvent(storage.EncodingMsgPack, event); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// TestEncodeStreamEvent_PayloadSize documents what this encoding switch is
+// for: no base64 overhead on ScreenshotData, and no repeated field-name
+// keys, once encoded as a single MessagePack blob.
+func TestEncodeStreamEvent_PayloadSize(t *testing.T) {
+	event := realisticEvent()
+
+	jsonFields, err := storage.EncodeStreamEvent(storage.EncodingJSON, event)
+	if err != nil {
+		t.Fatalf("EncodeStreamEvent(json): %v", err)
+	}
+	msgpackFields, err := storage.EncodeStreamEvent(storage.EncodingMsgPack, event)
+	if err != nil {
+		t.Fatalf("EncodeStreamEvent(msgpack): %v", err)
+	}
+
+	jsonSize := streamFieldsSize(jsonFields)
+	msgpackSize := streamFieldsSize(msgpackFields)
+
+	if msgpackSize >= jsonSize {
+		t.Errorf("expected msgpack encoding (%d bytes) to be smaller than json/base64 (%d bytes)", msgpackSize, jsonSize)
+	}
+	t.Logf("json: %d bytes, msgpack: %d bytes (%.1f%% smaller)", jsonSize, msgpackSize, 100*(1-float64(msgpackSize)/float64(jsonSize)))
+}
+
+func streamFieldsSize(fields map[string]interface{}) int {
+	total := 0
+	for k, v := range fields {
+		total += len(k)
+		switch val := v.(type) {
+		case string:
+			total += len(val)
+		case []byte:
+			total += len(val)
+		default:
+			total += 8
+		}
+	}
+	return total
+}
+
+func TestDecodeStreamEvent_RoundTrip(t *testing.T) {
+	event := realisticEvent()
+
+	for _, enc := range []storage.StreamEncoding{storage.EncodingJSON, storage.EncodingMsgPack} {
+		fields, err := storage.EncodeStreamEvent(enc, event)
+		if err != nil {
+			t.Fatalf("EncodeStreamEvent(%s): %v", enc, err)
+		}
+		got := storage.DecodeStreamEvent(fields)
+		if got.SessionUUID != event.SessionUUID || got.ProcessName != event.ProcessName || got.WindowTitle != event.WindowTitle {
+			t.Errorf("%s: round-trip mismatch: got %+v", enc, got)
+		}
+	}
+}
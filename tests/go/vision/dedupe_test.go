@@ -0,0 +1,78 @@
+// Tests for the vision/dedupe package.
+package dedupe_test
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"mnemosyne/internal/vision/dedupe"
+)
+
+func solidImage(w, h int, c color.Color) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.Set(x, y, c)
+		}
+	}
+	return img
+}
+
+// TestComputeIdenticalFrames verifies that fingerprinting the same image
+// twice yields a perfect tile match.
+func TestComputeIdenticalFrames(t *testing.T) {
+	img := solidImage(640, 480, color.RGBA{R: 40, G: 40, B: 40, A: 255})
+
+	a := dedupe.Compute(img)
+	b := dedupe.Compute(img)
+
+	if matches := dedupe.MatchingTiles(a, b); matches != dedupe.FingerprintLen {
+		t.Errorf("Expected all %d tiles to match for identical frames, got %d", dedupe.FingerprintLen, matches)
+	}
+	if !dedupe.SameFrame(a, b, dedupe.DefaultMinMatchingTiles) {
+		t.Error("Expected SameFrame to report true for identical frames")
+	}
+}
+
+// TestComputeChangedFrame verifies that a frame with a large visual change
+// in half the image falls below the default match threshold.
+func TestComputeChangedFrame(t *testing.T) {
+	before := solidImage(640, 480, color.RGBA{R: 10, G: 10, B: 10, A: 255})
+
+	after := image.NewRGBA(before.Bounds())
+	for y := 0; y < 480; y++ {
+		for x := 0; x < 640; x++ {
+			if x < 320 {
+				after.Set(x, y, color.RGBA{R: 10, G: 10, B: 10, A: 255})
+			} else {
+				after.Set(x, y, color.RGBA{R: 250, G: 250, B: 250, A: 255})
+			}
+		}
+	}
+
+	a := dedupe.Compute(before)
+	b := dedupe.Compute(after)
+
+	if dedupe.SameFrame(a, b, dedupe.DefaultMinMatchingTiles) {
+		t.Error("Expected SameFrame to report false once half the frame changed drastically")
+	}
+	if matches := dedupe.MatchingTiles(a, b); matches > dedupe.FingerprintLen/2 {
+		t.Errorf("Expected roughly half the tiles to stop matching, got %d/%d", matches, dedupe.FingerprintLen)
+	}
+}
+
+// TestHashFrame verifies HashFrame is deterministic and sensitive to
+// content changes, matching how ScreenshotHash is used for content
+// addressing.
+func TestHashFrame(t *testing.T) {
+	a := []byte("fake-jpeg-bytes-1")
+	b := []byte("fake-jpeg-bytes-2")
+
+	if dedupe.HashFrame(a) != dedupe.HashFrame(a) {
+		t.Error("Expected HashFrame to be deterministic for identical input")
+	}
+	if dedupe.HashFrame(a) == dedupe.HashFrame(b) {
+		t.Error("Expected HashFrame to differ for different input")
+	}
+}